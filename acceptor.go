@@ -0,0 +1,356 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package pfcp
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// UPAssociationAcceptor accepts the PFCP associations requested by the CP
+// functions, as a UP function defined in TS 29.244 §6.2.6 - §6.2.8.
+//
+// It answers the AssociationSetup/Update/ReleaseRequests when it is registered
+// as a Handler for those message types, e.g., with ServeMux.Handle, and keeps
+// the parameters advertised by the peers.
+type UPAssociationAcceptor struct {
+	// NodeID is the NodeID of this node, put in the responses.
+	// It must not be nil.
+	NodeID *ie.IE
+	// UPFunctionFeatures is put in AssociationSetupResponse if not nil.
+	UPFunctionFeatures *ie.IE
+	// UserPlaneIPResourceInformation is put in AssociationSetupResponse.
+	UserPlaneIPResourceInformation []*ie.IE
+	// SupportedCPFunctionFeatures is the CP function features(the first octet
+	// of CPFunctionFeatures IE) that this node supports. Only the ones also
+	// advertised by the peer are available in the Features of association.
+	SupportedCPFunctionFeatures uint8
+
+	// OnAssociated is called when an association is set up with a peer.
+	OnAssociated func(a *Association)
+	// OnReassociated is called when an association is set up with a peer
+	// that has already been associated, as defined in §6.2.6.2.2.
+	// The sessions with the peer should be deleted, except the ones requested
+	// to be retained by retention, which is the PFCPSessionRetentionInformation
+	// IE in the request(can be nil).
+	// OnAssociated is not called when OnReassociated is called.
+	OnReassociated func(a *Association, retention *ie.IE)
+	// OnReleased is called when the association is released by the peer.
+	OnReleased func(nodeID string)
+
+	conn *Conn
+
+	mu     sync.Mutex
+	assocs map[string]*Association
+}
+
+// NewUPAssociationAcceptor creates a new UPAssociationAcceptor that works on c.
+func NewUPAssociationAcceptor(c *Conn, nodeID *ie.IE) *UPAssociationAcceptor {
+	return &UPAssociationAcceptor{
+		NodeID: nodeID,
+		conn:   c,
+		assocs: make(map[string]*Association),
+	}
+}
+
+// Association returns the association with the peer of nodeID.
+//
+// The returned value is a copy, which is not updated afterwards.
+func (u *UPAssociationAcceptor) Association(nodeID string) (*Association, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	a, ok := u.assocs[nodeID]
+	if !ok {
+		return nil, false
+	}
+	return a.clone(), true
+}
+
+// Features returns the set of features available in the association with the
+// peer of nodeID.
+func (u *UPAssociationAcceptor) Features(nodeID string) (Features, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	a, ok := u.assocs[nodeID]
+	if !ok {
+		return Features{}, false
+	}
+	return a.Features, true
+}
+
+// ServePFCP handles the AssociationSetupRequest, AssociationUpdateRequest and
+// AssociationReleaseRequest from the peers.
+func (u *UPAssociationAcceptor) ServePFCP(w ResponseWriter, peer net.Addr, msg message.Message) {
+	var rsp message.Message
+	switch req := msg.(type) {
+	case *message.AssociationSetupRequest:
+		rsp = u.handleSetup(peer, req)
+	case *message.AssociationUpdateRequest:
+		rsp = u.handleUpdate(peer, req)
+	case *message.AssociationReleaseRequest:
+		rsp = u.handleRelease(peer, req)
+	default:
+		loggerOf(w).Log(LogLevelDebug, "ignored message: not an association message", "peer", peer, "type", msg.MessageTypeName())
+		return
+	}
+
+	if err := w.WriteMessage(rsp); err != nil {
+		loggerOf(w).Log(LogLevelWarn, "failed to respond to request", "peer", peer, "type", msg.MessageTypeName(), "error", err)
+	}
+}
+
+func (u *UPAssociationAcceptor) handleSetup(peer net.Addr, req *message.AssociationSetupRequest) message.Message {
+	nodeID, cause := u.peerNodeID(peer, req.NodeID)
+	if cause != ie.CauseRequestAccepted {
+		return u.rejectSetup(cause)
+	}
+	if req.RecoveryTimeStamp == nil {
+		return u.rejectSetup(ie.CauseMandatoryIEMissing)
+	}
+	ts, err := req.RecoveryTimeStamp.RecoveryTimeStamp()
+	if err != nil {
+		return u.rejectSetup(ie.CauseMandatoryIEIncorrect)
+	}
+
+	a := &Association{
+		NodeID:                         nodeID,
+		Peer:                           peer,
+		State:                          AssociationAssociated,
+		RecoveryTimeStamp:              ts,
+		UPFunctionFeatures:             u.UPFunctionFeatures,
+		UserPlaneIPResourceInformation: u.UserPlaneIPResourceInformation,
+		CPFunctionFeatures:             req.CPFunctionFeatures,
+		Features:                       newFeatures(u.UPFunctionFeatures, cpFeatures(req.CPFunctionFeatures)&u.SupportedCPFunctionFeatures),
+		AlternativeSMFIPAddresses:      alternativeSMFIPAddresses(req.AlternativeSMFIPAddress),
+	}
+	if req.SMFSetID != nil {
+		if id, err := req.SMFSetID.SMFSetIDString(); err == nil {
+			a.SMFSetID = id
+		}
+	}
+
+	u.mu.Lock()
+	_, exists := u.assocs[nodeID]
+	u.assocs[nodeID] = a
+	u.mu.Unlock()
+	u.reportAssociations()
+
+	if exists {
+		u.conn.log().Log(LogLevelInfo, "re-associated", "peer", peer, "nodeID", nodeID)
+		if u.OnReassociated != nil {
+			go u.OnReassociated(a.clone(), req.PFCPSessionRetentionInformation)
+		}
+	} else if u.OnAssociated != nil {
+		go u.OnAssociated(a.clone())
+	}
+
+	ies := []*ie.IE{u.NodeID, ie.NewCause(ie.CauseRequestAccepted), ie.NewRecoveryTimeStamp(u.conn.RecoveryTimeStamp)}
+	if u.UPFunctionFeatures != nil {
+		ies = append(ies, u.UPFunctionFeatures)
+	}
+	ies = append(ies, u.UserPlaneIPResourceInformation...)
+	return message.NewAssociationSetupResponse(0, ies...)
+}
+
+// rejectSetup returns AssociationSetupResponse with cause, which has the
+// RecoveryTimeStamp mandatory even in the rejection.
+func (u *UPAssociationAcceptor) rejectSetup(cause uint8) message.Message {
+	return message.NewAssociationSetupResponse(0, u.NodeID, ie.NewCause(cause), ie.NewRecoveryTimeStamp(u.conn.RecoveryTimeStamp))
+}
+
+func (u *UPAssociationAcceptor) handleUpdate(peer net.Addr, req *message.AssociationUpdateRequest) message.Message {
+	nodeID, cause := u.peerNodeID(peer, req.NodeID)
+	if cause != ie.CauseRequestAccepted {
+		return message.NewAssociationUpdateResponse(0, u.NodeID, ie.NewCause(cause))
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	a, ok := u.assocs[nodeID]
+	if !ok {
+		return message.NewAssociationUpdateResponse(0, u.NodeID, ie.NewCause(ie.CauseNoEstablishedPFCPAssociation))
+	}
+	if req.CPFunctionFeatures != nil {
+		a.CPFunctionFeatures = req.CPFunctionFeatures
+		a.Features = newFeatures(u.UPFunctionFeatures, cpFeatures(req.CPFunctionFeatures)&u.SupportedCPFunctionFeatures)
+	}
+	if len(req.AlternativeSMFIPAddress) > 0 {
+		a.AlternativeSMFIPAddresses = alternativeSMFIPAddresses(req.AlternativeSMFIPAddress)
+	}
+
+	return message.NewAssociationUpdateResponse(0, u.NodeID, ie.NewCause(ie.CauseRequestAccepted))
+}
+
+func (u *UPAssociationAcceptor) handleRelease(peer net.Addr, req *message.AssociationReleaseRequest) message.Message {
+	nodeID, cause := u.peerNodeID(peer, req.NodeID)
+	if cause != ie.CauseRequestAccepted {
+		return message.NewAssociationReleaseResponse(0, u.NodeID, ie.NewCause(cause))
+	}
+
+	u.mu.Lock()
+	_, ok := u.assocs[nodeID]
+	delete(u.assocs, nodeID)
+	u.mu.Unlock()
+	u.reportAssociations()
+
+	if !ok {
+		return message.NewAssociationReleaseResponse(0, u.NodeID, ie.NewCause(ie.CauseNoEstablishedPFCPAssociation))
+	}
+
+	if u.OnReleased != nil {
+		go u.OnReleased(nodeID)
+	}
+	return message.NewAssociationReleaseResponse(0, u.NodeID, ie.NewCause(ie.CauseRequestAccepted))
+}
+
+// GracefulRelease releases the association with the peer of nodeID after
+// draining the sessions in it for period, as defined in TS 29.244 §6.2.8.3.
+//
+// It sends AssociationUpdateRequest with the PARPS flag and period to let the
+// peer stop establishing new sessions, and the SessionEstablishmentRequests
+// from the peer are rejected by the Handler given by Handler after that. The
+// existing sessions are kept until period expires, and then the association
+// is released with AssociationReleaseRequest. OnReleased is called when it
+// is done, even if the peer rejects the request.
+//
+// If ctx is done before period expires, the association is back in use
+// without notifying the peer, and ctx.Err() is returned.
+func (u *UPAssociationAcceptor) GracefulRelease(ctx context.Context, nodeID string, period time.Duration) error {
+	u.mu.Lock()
+	a, ok := u.assocs[nodeID]
+	if !ok {
+		u.mu.Unlock()
+		return ErrNoAssociation
+	}
+	if a.State != AssociationAssociated {
+		u.mu.Unlock()
+		return &AssociationStateError{NodeID: nodeID, State: a.State}
+	}
+	a.State = AssociationReleasing
+	a.ReleaseDeadline = u.conn.clock().Now().Add(period)
+	peer := a.Peer
+	u.mu.Unlock()
+
+	restore := func() {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		if a, ok := u.assocs[nodeID]; ok && a.State == AssociationReleasing {
+			a.State = AssociationAssociated
+			a.ReleaseDeadline = time.Time{}
+		}
+	}
+
+	req := message.NewAssociationUpdateRequest(0, u.NodeID, ie.NewPFCPAUReqFlags(0x01), ie.NewGracefulReleasePeriod(period))
+	rsp, err := u.conn.Request(ctx, req, peer)
+	if err != nil {
+		restore()
+		return err
+	}
+	res, ok := rsp.(*message.AssociationUpdateResponse)
+	if !ok {
+		restore()
+		return &InvalidMessageError{Type: rsp.MessageType()}
+	}
+	if err := ResponseError(res); err != nil {
+		restore()
+		return err
+	}
+
+	t := u.conn.clock().NewTimer(period)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		restore()
+		return ctx.Err()
+	case <-t.C():
+	}
+
+	u.mu.Lock()
+	delete(u.assocs, nodeID)
+	u.mu.Unlock()
+	u.reportAssociations()
+	if u.OnReleased != nil {
+		go u.OnReleased(nodeID)
+	}
+
+	rsp, err = u.conn.Request(ctx, message.NewAssociationReleaseRequest(0, u.NodeID), peer)
+	if err != nil {
+		return err
+	}
+	rel, ok := rsp.(*message.AssociationReleaseResponse)
+	if !ok {
+		return &InvalidMessageError{Type: rsp.MessageType()}
+	}
+	return ResponseError(rel)
+}
+
+// Handler returns a Handler that answers the SessionEstablishmentRequests
+// from the peers in the graceful release with the Cause "No resources
+// available", and passes the other messages to h.
+func (u *UPAssociationAcceptor) Handler(h Handler) Handler {
+	return HandlerFunc(func(w ResponseWriter, peer net.Addr, msg message.Message) {
+		if req, ok := msg.(*message.SessionEstablishmentRequest); ok && u.releasing(peer, req.NodeID) {
+			loggerOf(w).Log(LogLevelInfo, "rejected request: association being released", "peer", peer, "type", msg.MessageTypeName())
+			if err := w.WriteMessage(newCauseResponse(w, msg, ie.CauseNoResourcesAvailable, u.NodeID)); err != nil {
+				loggerOf(w).Log(LogLevelWarn, "failed to respond to request", "peer", peer, "type", msg.MessageTypeName(), "error", err)
+			}
+			return
+		}
+		h.ServePFCP(w, peer, msg)
+	})
+}
+
+// releasing reports whether the association with the peer identified by
+// nodeID, or by the address if nodeID is not available, is being released.
+func (u *UPAssociationAcceptor) releasing(peer net.Addr, nodeID *ie.IE) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if nodeID != nil {
+		if id, err := nodeID.NodeID(); err == nil {
+			a, ok := u.assocs[id]
+			return ok && a.State == AssociationReleasing
+		}
+	}
+	for _, a := range u.assocs {
+		if a.Peer.String() == peer.String() {
+			return a.State == AssociationReleasing
+		}
+	}
+	return false
+}
+
+// reportAssociations passes the number of the associations established to
+// the Metrics of the Conn.
+func (u *UPAssociationAcceptor) reportAssociations() {
+	u.mu.Lock()
+	n := activeAssociations(u.assocs)
+	u.mu.Unlock()
+
+	u.conn.metrics().ActiveAssociations(n)
+}
+
+// peerNodeID returns the NodeID in i, and the cause to respond with if it is
+// missing or invalid.
+func (u *UPAssociationAcceptor) peerNodeID(peer net.Addr, i *ie.IE) (string, uint8) {
+	if i == nil {
+		u.conn.log().Log(LogLevelWarn, "got no NodeID", "peer", peer)
+		return "", ie.CauseMandatoryIEMissing
+	}
+	id, err := i.NodeID()
+	if err != nil {
+		u.conn.log().Log(LogLevelWarn, "got invalid NodeID", "peer", peer, "error", err)
+		return "", ie.CauseMandatoryIEIncorrect
+	}
+	return id, ie.CauseRequestAccepted
+}
@@ -0,0 +1,268 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package pfcp_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/wmnsk/go-pfcp"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+func TestUPAssociationAcceptor(t *testing.T) {
+	associated := make(chan *pfcp.Association, 1)
+	reassociated := make(chan *ie.IE, 1)
+
+	var acc *pfcp.UPAssociationAcceptor
+	up := listen(t, func(c *pfcp.Conn) {
+		c.RecoveryTimeStamp = ts
+		acc = pfcp.NewUPAssociationAcceptor(c, ie.NewNodeID("127.0.0.2", "", ""))
+		acc.UPFunctionFeatures = ie.NewUPFunctionFeatures(0x10, 0x00) // FTUP
+		acc.UserPlaneIPResourceInformation = []*ie.IE{
+			ie.NewUserPlaneIPResourceInformation(0x01, 0, "127.0.0.2", "", "", 0),
+		}
+		acc.SupportedCPFunctionFeatures = 0x01 // LOAD
+		acc.OnAssociated = func(a *pfcp.Association) {
+			associated <- a
+		}
+		acc.OnReassociated = func(a *pfcp.Association, retention *ie.IE) {
+			reassociated <- retention
+		}
+
+		mux := pfcp.NewServeMux()
+		mux.Handle(message.MsgTypeAssociationSetupRequest, acc)
+		mux.Handle(message.MsgTypeAssociationReleaseRequest, acc)
+		c.Handler = mux
+	})
+
+	var m *pfcp.CPAssociationManager
+	listen(t, func(c *pfcp.Conn) {
+		m = pfcp.NewCPAssociationManager(c, ie.NewNodeID("127.0.0.1", "", ""))
+		m.CPFunctionFeatures = ie.NewCPFunctionFeatures(0x03) // LOAD, OVRL
+	})
+
+	ctx := context.Background()
+	a, err := m.Setup(ctx, "127.0.0.2", up.LocalAddr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !a.Features.HasFTUP() || !a.Features.HasLOAD() || !a.Features.HasOVRL() {
+		t.Errorf("got unexpected features on CP: FTUP=%v, LOAD=%v, OVRL=%v", a.Features.HasFTUP(), a.Features.HasLOAD(), a.Features.HasOVRL())
+	}
+	if !a.RecoveryTimeStamp.Equal(ts) {
+		t.Errorf("got RecoveryTimeStamp %s want %s", a.RecoveryTimeStamp, ts)
+	}
+
+	select {
+	case <-associated:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for association")
+	}
+
+	f, ok := acc.Features("127.0.0.1")
+	if !ok {
+		t.Fatal("no association on UP")
+	}
+	if !f.HasFTUP() || !f.HasLOAD() || f.HasOVRL() {
+		t.Errorf("got unexpected features on UP: FTUP=%v, LOAD=%v, OVRL=%v", f.HasFTUP(), f.HasLOAD(), f.HasOVRL())
+	}
+
+	// the second setup is a re-association.
+	if _, err := m.Setup(ctx, "127.0.0.2", up.LocalAddr(), ie.NewPFCPSessionRetentionInformation(nil)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case retention := <-reassociated:
+		if retention == nil {
+			t.Error("got no PFCPSessionRetentionInformation")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for re-association")
+	}
+
+	if err := m.Release(ctx, "127.0.0.2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := acc.Association("127.0.0.1"); ok {
+		t.Error("association remains on UP after release")
+	}
+}
+
+func TestUPAssociationAcceptorFeatures(t *testing.T) {
+	associated := make(chan struct{}, 1)
+
+	var acc *pfcp.UPAssociationAcceptor
+	up := listen(t, func(c *pfcp.Conn) {
+		acc = pfcp.NewUPAssociationAcceptor(c, ie.NewNodeID("127.0.0.2", "", ""))
+		acc.UPFunctionFeatures = ie.NewUPFunctionFeatures(0x00, 0x80, 0x08) // EPFAR, SSET
+		acc.SupportedCPFunctionFeatures = 0x1c                              // EPFAR, SSET, BUNDL
+		acc.OnAssociated = func(a *pfcp.Association) {
+			associated <- struct{}{}
+		}
+		c.Handler = acc
+	})
+
+	var m *pfcp.CPAssociationManager
+	listen(t, func(c *pfcp.Conn) {
+		m = pfcp.NewCPAssociationManager(c, ie.NewNodeID("127.0.0.1", "", ""))
+		m.CPFunctionFeatures = ie.NewCPFunctionFeatures(0x14) // EPFAR, BUNDL
+	})
+
+	a, err := m.Setup(context.Background(), "127.0.0.2", up.LocalAddr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-associated:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for association")
+	}
+	f, ok := acc.Features("127.0.0.1")
+	if !ok {
+		t.Fatal("no association on UP")
+	}
+
+	// only EPFAR is advertised by both.
+	for side, f := range map[string]pfcp.Features{"CP": a.Features, "UP": f} {
+		if !f.HasEPFAR() || f.HasSSET() || f.HasBUNDL() {
+			t.Errorf("got unexpected features on %s: EPFAR=%v, SSET=%v, BUNDL=%v", side, f.HasEPFAR(), f.HasSSET(), f.HasBUNDL())
+		}
+	}
+}
+
+func TestUPAssociationAcceptorGracefulRelease(t *testing.T) {
+	var acc *pfcp.UPAssociationAcceptor
+	up := listen(t, func(c *pfcp.Conn) {
+		acc = pfcp.NewUPAssociationAcceptor(c, ie.NewNodeID("127.0.0.2", "", ""))
+
+		mux := pfcp.NewServeMux()
+		mux.Handle(message.MsgTypeAssociationSetupRequest, acc)
+		mux.HandleFunc(message.MsgTypeSessionEstablishmentRequest, func(w pfcp.ResponseWriter, peer net.Addr, msg message.Message) {
+			_ = w.WriteMessage(message.NewSessionEstablishmentResponse(0, 0, 0, 0, 0, ie.NewCause(ie.CauseRequestAccepted)))
+		})
+		c.Handler = acc.Handler(mux)
+	})
+
+	preparing := make(chan time.Duration, 1)
+	var m *pfcp.CPAssociationManager
+	cp := listen(t, func(c *pfcp.Conn) {
+		m = pfcp.NewCPAssociationManager(c, ie.NewNodeID("127.0.0.1", "", ""))
+		m.OnReleasePreparation = func(nodeID string, period time.Duration) {
+			preparing <- period
+		}
+		c.Handler = m
+	})
+
+	ctx := context.Background()
+	if _, err := m.Setup(ctx, "127.0.0.2", up.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Available("127.0.0.2", "127.0.0.3"); len(got) != 1 {
+		t.Errorf("got available %v", got)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- acc.GracefulRelease(ctx, "127.0.0.1", 2*time.Second)
+	}()
+
+	select {
+	case period := <-preparing:
+		if period != 2*time.Second {
+			t.Errorf("got GracefulReleasePeriod %s", period)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for release preparation")
+	}
+	if got := m.Available("127.0.0.2"); len(got) != 0 {
+		t.Errorf("got available %v during release", got)
+	}
+
+	ser := func() message.Message {
+		return message.NewSessionEstablishmentRequest(0, 0, 0, 0, 0, ie.NewNodeID("127.0.0.1", "", ""))
+	}
+	if _, err := m.Request(ctx, "127.0.0.2", ser()); !errors.Is(err, pfcp.ErrAssociationReleasing) {
+		t.Errorf("got %v want %v", err, pfcp.ErrAssociationReleasing)
+	}
+	// sent bypassing the association check.
+	rsp, err := cp.Request(ctx, ser(), up.LocalAddr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pfcp.ResponseError(rsp); !errors.Is(err, pfcp.ErrNoResourcesAvailable) {
+		t.Errorf("got %v want %v", err, pfcp.ErrNoResourcesAvailable)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for release")
+	}
+	if got := m.State("127.0.0.2"); got != pfcp.AssociationIdle {
+		t.Errorf("got state %s want %s", got, pfcp.AssociationIdle)
+	}
+}
+
+func TestUPAssociationAcceptorRejectSetup(t *testing.T) {
+	up := listen(t, func(c *pfcp.Conn) {
+		c.Handler = pfcp.NewUPAssociationAcceptor(c, ie.NewNodeID("127.0.0.2", "", ""))
+	})
+	cp := listen(t, nil)
+
+	nodeID := ie.NewNodeID("127.0.0.1", "", "")
+	for _, c := range []struct {
+		description string
+		req         *message.AssociationSetupRequest
+		cause       uint8
+	}{
+		{
+			"NoNodeID",
+			message.NewAssociationSetupRequest(0, ie.NewRecoveryTimeStamp(ts)),
+			ie.CauseMandatoryIEMissing,
+		}, {
+			"InvalidNodeID",
+			message.NewAssociationSetupRequest(0, ie.New(ie.NodeID, []byte{0x0f, 0x00}), ie.NewRecoveryTimeStamp(ts)),
+			ie.CauseMandatoryIEIncorrect,
+		}, {
+			"NoRecoveryTimeStamp",
+			message.NewAssociationSetupRequest(0, nodeID),
+			ie.CauseMandatoryIEMissing,
+		}, {
+			"InvalidRecoveryTimeStamp",
+			message.NewAssociationSetupRequest(0, nodeID, ie.New(ie.RecoveryTimeStamp, []byte{0x01})),
+			ie.CauseMandatoryIEIncorrect,
+		},
+	} {
+		t.Run(c.description, func(t *testing.T) {
+			rsp, err := cp.Request(context.Background(), c.req, up.LocalAddr())
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, ok := rsp.(*message.AssociationSetupResponse)
+			if !ok {
+				t.Fatalf("got %s", rsp.MessageTypeName())
+			}
+			if err := message.Validate(res); err != nil {
+				t.Errorf("got invalid response: %v", err)
+			}
+			if got, _ := res.Cause.Cause(); got != c.cause {
+				t.Errorf("got Cause %d want %d", got, c.cause)
+			}
+			for _, i := range res.IEs {
+				if i.Type == ie.OffendingIE {
+					t.Error("got OffendingIE in AssociationSetupResponse")
+				}
+			}
+		})
+	}
+}
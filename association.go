@@ -0,0 +1,556 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package pfcp
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// AssociationState is the state of a PFCP association with a peer.
+type AssociationState int
+
+// AssociationState definitions.
+const (
+	AssociationIdle AssociationState = iota
+	AssociationSettingUp
+	AssociationAssociated
+	AssociationReleasing
+)
+
+// String returns the name of the state.
+func (s AssociationState) String() string {
+	switch s {
+	case AssociationIdle:
+		return "idle"
+	case AssociationSettingUp:
+		return "setting-up"
+	case AssociationAssociated:
+		return "associated"
+	case AssociationReleasing:
+		return "releasing"
+	default:
+		return "unknown"
+	}
+}
+
+// Association is a PFCP association with a peer, and the parameters
+// negotiated in it.
+type Association struct {
+	// NodeID is the NodeID of the peer, in the format returned by (*ie.IE).NodeID.
+	NodeID string
+	// Peer is the address the messages to the peer are sent to.
+	Peer  net.Addr
+	State AssociationState
+	// RecoveryTimeStamp is the one advertised by the peer.
+	RecoveryTimeStamp time.Time
+
+	// The IEs advertised by the UP function.
+	UPFunctionFeatures             *ie.IE
+	UserPlaneIPResourceInformation []*ie.IE
+	UEIPAddressPoolInformation     []*ie.IE
+
+	// CPFunctionFeatures is the one advertised by the CP function.
+	CPFunctionFeatures *ie.IE
+	// SMFSetID is the FQDN of the SMF set the CP function belongs to, or an
+	// empty string if it is not in any.
+	SMFSetID string
+	// AlternativeSMFIPAddresses are the addresses of the other SMFs in the
+	// set, to which the messages can be sent when the CP function fails.
+	AlternativeSMFIPAddresses []net.IP
+	// Features is the set of features available in the association.
+	Features Features
+
+	// ReleaseDeadline is when the UP function releases the association, set
+	// when it starts the graceful release. No new session is established in
+	// the association after it is set.
+	ReleaseDeadline time.Time
+}
+
+func (a *Association) clone() *Association {
+	c := *a
+	c.UserPlaneIPResourceInformation = append([]*ie.IE(nil), a.UserPlaneIPResourceInformation...)
+	c.UEIPAddressPoolInformation = append([]*ie.IE(nil), a.UEIPAddressPoolInformation...)
+	c.AlternativeSMFIPAddresses = append([]net.IP(nil), a.AlternativeSMFIPAddresses...)
+	return &c
+}
+
+// CPAssociationManager manages the PFCP associations of a CP function with
+// the UP functions, as defined in TS 29.244 §6.2.6 - §6.2.8.
+//
+// It sends AssociationSetup/Update/ReleaseRequests to the peers identified by
+// their NodeIDs, and keeps the parameters advertised by them. It also handles
+// the AssociationUpdate/ReleaseRequests from the peers when it is registered
+// as a Handler for those message types, e.g., with ServeMux.Handle.
+//
+// The session related requests should be sent with Request, which refuses to
+// send them to the peers with no association established.
+type CPAssociationManager struct {
+	// NodeID is the NodeID of this node, put in the association messages.
+	// It must not be nil.
+	NodeID *ie.IE
+	// CPFunctionFeatures is put in AssociationSetupRequest if not nil.
+	CPFunctionFeatures *ie.IE
+	// OnReleaseRequested is called when a peer requests the release of the
+	// association by the SARR flag in AssociationUpdateRequest. If nil, the
+	// association is released immediately.
+	OnReleaseRequested func(nodeID string)
+	// OnReleased is called when the association is released by the peer.
+	OnReleased func(nodeID string)
+	// OnReleasePreparation is called when the peer starts the graceful
+	// release of the association with the PARPS flag in
+	// AssociationUpdateRequest, with the period the existing sessions are
+	// kept in.
+	OnReleasePreparation func(nodeID string, period time.Duration)
+	// Load keeps the load of the peers advertised in the responses to the
+	// session related requests sent with Request, if not nil.
+	Load *LoadTracker
+	// Overload keeps the overload of the peers advertised in the responses
+	// to the session related requests sent with Request, and throttles the
+	// requests to the overloaded ones, if not nil.
+	Overload *OverloadTracker
+
+	conn *Conn
+
+	mu     sync.Mutex
+	assocs map[string]*Association
+}
+
+// NewCPAssociationManager creates a new CPAssociationManager that works on c.
+func NewCPAssociationManager(c *Conn, nodeID *ie.IE) *CPAssociationManager {
+	return &CPAssociationManager{
+		NodeID: nodeID,
+		conn:   c,
+		assocs: make(map[string]*Association),
+	}
+}
+
+// Association returns the association with the peer of nodeID.
+//
+// The returned value is a copy, which is not updated afterwards.
+func (m *CPAssociationManager) Association(nodeID string) (*Association, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	a, ok := m.assocs[nodeID]
+	if !ok {
+		return nil, false
+	}
+	return a.clone(), true
+}
+
+// State returns the state of the association with the peer of nodeID.
+func (m *CPAssociationManager) State(nodeID string) AssociationState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if a, ok := m.assocs[nodeID]; ok {
+		return a.State
+	}
+	return AssociationIdle
+}
+
+// transition changes the state of the association with nodeID to "to", if the
+// current state is one of from. The association is created if it is idle.
+func (m *CPAssociationManager) transition(nodeID string, to AssociationState, from ...AssociationState) (*Association, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	a, ok := m.assocs[nodeID]
+	if !ok {
+		a = &Association{NodeID: nodeID, State: AssociationIdle}
+	}
+
+	for _, s := range from {
+		if a.State == s {
+			prev := a.clone()
+			a.State = to
+			m.assocs[nodeID] = a
+			return prev, nil
+		}
+	}
+	return nil, &AssociationStateError{NodeID: nodeID, State: a.State}
+}
+
+// restore puts back the association with nodeID to prev.
+func (m *CPAssociationManager) restore(prev *Association) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if prev.State == AssociationIdle {
+		delete(m.assocs, prev.NodeID)
+		return
+	}
+	m.assocs[prev.NodeID] = prev
+}
+
+// Setup sets up the association with the peer of nodeID at peer address.
+//
+// The AssociationSetupRequest has NodeID, RecoveryTimeStamp and
+// CPFunctionFeatures of this node, and the additional ies given(e.g.,
+// SMFSetID). It can also be called for the peer already associated, to
+// update the association with the new parameters.
+//
+// If the peer rejects the request, CauseError is returned and the
+// association is left in the state before calling Setup.
+func (m *CPAssociationManager) Setup(ctx context.Context, nodeID string, peer net.Addr, ies ...*ie.IE) (*Association, error) {
+	prev, err := m.transition(nodeID, AssociationSettingUp, AssociationIdle, AssociationAssociated)
+	if err != nil {
+		return nil, err
+	}
+
+	reqIEs := []*ie.IE{m.NodeID, ie.NewRecoveryTimeStamp(m.conn.RecoveryTimeStamp)}
+	if m.CPFunctionFeatures != nil {
+		reqIEs = append(reqIEs, m.CPFunctionFeatures)
+	}
+	reqIEs = append(reqIEs, ies...)
+
+	rsp, err := m.conn.Request(ctx, message.NewAssociationSetupRequest(0, reqIEs...), peer)
+	if err != nil {
+		m.restore(prev)
+		return nil, err
+	}
+
+	res, ok := rsp.(*message.AssociationSetupResponse)
+	if !ok {
+		m.restore(prev)
+		return nil, &InvalidMessageError{Type: rsp.MessageType()}
+	}
+	if err := ResponseError(res); err != nil {
+		m.restore(prev)
+		return nil, err
+	}
+
+	a := &Association{
+		NodeID:                         nodeID,
+		Peer:                           peer,
+		State:                          AssociationAssociated,
+		UPFunctionFeatures:             res.UPFunctionFeatures,
+		UserPlaneIPResourceInformation: res.UserPlaneIPResourceInformation,
+		UEIPAddressPoolInformation:     res.UEIPAddressPoolInformation,
+		CPFunctionFeatures:             m.CPFunctionFeatures,
+		Features:                       newFeatures(res.UPFunctionFeatures, cpFeatures(m.CPFunctionFeatures)),
+	}
+	if res.RecoveryTimeStamp != nil {
+		if ts, err := res.RecoveryTimeStamp.RecoveryTimeStamp(); err == nil {
+			a.RecoveryTimeStamp = ts
+		}
+	}
+	if res.NodeID != nil {
+		if id, err := res.NodeID.NodeID(); err == nil && id != nodeID {
+			m.conn.log().Log(LogLevelWarn, "NodeID in Association Setup Response differs", "peer", peer, "nodeID", nodeID, "got", id)
+		}
+	}
+
+	m.mu.Lock()
+	m.assocs[nodeID] = a
+	m.mu.Unlock()
+	m.reportAssociations()
+
+	if m.Load != nil && prev.State == AssociationIdle {
+		m.Load.Forget(nodeID)
+	}
+	if m.Overload != nil && prev.State == AssociationIdle {
+		m.Overload.Forget(nodeID, peer)
+	}
+	return a.clone(), nil
+}
+
+// Update sends AssociationUpdateRequest with the ies given to the peer of
+// nodeID. NodeID of this node is added by Update.
+//
+// The UPFunctionFeatures in the response, if any, replaces the stored one.
+func (m *CPAssociationManager) Update(ctx context.Context, nodeID string, ies ...*ie.IE) error {
+	a, err := m.associated(nodeID)
+	if err != nil {
+		return err
+	}
+
+	rsp, err := m.conn.Request(ctx, message.NewAssociationUpdateRequest(0, append([]*ie.IE{m.NodeID}, ies...)...), a.Peer)
+	if err != nil {
+		return err
+	}
+
+	res, ok := rsp.(*message.AssociationUpdateResponse)
+	if !ok {
+		return &InvalidMessageError{Type: rsp.MessageType()}
+	}
+	if err := ResponseError(res); err != nil {
+		return err
+	}
+
+	if res.UPFunctionFeatures != nil {
+		m.mu.Lock()
+		if a, ok := m.assocs[nodeID]; ok {
+			a.UPFunctionFeatures = res.UPFunctionFeatures
+			a.Features = newFeatures(a.UPFunctionFeatures, cpFeatures(m.CPFunctionFeatures))
+		}
+		m.mu.Unlock()
+	}
+	return nil
+}
+
+// Release releases the association with the peer of nodeID.
+//
+// The association is removed even if the peer does not answer or rejects
+// the request, as the CP function is not supposed to keep it any longer.
+func (m *CPAssociationManager) Release(ctx context.Context, nodeID string) error {
+	prev, err := m.transition(nodeID, AssociationReleasing, AssociationAssociated)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		m.mu.Lock()
+		delete(m.assocs, nodeID)
+		m.mu.Unlock()
+		m.reportAssociations()
+	}()
+
+	rsp, err := m.conn.Request(ctx, message.NewAssociationReleaseRequest(0, m.NodeID), prev.Peer)
+	if err != nil {
+		return err
+	}
+
+	res, ok := rsp.(*message.AssociationReleaseResponse)
+	if !ok {
+		return &InvalidMessageError{Type: rsp.MessageType()}
+	}
+	return ResponseError(res)
+}
+
+// Request sends a session related msg to the peer of nodeID, and waits for
+// the response to it.
+//
+// It returns ErrNoAssociation without sending msg if the association with
+// the peer is not established, ErrAssociationReleasing if msg is
+// SessionEstablishmentRequest to the peer in the graceful release, and
+// *ThrottledError if msg is throttled by Overload. LoadControlInformation and
+// OverloadControlInformation in the response are passed to Load and Overload
+// if set.
+func (m *CPAssociationManager) Request(ctx context.Context, nodeID string, msg message.Message) (message.Message, error) {
+	a, err := m.associated(nodeID)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := msg.(*message.SessionEstablishmentRequest); ok && !a.ReleaseDeadline.IsZero() {
+		return nil, ErrAssociationReleasing
+	}
+	if m.Overload != nil {
+		if err := m.Overload.Throttle(nodeID, a.Peer, msg); err != nil {
+			return nil, err
+		}
+	}
+
+	rsp, err := m.conn.Request(ctx, msg, a.Peer)
+	if err != nil {
+		return nil, err
+	}
+	if m.Load != nil {
+		m.Load.Observe(nodeID, rsp)
+	}
+	if m.Overload != nil {
+		m.Overload.Observe(nodeID, a.Peer, rsp)
+	}
+	return rsp, nil
+}
+
+// DeleteSessionSet sends SessionSetDeletionRequest with the FQ-CSIDs given to
+// the peer of nodeID, to make it delete all the sessions in the connection
+// sets, e.g., those of a failed SGW-C or PGW-C, as defined in TS 29.244
+// §6.2.7. The sessions kept locally should be removed separately, e.g., with
+// SessionRegistry.DeleteSet.
+//
+// It returns ErrMandatoryIEMissing if no FQ-CSID is given, ErrNoAssociation
+// without sending the request if the association with the peer is not
+// established, and CauseError if the peer rejects it.
+func (m *CPAssociationManager) DeleteSessionSet(ctx context.Context, nodeID string, fqcsids ...*ie.IE) error {
+	if len(fqcsids) == 0 {
+		return ErrMandatoryIEMissing
+	}
+
+	a, err := m.associated(nodeID)
+	if err != nil {
+		return err
+	}
+
+	rsp, err := m.conn.Request(ctx, message.NewSessionSetDeletionRequest(0, m.NodeID, fqcsids[0], fqcsids[1:]...), a.Peer)
+	if err != nil {
+		return err
+	}
+
+	res, ok := rsp.(*message.SessionSetDeletionResponse)
+	if !ok {
+		return &InvalidMessageError{Type: rsp.MessageType()}
+	}
+	return ResponseError(res)
+}
+
+// Available returns the ones in nodeIDs that new sessions can be established
+// with, i.e., the associated ones not in the graceful release, in the same
+// order.
+func (m *CPAssociationManager) Available(nodeIDs ...string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var ids []string
+	for _, id := range nodeIDs {
+		if a, ok := m.assocs[id]; ok && a.State == AssociationAssociated && a.ReleaseDeadline.IsZero() {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (m *CPAssociationManager) associated(nodeID string) (*Association, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	a, ok := m.assocs[nodeID]
+	if !ok || a.State != AssociationAssociated {
+		return nil, ErrNoAssociation
+	}
+	return a.clone(), nil
+}
+
+// ServePFCP handles the AssociationUpdateRequest and AssociationReleaseRequest
+// from the peers.
+func (m *CPAssociationManager) ServePFCP(w ResponseWriter, peer net.Addr, msg message.Message) {
+	var (
+		rsp    message.Message
+		nodeID string
+	)
+	switch req := msg.(type) {
+	case *message.AssociationUpdateRequest:
+		nodeID = m.peerNodeID(peer, req.NodeID)
+		rsp = m.handleUpdate(nodeID, req)
+	case *message.AssociationReleaseRequest:
+		nodeID = m.peerNodeID(peer, req.NodeID)
+		rsp = m.handleRelease(nodeID)
+	default:
+		loggerOf(w).Log(LogLevelDebug, "ignored message: not an association message", "peer", peer, "type", msg.MessageTypeName())
+		return
+	}
+
+	if err := w.WriteMessage(rsp); err != nil {
+		loggerOf(w).Log(LogLevelWarn, "failed to respond to request", "peer", peer, "type", msg.MessageTypeName(), "error", err)
+	}
+}
+
+// peerNodeID returns the NodeID in i, or an empty string if it is invalid.
+func (m *CPAssociationManager) peerNodeID(peer net.Addr, i *ie.IE) string {
+	if i == nil {
+		return ""
+	}
+	id, err := i.NodeID()
+	if err != nil {
+		m.conn.log().Log(LogLevelWarn, "got invalid NodeID", "peer", peer, "error", err)
+		return ""
+	}
+	return id
+}
+
+func (m *CPAssociationManager) handleUpdate(nodeID string, req *message.AssociationUpdateRequest) message.Message {
+	var (
+		parps  bool
+		period time.Duration
+	)
+	if f := req.PFCPAUReqFlags; f != nil && f.HasPARPS() {
+		parps = true
+		if req.GracefulReleasePeriod != nil {
+			if d, err := req.GracefulReleasePeriod.GracefulReleasePeriod(); err == nil {
+				period = d
+			}
+		}
+	}
+
+	m.mu.Lock()
+	a, ok := m.assocs[nodeID]
+	associated := ok && a.State == AssociationAssociated
+	if associated {
+		if parps {
+			a.ReleaseDeadline = m.conn.clock().Now().Add(period)
+		}
+		if req.UPFunctionFeatures != nil {
+			a.UPFunctionFeatures = req.UPFunctionFeatures
+			a.Features = newFeatures(a.UPFunctionFeatures, cpFeatures(m.CPFunctionFeatures))
+		}
+		if req.UEIPAddressPoolInformation != nil {
+			a.UEIPAddressPoolInformation = req.UEIPAddressPoolInformation
+		}
+	}
+	m.mu.Unlock()
+
+	if !associated {
+		return newAssociationUpdateResponse(req.Sequence(), m.NodeID, ie.CauseNoEstablishedPFCPAssociation)
+	}
+
+	if parps {
+		m.conn.log().Log(LogLevelInfo, "association to be released", "nodeID", nodeID, "period", period)
+		if m.OnReleasePreparation != nil {
+			go m.OnReleasePreparation(nodeID, period)
+		}
+	}
+	if r := req.PFCPAssociationReleaseRequest; r != nil && r.HasSARR() {
+		if m.OnReleaseRequested != nil {
+			go m.OnReleaseRequested(nodeID)
+		} else {
+			go func() {
+				if err := m.Release(context.Background(), nodeID); err != nil {
+					m.conn.log().Log(LogLevelWarn, "failed to release association", "nodeID", nodeID, "error", err)
+				}
+			}()
+		}
+	}
+
+	return newAssociationUpdateResponse(req.Sequence(), m.NodeID, ie.CauseRequestAccepted)
+}
+
+func (m *CPAssociationManager) handleRelease(nodeID string) message.Message {
+	m.mu.Lock()
+	_, ok := m.assocs[nodeID]
+	delete(m.assocs, nodeID)
+	m.mu.Unlock()
+	m.reportAssociations()
+
+	if !ok {
+		return message.NewAssociationReleaseResponse(0, m.NodeID, ie.NewCause(ie.CauseNoEstablishedPFCPAssociation))
+	}
+
+	if m.OnReleased != nil {
+		go m.OnReleased(nodeID)
+	}
+	return message.NewAssociationReleaseResponse(0, m.NodeID, ie.NewCause(ie.CauseRequestAccepted))
+}
+
+// reportAssociations passes the number of the associations established to
+// the Metrics of the Conn.
+func (m *CPAssociationManager) reportAssociations() {
+	m.mu.Lock()
+	n := activeAssociations(m.assocs)
+	m.mu.Unlock()
+
+	m.conn.metrics().ActiveAssociations(n)
+}
+
+// activeAssociations returns the number of the associations established,
+// including the ones being released.
+func activeAssociations(assocs map[string]*Association) int {
+	n := 0
+	for _, a := range assocs {
+		if a.State == AssociationAssociated || a.State == AssociationReleasing {
+			n++
+		}
+	}
+	return n
+}
+
+func newAssociationUpdateResponse(seq uint32, nodeID *ie.IE, cause uint8) message.Message {
+	return message.NewAssociationUpdateResponse(seq, nodeID, ie.NewCause(cause))
+}
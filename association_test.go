@@ -0,0 +1,102 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package pfcp_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/wmnsk/go-pfcp"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// listenUP returns a Conn that accepts the association with the cause given.
+func listenUP(t *testing.T, cause uint8) *pfcp.Conn {
+	t.Helper()
+
+	upNodeID := ie.NewNodeID("127.0.0.2", "", "")
+	return listen(t, func(c *pfcp.Conn) {
+		mux := pfcp.NewServeMux()
+		mux.HandleFunc(message.MsgTypeAssociationSetupRequest, func(w pfcp.ResponseWriter, peer net.Addr, msg message.Message) {
+			_ = w.WriteMessage(message.NewAssociationSetupResponse(0,
+				upNodeID,
+				ie.NewCause(cause),
+				ie.NewRecoveryTimeStamp(ts),
+				ie.NewUPFunctionFeatures(0x01, 0x00),
+				ie.NewUserPlaneIPResourceInformation(0x01, 0, "127.0.0.2", "", "", 0),
+			))
+		})
+		mux.HandleFunc(message.MsgTypeAssociationReleaseRequest, func(w pfcp.ResponseWriter, peer net.Addr, msg message.Message) {
+			_ = w.WriteMessage(message.NewAssociationReleaseResponse(0, upNodeID, ie.NewCause(ie.CauseRequestAccepted)))
+		})
+		mux.HandleFunc(message.MsgTypeSessionDeletionRequest, func(w pfcp.ResponseWriter, peer net.Addr, msg message.Message) {
+			_ = w.WriteMessage(message.NewSessionDeletionResponse(0, 0, 0, 0, 0, ie.NewCause(ie.CauseSessionContextNotFound)))
+		})
+		c.Handler = mux
+	})
+}
+
+func TestCPAssociationManager(t *testing.T) {
+	up := listenUP(t, ie.CauseRequestAccepted)
+
+	var m *pfcp.CPAssociationManager
+	listen(t, func(c *pfcp.Conn) {
+		m = pfcp.NewCPAssociationManager(c, ie.NewNodeID("127.0.0.1", "", ""))
+	})
+
+	ctx := context.Background()
+	sdr := message.NewSessionDeletionRequest(0, 0, 1, 0, 0)
+	if _, err := m.Request(ctx, "127.0.0.2", sdr); !errors.Is(err, pfcp.ErrNoAssociation) {
+		t.Fatalf("got %v want %v", err, pfcp.ErrNoAssociation)
+	}
+
+	a, err := m.Setup(ctx, "127.0.0.2", up.LocalAddr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.State != pfcp.AssociationAssociated {
+		t.Errorf("got state %s want %s", a.State, pfcp.AssociationAssociated)
+	}
+	if !a.RecoveryTimeStamp.Equal(ts) {
+		t.Errorf("got RecoveryTimeStamp %s want %s", a.RecoveryTimeStamp, ts)
+	}
+	if a.UPFunctionFeatures == nil || !a.UPFunctionFeatures.HasBUCP() {
+		t.Errorf("got UPFunctionFeatures %v, want BUCP", a.UPFunctionFeatures)
+	}
+	if len(a.UserPlaneIPResourceInformation) != 1 {
+		t.Errorf("got %d UserPlaneIPResourceInformation want 1", len(a.UserPlaneIPResourceInformation))
+	}
+
+	if _, err := m.Request(ctx, "127.0.0.2", sdr); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Release(ctx, "127.0.0.2"); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.State("127.0.0.2"); got != pfcp.AssociationIdle {
+		t.Errorf("got state %s want %s", got, pfcp.AssociationIdle)
+	}
+}
+
+func TestCPAssociationManagerRejected(t *testing.T) {
+	up := listenUP(t, ie.CauseRequestRejected)
+
+	var m *pfcp.CPAssociationManager
+	listen(t, func(c *pfcp.Conn) {
+		m = pfcp.NewCPAssociationManager(c, ie.NewNodeID("127.0.0.1", "", ""))
+	})
+
+	_, err := m.Setup(context.Background(), "127.0.0.2", up.LocalAddr())
+	if !errors.Is(err, pfcp.ErrRequestRejected) {
+		t.Fatalf("got %v want %v", err, pfcp.ErrRequestRejected)
+	}
+	if got := m.State("127.0.0.2"); got != pfcp.AssociationIdle {
+		t.Errorf("got state %s want %s", got, pfcp.AssociationIdle)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package pfcp
+
+import (
+	"sync"
+	"time"
+)
+
+// requestKey identifies a request received from a peer.
+type requestKey struct {
+	peer string
+	seid uint64
+	seq  uint32
+}
+
+type cachedResponse struct {
+	b       []byte // nil while the request is being handled
+	name    string // type name of the response, for Metrics
+	cause   uint8  // Cause in the response, for Metrics
+	expires time.Time
+}
+
+// responseCache keeps the responses sent to the requests for a while, so that
+// the retransmitted requests are answered with the same response without
+// being handled again, as required by TS 29.244 §6.4.
+type responseCache struct {
+	mu        sync.Mutex
+	entries   map[requestKey]*cachedResponse
+	nextSweep time.Time
+}
+
+func newResponseCache() *responseCache {
+	return &responseCache{
+		entries: make(map[requestKey]*cachedResponse),
+	}
+}
+
+// lookup reports whether the request identified by key has been received
+// before, and returns the record of it, whose b is nil if no response has been
+// sent yet.
+//
+// If it is the first time, the request is recorded as being handled until
+// the lifetime given elapses.
+func (c *responseCache) lookup(key requestKey, now time.Time, lifetime time.Duration) (*cachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.After(c.nextSweep) {
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		c.nextSweep = now.Add(lifetime)
+	}
+
+	if e, ok := c.entries[key]; ok && !now.After(e.expires) {
+		return e, true
+	}
+
+	c.entries[key] = &cachedResponse{expires: now.Add(lifetime)}
+	return nil, false
+}
+
+// store records rsp as the response to the request identified by key. rsp
+// must not be modified after that.
+func (c *responseCache) store(key requestKey, rsp *cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = rsp
+}
+
+// forget removes the record of the request identified by key, so that the
+// request is handled again when it is retransmitted.
+func (c *responseCache) forget(key requestKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, key)
+}
@@ -0,0 +1,89 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package pfcp_test
+
+import (
+	"bytes"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wmnsk/go-pfcp"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+type countingHandler struct {
+	n int32
+}
+
+func (h *countingHandler) ServePFCP(w pfcp.ResponseWriter, peer net.Addr, msg message.Message) {
+	n := atomic.AddInt32(&h.n, 1)
+	_ = w.WriteMessage(message.NewSessionModificationResponse(
+		0, 0, msg.SEID(), msg.Sequence(), 0,
+		ie.NewCause(ie.CauseRequestAccepted),
+		// differs per call so that the replayed response can be told apart.
+		ie.NewRecoveryTimeStamp(ts.Add(time.Duration(n)*time.Second)),
+	))
+}
+
+func TestConnResponseCache(t *testing.T) {
+	cases := []struct {
+		description string
+		lifetime    time.Duration
+		handled     int32
+	}{
+		{"Enabled", time.Minute, 1},
+		{"Disabled", 0, 2},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			h := &countingHandler{}
+			m := newMetrics(t)
+			srv := listen(t, func(conn *pfcp.Conn) {
+				conn.Handler = h
+				conn.ResponseCacheLifetime = c.lifetime
+				conn.Metrics = m
+			})
+			pc, received := listenRaw(t)
+
+			req, err := message.NewSessionModificationRequest(0, 0, 0x1122334455667788, 1, 0).Marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var responses [][]byte
+			for i := 0; i < 2; i++ {
+				if _, err := pc.WriteTo(req, srv.LocalAddr()); err != nil {
+					t.Fatal(err)
+				}
+				select {
+				case b := <-received:
+					responses = append(responses, b)
+				case <-time.After(time.Second):
+					t.Fatal("timed out waiting for response")
+				}
+			}
+
+			if got, want := atomic.LoadInt32(&h.n), c.handled; got != want {
+				t.Errorf("handler called %d times, want %d", got, want)
+			}
+			if got, want := bytes.Equal(responses[0], responses[1]), c.handled == 1; got != want {
+				t.Errorf("responses are identical: %v, want %v", got, want)
+			}
+
+			// the replayed response is counted as well, after it is sent.
+			deadline := time.Now().Add(time.Second)
+			for metric(m, "sent", "Session Modification Response/1") != "2" && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			if got := metric(m, "sent", "Session Modification Response/1"); got != "2" {
+				t.Errorf("got %q responses sent", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,570 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package pfcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/internal/logger"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// Default values of the retransmission parameters.
+//
+// The values are left to the operators by TS 29.244 §6.4; these are the ones
+// commonly used in the deployments.
+const (
+	DefaultT1 = 3 * time.Second
+	DefaultN1 = 3
+)
+
+// maxDatagramSize is the size of the buffer used to read a datagram.
+const maxDatagramSize = 0xffff
+
+// Conn represents a PFCP node bound to a net.PacketConn.
+//
+// Conn assigns the sequence numbers to the requests sent with Request, and
+// matches the responses to them by the sequence number and the peer address.
+// The requests received from the peers are passed to Handler.
+type Conn struct {
+	// T1 is the time to wait for a response before retransmitting the request.
+	T1 time.Duration
+	// N1 is the maximum number of retransmissions of a request.
+	N1 int
+	// RecoveryTimeStamp is the time this node has started, advertised to the
+	// peers in HeartbeatRequest/Response and the PFCP association messages.
+	RecoveryTimeStamp time.Time
+	// Handler handles the requests received from the peers.
+	// The requests are silently discarded if nil.
+	Handler Handler
+	// ResponseCacheLifetime is how long the responses sent by Handler are kept
+	// to answer the retransmitted requests. Within the lifetime, a request with
+	// the same peer, SEID and sequence number is not passed to Handler again,
+	// and the cached response, if any, is sent back instead.
+	// The cache is disabled if zero.
+	ResponseCacheLifetime time.Duration
+	// ValidateRequests makes Conn check the presence and types of the IEs in
+	// the requests with message.Validate before passing them to Handler.
+	// The invalid requests are answered by Conn itself with the Cause and
+	// OffendingIE, as well as the requests that cannot be decoded, which
+	// are always answered if Handler is set.
+	ValidateRequests bool
+	// NodeID is put in the error responses sent by Conn itself, to the
+	// requests that have NodeID as a mandatory IE in its response.
+	NodeID *ie.IE
+	// QueueSize is the maximum number of the messages waiting in each of the
+	// inbound and outbound queues, which serve the messages in the order of
+	// priority. When a queue is full, the message with the lowest priority is
+	// dropped first. The queues are disabled if zero, and the messages are sent
+	// and handled as soon as possible.
+	QueueSize int
+	// QueueWorkers is the number of goroutines that take the requests out of
+	// the inbound queue and pass them to Handler. One if zero.
+	QueueWorkers int
+	// DefaultPriority is the priority of the session related messages without
+	// the MP flag, used in the queues. 0 is the highest and 15 is the lowest.
+	DefaultPriority uint8
+	// Clock provides the time to Conn and the components working on it, i.e.,
+	// the retransmissions, the response cache, the Heartbeat interval and
+	// the graceful release of the associations. The system clock is used if
+	// nil. LoadTracker and OverloadTracker have their own Clock.
+	Clock Clock
+	// Metrics receives the events of the messages sent and received by Conn,
+	// and the associations set up with it, if not nil.
+	Metrics Metrics
+	// Logger receives the logs of Conn and the components working on it, e.g.,
+	// the Handlers given the ResponseWriter of Conn. The default Logger set
+	// with SetDefaultLogger is used if nil.
+	Logger Logger
+
+	pktConn net.PacketConn
+	cache   *responseCache
+
+	mu       sync.Mutex
+	sequence uint32
+	pending  map[transaction]chan message.Message
+
+	queueOnce sync.Once
+	inQueue   *priorityQueue
+	outQueue  *priorityQueue
+
+	closeOnce sync.Once
+	closeCh   chan struct{}
+}
+
+// log returns the Logger of c.
+func (c *Conn) log() Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+	return logger.Default()
+}
+
+// clock returns the Clock of c.
+func (c *Conn) clock() Clock {
+	if c.Clock != nil {
+		return c.Clock
+	}
+	return realClock{}
+}
+
+// metrics returns the Metrics of c.
+func (c *Conn) metrics() Metrics {
+	if c.Metrics != nil {
+		return c.Metrics
+	}
+	return noMetrics{}
+}
+
+// transaction identifies an outstanding request.
+type transaction struct {
+	peer string
+	seq  uint32
+}
+
+// NewConn creates a new Conn on top of pc.
+//
+// T1 and N1 are set to DefaultT1 and DefaultN1, and ResponseCacheLifetime is
+// set to the time the peer with the same parameters keeps retransmitting a
+// request. RecoveryTimeStamp is set to the current time, and DefaultPriority
+// is set to DefaultMessagePriority.
+// Serve must be called to start receiving messages.
+func NewConn(pc net.PacketConn) *Conn {
+	return &Conn{
+		T1:                    DefaultT1,
+		N1:                    DefaultN1,
+		RecoveryTimeStamp:     time.Now(),
+		ResponseCacheLifetime: DefaultT1 * (DefaultN1 + 1),
+		DefaultPriority:       DefaultMessagePriority,
+		pktConn:               pc,
+		cache:                 newResponseCache(),
+		pending:               make(map[transaction]chan message.Message),
+		closeCh:               make(chan struct{}),
+	}
+}
+
+// Listen announces on the local network address and returns a new Conn.
+//
+// The network must be "udp", "udp4" or "udp6".
+func Listen(network, address string) (*Conn, error) {
+	pc, err := net.ListenPacket(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewConn(pc), nil
+}
+
+// LocalAddr returns the local network address.
+func (c *Conn) LocalAddr() net.Addr {
+	return c.pktConn.LocalAddr()
+}
+
+// Close closes the connection.
+//
+// Any blocked Request calls are unblocked and return ErrConnClosed, as do the
+// ones called after that.
+func (c *Conn) Close() error {
+	err := ErrConnClosed
+	c.closeOnce.Do(func() {
+		close(c.closeCh)
+		// prevent the queues from being started after this.
+		c.queueOnce.Do(func() {})
+		if c.inQueue != nil {
+			c.inQueue.close()
+			c.outQueue.close()
+		}
+		err = c.pktConn.Close()
+	})
+	return err
+}
+
+// closed reports whether Close has been called.
+func (c *Conn) closed() bool {
+	select {
+	case <-c.closeCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// InboundQueueStats returns the counters of the queue of the requests
+// received, which are all zero if QueueSize is zero.
+func (c *Conn) InboundQueueStats() QueueStats {
+	c.startQueues()
+	if c.inQueue == nil {
+		return QueueStats{}
+	}
+	return c.inQueue.snapshot()
+}
+
+// OutboundQueueStats returns the counters of the queue of the messages to be
+// sent, which are all zero if QueueSize is zero.
+func (c *Conn) OutboundQueueStats() QueueStats {
+	c.startQueues()
+	if c.outQueue == nil {
+		return QueueStats{}
+	}
+	return c.outQueue.snapshot()
+}
+
+// startQueues starts the queues and the goroutines serving them, if the
+// queues are enabled and not started yet.
+func (c *Conn) startQueues() {
+	c.queueOnce.Do(func() {
+		if c.QueueSize <= 0 {
+			return
+		}
+
+		c.inQueue = newPriorityQueue(c.QueueSize)
+		c.outQueue = newPriorityQueue(c.QueueSize)
+		go c.writeQueued()
+
+		workers := c.QueueWorkers
+		if workers <= 0 {
+			workers = 1
+		}
+		for i := 0; i < workers; i++ {
+			go c.serveQueued()
+		}
+	})
+}
+
+// writeQueued sends the messages in the outbound queue until it is closed.
+func (c *Conn) writeQueued() {
+	for m := c.outQueue.pop(); m != nil; m = c.outQueue.pop() {
+		if _, err := c.pktConn.WriteTo(m.b, m.peer); err != nil {
+			c.log().Log(LogLevelWarn, "failed to send message", "peer", m.peer, "error", err)
+		}
+	}
+}
+
+// serveQueued passes the requests in the inbound queue to Handler until it is
+// closed.
+func (c *Conn) serveQueued() {
+	for m := c.inQueue.pop(); m != nil; m = c.inQueue.pop() {
+		w := &response{conn: c, peer: m.peer, req: m.msg, key: m.key}
+		c.Handler.ServePFCP(w, m.peer, m.msg)
+	}
+}
+
+// send sends b to peer, through the outbound queue if it is enabled.
+//
+// It returns ErrMessageDropped if b is dropped as the queue is full of the
+// messages with higher or the same priority.
+func (c *Conn) send(b []byte, pri uint8, peer net.Addr) error {
+	c.startQueues()
+	if c.outQueue == nil {
+		_, err := c.pktConn.WriteTo(b, peer)
+		return err
+	}
+
+	m := &queuedMessage{pri: pri, b: b, peer: peer}
+	if dropped := c.outQueue.push(m); dropped != nil {
+		if dropped == m {
+			return ErrMessageDropped
+		}
+		c.log().Log(LogLevelWarn, "dropped message: outbound queue is full", "peer", dropped.peer, "priority", dropped.pri)
+	}
+	return nil
+}
+
+// NextSequence increments the sequence number and returns it.
+//
+// The sequence number is 24-bit long and wraps around to 0 after 0xffffff.
+func (c *Conn) NextSequence() uint32 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sequence = (c.sequence + 1) & 0xffffff
+	return c.sequence
+}
+
+// Serve reads the messages from the connection until it is closed.
+//
+// The responses are delivered to the Request calls waiting for them, and the
+// other messages are passed to Handler in a new goroutine, or through the
+// inbound queue if QueueSize is set.
+// Serve always returns a non-nil error; ErrConnClosed after Close is called.
+func (c *Conn) Serve() error {
+	c.startQueues()
+
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, peer, err := c.pktConn.ReadFrom(buf)
+		if err != nil {
+			select {
+			case <-c.closeCh:
+				return ErrConnClosed
+			default:
+				return err
+			}
+		}
+
+		// the decoded message refers to the buffer; copy it not to be overwritten.
+		b := make([]byte, n)
+		copy(b, buf[:n])
+		c.handle(b, peer)
+	}
+}
+
+// handle handles the messages in a datagram, which may have multiple
+// messages chained with the FO flag.
+func (c *Conn) handle(b []byte, peer net.Addr) {
+	bs, err := message.SplitMulti(b)
+	if err != nil {
+		// let handleMessage deal with the broken one.
+		bs = [][]byte{b}
+	}
+	for _, mb := range bs {
+		c.handleMessage(mb, peer)
+	}
+}
+
+func (c *Conn) handleMessage(b []byte, peer net.Addr) {
+	msg, err := message.Parse(b)
+	if err != nil {
+		// the unsupported version is answered regardless of the handler.
+		if errors.Is(err, message.ErrVersionNotSupported) && !isResponse(b[1]) {
+			c.reject(b, peer, err)
+			return
+		}
+		if len(b) < 2 || isResponse(b[1]) || c.Handler == nil {
+			c.log().Log(LogLevelDebug, "ignored undecodable message", "peer", peer, "message", fmt.Sprintf("%x", b), "error", err)
+			return
+		}
+		c.reject(b, peer, err)
+		return
+	}
+
+	c.metrics().MessageReceived(msg.MessageTypeName(), causeOf(msg))
+
+	if isResponse(msg.MessageType()) {
+		c.deliver(msg, peer)
+		return
+	}
+
+	if c.Handler == nil {
+		c.log().Log(LogLevelDebug, "ignored message: no handler", "peer", peer, "type", msg.MessageTypeName())
+		return
+	}
+
+	if c.ValidateRequests {
+		if err := message.Validate(msg); err != nil {
+			c.reject(b, peer, err)
+			return
+		}
+	}
+
+	w := &response{
+		conn: c,
+		peer: peer,
+		req:  msg,
+		key:  requestKey{peer: peer.String(), seid: msg.SEID(), seq: msg.Sequence()},
+	}
+	if c.ResponseCacheLifetime > 0 {
+		cached, dup := c.cache.lookup(w.key, c.clock().Now(), c.ResponseCacheLifetime)
+		if dup {
+			c.replay(msg, peer, cached)
+			return
+		}
+	}
+
+	if c.inQueue == nil {
+		go c.Handler.ServePFCP(w, peer, msg)
+		return
+	}
+
+	m := &queuedMessage{pri: priorityOf(msg, c.DefaultPriority), b: b, peer: peer, msg: msg, key: w.key}
+	if dropped := c.inQueue.push(m); dropped != nil {
+		// let the retransmitted one be handled.
+		c.cache.forget(dropped.key)
+		c.log().Log(LogLevelWarn, "dropped message: inbound queue is full", "peer", dropped.peer, "type", dropped.msg.MessageTypeName(), "priority", dropped.pri)
+	}
+}
+
+// reject answers the request in b that cannot be decoded or is invalid with
+// the error response.
+func (c *Conn) reject(b []byte, peer net.Addr, err error) {
+	rsp, rerr := message.NewErrorResponse(b, err, c.NodeID, ie.NewRecoveryTimeStamp(c.RecoveryTimeStamp))
+	if rerr != nil {
+		c.log().Log(LogLevelDebug, "ignored invalid message", "peer", peer, "message", fmt.Sprintf("%x", b), "error", err)
+		return
+	}
+
+	c.log().Log(LogLevelInfo, "rejecting invalid message", "peer", peer, "type", rsp.MessageTypeName(), "error", err)
+	if err := c.WriteMessageTo(rsp, peer); err != nil {
+		c.log().Log(LogLevelWarn, "failed to respond to invalid message", "peer", peer, "error", err)
+	}
+}
+
+// replay sends the cached response to a retransmitted request.
+func (c *Conn) replay(msg message.Message, peer net.Addr, cached *cachedResponse) {
+	if cached.b == nil {
+		c.log().Log(LogLevelDebug, "ignored retransmitted message: still being handled", "peer", peer, "type", msg.MessageTypeName(), "seq", msg.Sequence())
+		return
+	}
+
+	if err := c.send(cached.b, priorityOf(msg, c.DefaultPriority), peer); err != nil {
+		c.log().Log(LogLevelWarn, "failed to resend response to retransmitted message", "peer", peer, "type", msg.MessageTypeName(), "error", err)
+		return
+	}
+	c.metrics().MessageSent(cached.name, cached.cause)
+}
+
+func (c *Conn) deliver(msg message.Message, peer net.Addr) {
+	key := transaction{peer: peer.String(), seq: msg.Sequence()}
+
+	c.mu.Lock()
+	ch, ok := c.pending[key]
+	delete(c.pending, key)
+	c.mu.Unlock()
+
+	if !ok {
+		c.log().Log(LogLevelDebug, "ignored response: no request waiting", "peer", peer, "type", msg.MessageTypeName(), "seq", msg.Sequence())
+		return
+	}
+
+	ch <- msg
+}
+
+// WriteMessageTo sends msg to peer as it is, without waiting for any response.
+//
+// If the outbound queue is enabled, msg is put in the queue to be sent, and
+// ErrMessageDropped is returned if the queue is full.
+func (c *Conn) WriteMessageTo(msg message.Message, peer net.Addr) error {
+	b, err := marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	if err := c.send(b, priorityOf(msg, c.DefaultPriority), peer); err != nil {
+		return err
+	}
+	c.metrics().MessageSent(msg.MessageTypeName(), causeOf(msg))
+	return nil
+}
+
+// Request sends msg to peer and waits for the response to it.
+//
+// The sequence number in msg is overwritten with the one assigned by Conn.
+// If no response arrives within T1, the same request is sent again up to N1
+// times, and ErrTimeout is returned when all of them are left unanswered.
+// ErrVersionNotSupported is returned if the peer answers with
+// VersionNotSupportedResponse.
+//
+// The returned message is the typed one returned by message.Parse, e.g.,
+// *message.HeartbeatResponse for a *message.HeartbeatRequest.
+//
+// Serve must be running to receive the response.
+func (c *Conn) Request(ctx context.Context, msg message.Message, peer net.Addr) (message.Message, error) {
+	s, ok := msg.(interface{ SetSequenceNumber(uint32) })
+	if !ok {
+		return nil, &InvalidMessageError{Type: msg.MessageType()}
+	}
+
+	seq := c.NextSequence()
+	s.SetSequenceNumber(seq)
+
+	b, err := marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	pri := priorityOf(msg, c.DefaultPriority)
+	key := transaction{peer: peer.String(), seq: seq}
+	ch := make(chan message.Message, 1)
+
+	c.mu.Lock()
+	c.pending[key] = ch
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, key)
+		c.mu.Unlock()
+	}()
+
+	start := c.clock().Now()
+	sent := false
+	for n := 0; n <= c.N1; n++ {
+		if n > 0 {
+			c.log().Log(LogLevelInfo, "retransmitting request", "peer", peer, "type", msg.MessageTypeName(), "seq", seq, "attempt", n)
+		}
+
+		if err := c.send(b, pri, peer); err != nil {
+			if c.closed() {
+				return nil, ErrConnClosed
+			}
+			if err != ErrMessageDropped {
+				return nil, err
+			}
+			// treat it as lost, to be retransmitted later.
+			c.log().Log(LogLevelWarn, "dropped request: outbound queue is full", "peer", peer, "type", msg.MessageTypeName(), "seq", seq)
+		} else if sent {
+			c.metrics().Retransmission(msg.MessageTypeName())
+		} else {
+			c.metrics().MessageSent(msg.MessageTypeName(), 0)
+			sent = true
+		}
+
+		timer := c.clock().NewTimer(c.T1)
+		select {
+		case rsp := <-ch:
+			timer.Stop()
+			c.metrics().RequestLatency(msg.MessageTypeName(), c.clock().Now().Sub(start))
+			if _, ok := rsp.(*message.VersionNotSupportedResponse); ok {
+				return nil, ErrVersionNotSupported
+			}
+			return rsp, nil
+		case <-timer.C():
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-c.closeCh:
+			timer.Stop()
+			return nil, ErrConnClosed
+		}
+	}
+
+	c.metrics().Timeout(msg.MessageTypeName())
+	return nil, ErrTimeout
+}
+
+func marshal(msg message.Message) ([]byte, error) {
+	b := make([]byte, msg.MarshalLen())
+	if err := msg.MarshalTo(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// isResponse reports whether the type of message is a response.
+func isResponse(mtype uint8) bool {
+	switch mtype {
+	case message.MsgTypeHeartbeatResponse,
+		message.MsgTypePFDManagementResponse,
+		message.MsgTypeAssociationSetupResponse,
+		message.MsgTypeAssociationUpdateResponse,
+		message.MsgTypeAssociationReleaseResponse,
+		message.MsgTypeVersionNotSupportedResponse,
+		message.MsgTypeNodeReportResponse,
+		message.MsgTypeSessionSetDeletionResponse,
+		message.MsgTypeSessionEstablishmentResponse,
+		message.MsgTypeSessionModificationResponse,
+		message.MsgTypeSessionDeletionResponse,
+		message.MsgTypeSessionReportResponse:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,397 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package pfcp_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wmnsk/go-pfcp"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+var ts = time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+type heartbeatResponder struct{}
+
+func (heartbeatResponder) ServePFCP(w pfcp.ResponseWriter, peer net.Addr, msg message.Message) {
+	if _, ok := msg.(*message.HeartbeatRequest); !ok {
+		return
+	}
+	_ = w.WriteMessage(message.NewHeartbeatResponse(msg.Sequence(), ie.NewRecoveryTimeStamp(ts)))
+}
+
+// listen returns a new Conn on loopback, which starts serving after setup is
+// called with it.
+func listen(t *testing.T, setup func(c *pfcp.Conn)) *pfcp.Conn {
+	t.Helper()
+
+	c, err := pfcp.Listen("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if setup != nil {
+		setup(c)
+	}
+	go func() { _ = c.Serve() }()
+	t.Cleanup(func() { _ = c.Close() })
+
+	return c
+}
+
+// listenRaw returns a bare PacketConn that passes the received datagrams to ch.
+func listenRaw(t *testing.T) (net.PacketConn, <-chan []byte) {
+	t.Helper()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = pc.Close() })
+
+	ch := make(chan []byte, 16)
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, _, err := pc.ReadFrom(buf)
+			if err != nil {
+				close(ch)
+				return
+			}
+			b := make([]byte, n)
+			copy(b, buf[:n])
+			ch <- b
+		}
+	}()
+
+	return pc, ch
+}
+
+func TestConnRequest(t *testing.T) {
+	srv := listen(t, func(c *pfcp.Conn) {
+		c.Handler = heartbeatResponder{}
+	})
+	cli := listen(t, nil)
+	rsp, err := cli.Request(context.Background(), message.NewHeartbeatRequest(0, ie.NewRecoveryTimeStamp(ts), nil), srv.LocalAddr())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hbrsp, ok := rsp.(*message.HeartbeatResponse)
+	if !ok {
+		t.Fatalf("got unexpected response: %s", rsp.MessageTypeName())
+	}
+	if got, want := hbrsp.Sequence(), uint32(1); got != want {
+		t.Errorf("got SequenceNumber %d want %d", got, want)
+	}
+}
+
+func TestConnRetransmission(t *testing.T) {
+	pc, received := listenRaw(t)
+
+	cli := listen(t, func(c *pfcp.Conn) {
+		c.T1 = 50 * time.Millisecond
+	})
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := cli.Request(context.Background(), message.NewHeartbeatRequest(0, ie.NewRecoveryTimeStamp(ts), nil), pc.LocalAddr())
+		errCh <- err
+	}()
+
+	first := <-received
+	second := <-received
+	if !bytes.Equal(first, second) {
+		t.Fatalf("retransmitted request differs: %x, %x", first, second)
+	}
+
+	req, err := message.ParseHeartbeatRequest(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := message.NewHeartbeatResponse(req.Sequence(), ie.NewRecoveryTimeStamp(ts)).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pc.WriteTo(b, cli.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConnTimeout(t *testing.T) {
+	pc, received := listenRaw(t)
+
+	cli := listen(t, func(c *pfcp.Conn) {
+		c.T1 = 10 * time.Millisecond
+		c.N1 = 2
+	})
+
+	_, err := cli.Request(context.Background(), message.NewHeartbeatRequest(0, ie.NewRecoveryTimeStamp(ts), nil), pc.LocalAddr())
+	if !errors.Is(err, pfcp.ErrTimeout) {
+		t.Fatalf("got %v want %v", err, pfcp.ErrTimeout)
+	}
+
+	for i := 0; i < cli.N1+1; i++ {
+		select {
+		case <-received:
+		case <-time.After(time.Second):
+			t.Fatalf("got %d requests want %d", i, cli.N1+1)
+		}
+	}
+}
+
+func TestConnRejectInvalidRequest(t *testing.T) {
+	h := &countingHandler{}
+	srv := listen(t, func(c *pfcp.Conn) {
+		c.Handler = h
+		c.ValidateRequests = true
+	})
+	pc, received := listenRaw(t)
+
+	// no NodeID and CP F-SEID.
+	req, err := message.NewSessionEstablishmentRequest(0, 0, 0, 1, 0,
+		ie.NewCreatePDR(ie.NewPDRID(1), ie.NewFARID(1)),
+		ie.NewCreateFAR(ie.NewFARID(1), ie.NewApplyAction(0x02)),
+	).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pc.WriteTo(req, srv.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+
+	var b []byte
+	select {
+	case b = <-received:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for response")
+	}
+
+	rsp, err := message.ParseSessionEstablishmentResponse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cause, _ := rsp.Cause.Cause(); cause != ie.CauseMandatoryIEMissing {
+		t.Errorf("got cause %d", cause)
+	}
+	if o, _ := rsp.OffendingIE.OffendingIE(); o != ie.NodeID {
+		t.Errorf("got offending IE %d", o)
+	}
+	if rsp.Sequence() != 1 {
+		t.Errorf("got sequence %d", rsp.Sequence())
+	}
+	if n := atomic.LoadInt32(&h.n); n != 0 {
+		t.Errorf("invalid request passed to handler %d times", n)
+	}
+}
+
+func TestConnVersionNotSupported(t *testing.T) {
+	h := &countingHandler{}
+	srv := listen(t, func(c *pfcp.Conn) {
+		c.Handler = h
+	})
+	pc, received := listenRaw(t)
+
+	req := message.NewHeartbeatRequest(0x1234, ie.NewRecoveryTimeStamp(ts), nil)
+	req.Header.Flags = 0x40 // version 2
+	b, err := req.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pc.WriteTo(b, srv.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case b = <-received:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for response")
+	}
+	rsp, err := message.Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := rsp.(*message.VersionNotSupportedResponse); !ok {
+		t.Fatalf("got unexpected response: %s", rsp.MessageTypeName())
+	}
+	if rsp.Sequence() != 0x1234 {
+		t.Errorf("got sequence %#x", rsp.Sequence())
+	}
+	if n := atomic.LoadInt32(&h.n); n != 0 {
+		t.Errorf("request of unsupported version passed to handler %d times", n)
+	}
+
+	// the peer does not support the version sent.
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := srv.Request(context.Background(), message.NewHeartbeatRequest(0, ie.NewRecoveryTimeStamp(ts), nil), pc.LocalAddr())
+		errCh <- err
+	}()
+
+	select {
+	case b = <-received:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for request")
+	}
+	hb, err := message.ParseHeartbeatRequest(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vnsr := message.NewVersionNotSupportedResponse(hb.Sequence())
+	vnsr.Header.Flags = 0x40
+	if b, err = vnsr.Marshal(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pc.WriteTo(b, srv.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-errCh; !errors.Is(err, pfcp.ErrVersionNotSupported) {
+		t.Errorf("got %v want %v", err, pfcp.ErrVersionNotSupported)
+	}
+}
+
+func TestConnFollowOn(t *testing.T) {
+	h := &countingHandler{}
+	srv := listen(t, func(c *pfcp.Conn) {
+		c.Handler = h
+	})
+	pc, received := listenRaw(t)
+
+	b, err := message.MarshalMulti(
+		message.NewSessionModificationRequest(0, 0, 1, 1, 0),
+		message.NewSessionModificationRequest(0, 0, 2, 2, 0),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pc.WriteTo(b, srv.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+
+	seqs := make(map[uint32]bool)
+	for i := 0; i < 2; i++ {
+		select {
+		case b := <-received:
+			rsp, err := message.Parse(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			seqs[rsp.Sequence()] = true
+		case <-time.After(time.Second):
+			t.Fatalf("got %d responses want 2", i)
+		}
+	}
+	if !seqs[1] || !seqs[2] {
+		t.Errorf("got responses to %v", seqs)
+	}
+}
+
+// orderHandler records the sequence numbers of the requests in the order
+// handled, blocking the first one until release is closed.
+type orderHandler struct {
+	started chan struct{}
+	release chan struct{}
+	seqs    chan uint32
+}
+
+func (h *orderHandler) ServePFCP(w pfcp.ResponseWriter, peer net.Addr, msg message.Message) {
+	if msg.Sequence() == 1 {
+		close(h.started)
+		<-h.release
+	}
+	h.seqs <- msg.Sequence()
+}
+
+// prioritized returns a SessionModificationRequest with the MP flag.
+func prioritized(seq uint32, pri uint8) message.Message {
+	m := message.NewSessionModificationRequest(0, 0, 1, seq, 0)
+	m.SetMP(pri)
+	return m
+}
+
+func TestConnPriorityQueue(t *testing.T) {
+	h := &orderHandler{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+		seqs:    make(chan uint32, 8),
+	}
+	srv := listen(t, func(c *pfcp.Conn) {
+		c.Handler = h
+		c.QueueSize = 2
+		c.QueueWorkers = 1
+	})
+	pc, _ := listenRaw(t)
+
+	send := func(msgs ...message.Message) {
+		t.Helper()
+		b, err := message.MarshalMulti(msgs...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := pc.WriteTo(b, srv.LocalAddr()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// occupies the only worker.
+	send(prioritized(1, 5))
+	select {
+	case <-h.started:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for first request")
+	}
+
+	send(
+		prioritized(2, 10),
+		prioritized(3, 1),
+		// evicts 2, which has the lowest priority.
+		prioritized(4, 3),
+		// dropped as it has the lowest priority.
+		prioritized(5, 12),
+	)
+	// wait for the datagram to be handled.
+	deadline := time.Now().Add(time.Second)
+	for srv.InboundQueueStats().Dropped[12] == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for requests to be queued")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(h.release)
+
+	for _, want := range []uint32{1, 3, 4} {
+		select {
+		case got := <-h.seqs:
+			if got != want {
+				t.Errorf("got SequenceNumber %d want %d", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for SequenceNumber %d", want)
+		}
+	}
+
+	stats := srv.InboundQueueStats()
+	for pri, want := range map[int]uint64{5: 0, 10: 1, 1: 0, 3: 0, 12: 1} {
+		if got := stats.Dropped[pri]; got != want {
+			t.Errorf("got %d dropped with priority %d want %d", got, pri, want)
+		}
+	}
+	for pri, want := range map[int]uint64{5: 1, 10: 1, 1: 1, 3: 1, 12: 0} {
+		if got := stats.Enqueued[pri]; got != want {
+			t.Errorf("got %d enqueued with priority %d want %d", got, pri, want)
+		}
+	}
+}
@@ -6,6 +6,15 @@
 // implemented in the Go Programming Language.
 //
 // THIS PROJECT IS STILL WIP.
-// It has the full definitions of messages and IEs as of V16.3.1(2020-04), TS29.244, but the
-// networking functionalities are not available at this moment.
+// It has the full definitions of messages and IEs as of V16.3.1(2020-04), TS29.244.
+// The networking functionalities are being added on top of Conn, which sends the requests
+// with retransmissions and matches the responses to them.
+//
+// Conn and the components working on it, e.g., Heartbeat, CPAssociationManager
+// and SessionRegistry, are created with NewXxx and configured with their
+// exported fields. The goroutines working on them read the fields without
+// locking, so they should be set before the component is put into use, i.e.,
+// before Conn.Serve, Heartbeat.AddPeer or CPAssociationManager.Setup is called,
+// or the component is registered as a Handler or set to a field of Conn, and
+// must not be changed after that.
 package pfcp
@@ -0,0 +1,218 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package pfcp
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// Error definitions.
+var (
+	ErrConnClosed = errors.New("use of closed PFCP connection")
+	ErrTimeout    = errors.New("timed out waiting for PFCP response")
+
+	ErrNoAssociation        = errors.New("no PFCP association established with the peer")
+	ErrAssociationReleasing = errors.New("PFCP association with the peer is being released")
+	ErrSessionNotFound      = errors.New("no PFCP session found with the SEID")
+
+	ErrMessageDropped      = errors.New("PFCP message dropped as queue is full")
+	ErrVersionNotSupported = errors.New("PFCP version not supported by the peer")
+	ErrThrottled           = errors.New("PFCP request throttled as peer is overloaded")
+)
+
+// Errors corresponding to the Causes of rejection in the responses.
+//
+// CauseError with the Cause matches the corresponding one with errors.Is.
+var (
+	ErrRequestRejected                 = errors.New("request rejected")
+	ErrSessionContextNotFound          = errors.New("session context not found")
+	ErrMandatoryIEMissing              = errors.New("mandatory IE missing")
+	ErrConditionalIEMissing            = errors.New("conditional IE missing")
+	ErrInvalidLength                   = errors.New("invalid length")
+	ErrMandatoryIEIncorrect            = errors.New("mandatory IE incorrect")
+	ErrInvalidForwardingPolicy         = errors.New("invalid forwarding policy")
+	ErrInvalidFTEIDAllocationOption    = errors.New("invalid F-TEID allocation option")
+	ErrNoEstablishedPFCPAssociation    = errors.New("no established PFCP association")
+	ErrRuleCreationModificationFailure = errors.New("rule creation/modification failure")
+	ErrPFCPEntityInCongestion          = errors.New("PFCP entity in congestion")
+	ErrNoResourcesAvailable            = errors.New("no resources available")
+	ErrServiceNotSupported             = errors.New("service not supported")
+	ErrSystemFailure                   = errors.New("system failure")
+	ErrRedirectionRequested            = errors.New("redirection requested")
+)
+
+var causeErrors = map[uint8]error{
+	ie.CauseRequestRejected:                 ErrRequestRejected,
+	ie.CauseSessionContextNotFound:          ErrSessionContextNotFound,
+	ie.CauseMandatoryIEMissing:              ErrMandatoryIEMissing,
+	ie.CauseConditionalIEMissing:            ErrConditionalIEMissing,
+	ie.CauseInvalidLength:                   ErrInvalidLength,
+	ie.CauseMandatoryIEIncorrect:            ErrMandatoryIEIncorrect,
+	ie.CauseInvalidForwardingPolicy:         ErrInvalidForwardingPolicy,
+	ie.CauseInvalidFTEIDAllocationOption:    ErrInvalidFTEIDAllocationOption,
+	ie.CauseNoEstablishedPFCPAssociation:    ErrNoEstablishedPFCPAssociation,
+	ie.CauseRuleCreationModificationFailure: ErrRuleCreationModificationFailure,
+	ie.CausePFCPEntityInCongestion:          ErrPFCPEntityInCongestion,
+	ie.CauseNoResourcesAvailable:            ErrNoResourcesAvailable,
+	ie.CauseServiceNotSupported:             ErrServiceNotSupported,
+	ie.CauseSystemFailure:                   ErrSystemFailure,
+	ie.CauseRedirectionRequested:            ErrRedirectionRequested,
+}
+
+// CauseName returns the name of cause, e.g., "session context not found".
+func CauseName(cause uint8) string {
+	if cause == ie.CauseRequestAccepted {
+		return "request accepted"
+	}
+	if err, ok := causeErrors[cause]; ok {
+		return err.Error()
+	}
+	return fmt.Sprintf("unknown cause %d", cause)
+}
+
+// InvalidMessageError indicates the message cannot be sent as a request,
+// as the sequence number cannot be set.
+type InvalidMessageError struct {
+	Type uint8
+}
+
+// Error returns message with the type of message given.
+func (e *InvalidMessageError) Error() string {
+	return fmt.Sprintf("cannot send message as a request: %d", e.Type)
+}
+
+// CauseError indicates the request is not accepted by the peer, with the
+// Cause and the related IEs in the response.
+//
+// Cause is 0 if the response has no valid Cause IE.
+type CauseError struct {
+	// MessageType is the type of the response.
+	MessageType uint8
+	Cause       uint8
+	// OffendingIE and FailedRuleID are the IEs in the response, if any.
+	OffendingIE  *ie.IE
+	FailedRuleID *ie.IE
+}
+
+// Error returns message with the type of response, the cause and the
+// related IEs in it.
+func (e *CauseError) Error() string {
+	if e.Cause == 0 {
+		return fmt.Sprintf("request rejected: no Cause in response %d", e.MessageType)
+	}
+
+	s := fmt.Sprintf("request rejected: response %d with Cause %d(%s)", e.MessageType, e.Cause, e.Name())
+	if e.OffendingIE != nil {
+		if t, err := e.OffendingIE.OffendingIE(); err == nil {
+			s += fmt.Sprintf(", OffendingIE=%d", t)
+		}
+	}
+	if e.FailedRuleID != nil {
+		typ, terr := e.FailedRuleID.RuleIDType()
+		id, ierr := e.FailedRuleID.FailedRuleID()
+		if terr == nil && ierr == nil {
+			s += fmt.Sprintf(", FailedRuleID=%d(type %d)", id, typ)
+		}
+	}
+	return s
+}
+
+// Name returns the name of the Cause.
+func (e *CauseError) Name() string {
+	return CauseName(e.Cause)
+}
+
+// Is reports whether target is the error corresponding to the Cause,
+// e.g., ErrSessionContextNotFound.
+func (e *CauseError) Is(target error) bool {
+	err, ok := causeErrors[e.Cause]
+	return ok && err == target
+}
+
+// ResponseError returns CauseError if the Cause in rsp is not "Request
+// accepted", or nil otherwise.
+//
+// It also returns nil for the messages that have no Cause, e.g.,
+// HeartbeatResponse and the requests.
+func ResponseError(rsp message.Message) error {
+	var cause, offending, failed *ie.IE
+	switch m := rsp.(type) {
+	case *message.PFDManagementResponse:
+		cause, offending = m.Cause, m.OffendingIE
+	case *message.AssociationSetupResponse:
+		cause = m.Cause
+	case *message.AssociationUpdateResponse:
+		cause = m.Cause
+	case *message.AssociationReleaseResponse:
+		cause = m.Cause
+	case *message.NodeReportResponse:
+		cause, offending = m.Cause, m.OffendingIE
+	case *message.SessionSetDeletionResponse:
+		cause, offending = m.Cause, m.OffendingIE
+	case *message.SessionEstablishmentResponse:
+		cause, offending, failed = m.Cause, m.OffendingIE, m.FailedRuleID
+	case *message.SessionModificationResponse:
+		cause, offending, failed = m.Cause, m.OffendingIE, m.FailedRuleID
+	case *message.SessionDeletionResponse:
+		cause, offending = m.Cause, m.OffendingIE
+	case *message.SessionReportResponse:
+		cause, offending = m.Cause, m.OffendingIE
+	default:
+		return nil
+	}
+
+	e := &CauseError{MessageType: rsp.MessageType(), OffendingIE: offending, FailedRuleID: failed}
+	if cause == nil {
+		return e
+	}
+	c, err := cause.Cause()
+	if err != nil {
+		return e
+	}
+	if c == ie.CauseRequestAccepted {
+		return nil
+	}
+	e.Cause = c
+	return e
+}
+
+// AssociationStateError indicates the operation is not allowed in the
+// current state of the association.
+type AssociationStateError struct {
+	NodeID string
+	State  AssociationState
+}
+
+// Error returns message with the NodeID and the state of the association.
+func (e *AssociationStateError) Error() string {
+	return fmt.Sprintf("association with %s is %s", e.NodeID, e.State)
+}
+
+// ThrottledError indicates the request is not sent to reduce the signalling
+// towards the peer in overload, as requested by OverloadControlInformation.
+//
+// It matches ErrThrottled with errors.Is.
+type ThrottledError struct {
+	NodeID string
+	// Reduction is the percentage of the requests to be throttled.
+	Reduction uint8
+	// Expires is when the overload control ends.
+	Expires time.Time
+}
+
+// Error returns message with the NodeID and the reduction.
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("request to %s throttled: peer in overload, reduction=%d%%", e.NodeID, e.Reduction)
+}
+
+// Is reports whether target is ErrThrottled.
+func (e *ThrottledError) Is(target error) bool {
+	return target == ErrThrottled
+}
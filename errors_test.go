@@ -0,0 +1,82 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package pfcp_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/wmnsk/go-pfcp"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+func TestResponseError(t *testing.T) {
+	cases := []struct {
+		description string
+		rsp         message.Message
+		want        error
+	}{
+		{
+			"Accepted",
+			message.NewSessionModificationResponse(0, 0, 1, 1, 0, ie.NewCause(ie.CauseRequestAccepted)),
+			nil,
+		}, {
+			"NoCauseInMessage",
+			message.NewHeartbeatResponse(1, ie.NewRecoveryTimeStamp(ts)),
+			nil,
+		}, {
+			"SessionContextNotFound",
+			message.NewSessionDeletionResponse(0, 0, 1, 1, 0, ie.NewCause(ie.CauseSessionContextNotFound)),
+			pfcp.ErrSessionContextNotFound,
+		}, {
+			"RuleCreationModificationFailure",
+			message.NewSessionEstablishmentResponse(0, 0, 1, 1, 0,
+				ie.NewCause(ie.CauseRuleCreationModificationFailure),
+				ie.NewFailedRuleID(ie.RuleIDTypeFAR, 2),
+			),
+			pfcp.ErrRuleCreationModificationFailure,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			err := pfcp.ResponseError(c.rsp)
+			if c.want == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			if !errors.Is(err, c.want) {
+				t.Fatalf("got %v want %v", err, c.want)
+			}
+			if errors.Is(err, pfcp.ErrRequestRejected) {
+				t.Errorf("%v matches %v", err, pfcp.ErrRequestRejected)
+			}
+
+			var cerr *pfcp.CauseError
+			if !errors.As(err, &cerr) {
+				t.Fatalf("got %T want *CauseError", err)
+			}
+			if cerr.MessageType != c.rsp.MessageType() {
+				t.Errorf("got message type %d want %d", cerr.MessageType, c.rsp.MessageType())
+			}
+		})
+	}
+
+	err := pfcp.ResponseError(message.NewSessionEstablishmentResponse(0, 0, 1, 1, 0,
+		ie.NewCause(ie.CauseRuleCreationModificationFailure),
+		ie.NewFailedRuleID(ie.RuleIDTypeFAR, 2),
+	))
+	var cerr *pfcp.CauseError
+	if !errors.As(err, &cerr) || cerr.FailedRuleID == nil {
+		t.Fatalf("got %v without FailedRuleID", err)
+	}
+	if id, _ := cerr.FailedRuleID.FailedRuleID(); id != 2 {
+		t.Errorf("got FailedRuleID %d", id)
+	}
+}
@@ -4,23 +4,24 @@
 
 // Command hb-client sends a HeartbeatRequest and checks response.
 //
-// Heartbeat exchanging feature is planned be included in the go-pfcp package's
-// built-in functions in the future.
+// To exchange the Heartbeat messages periodically, use pfcp.Heartbeat instead.
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"net"
 	"time"
 
+	"github.com/wmnsk/go-pfcp"
 	"github.com/wmnsk/go-pfcp/ie"
 	"github.com/wmnsk/go-pfcp/message"
 )
 
 func main() {
 	var (
-		server = flag.String("-s", "127.0.0.2:8805", "server's addr/port")
+		server = flag.String("s", "127.0.0.2:8805", "server's addr/port")
 	)
 	flag.Parse()
 
@@ -29,56 +30,41 @@ func main() {
 		log.Fatal(err)
 	}
 
-	conn, err := net.DialUDP("udp", nil, raddr)
+	conn, err := pfcp.Listen("udp", ":0")
 	if err != nil {
 		log.Fatal(err)
 	}
+	go func() {
+		if err := conn.Serve(); err != pfcp.ErrConnClosed {
+			log.Fatal(err)
+		}
+	}()
+	defer conn.Close()
 
-	var seq uint32 = 1
-	hbreq, err := message.NewHeartbeatRequest(
-		seq,
+	// Sequence Number is set by Conn.
+	hbreq := message.NewHeartbeatRequest(
+		0,
 		ie.NewRecoveryTimeStamp(time.Now()),
 		ie.NewSourceIPAddress(net.ParseIP("127.0.0.1"), net.ParseIP("2001::1"), 0),
-	).Marshal()
-	if err != nil {
-		log.Fatal(err)
-	}
+	)
 
-	if _, err := conn.Write(hbreq); err != nil {
-		log.Fatal(err)
-	}
-	log.Printf("sent Heartbeat Request to: %s", raddr)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
 
-	if err := conn.SetReadDeadline(time.Now().Add(3 * time.Second)); err != nil {
+	log.Printf("sending Heartbeat Request to: %s", raddr)
+	msg, err := conn.Request(ctx, hbreq, raddr)
+	if err != nil {
 		log.Fatal(err)
 	}
 
-	buf := make([]byte, 1500)
-	waiting := true
-	for waiting {
-		n, addr, err := conn.ReadFrom(buf)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		msg, err := message.Parse(buf[:n])
-		if err != nil {
-			log.Printf("ignored undecodable message: %x, error: %s", buf[:n], err)
-			continue
-		}
-
-		hbres, ok := msg.(*message.HeartbeatResponse)
-		if !ok {
-			log.Printf("got unexpected message: %s, from: %s", msg.MessageTypeName(), addr)
-			continue
-		}
+	hbres, ok := msg.(*message.HeartbeatResponse)
+	if !ok {
+		log.Fatalf("got unexpected message: %s, from: %s", msg.MessageTypeName(), raddr)
+	}
 
-		waiting = false
-		ts, err := hbres.RecoveryTimeStamp.RecoveryTimeStamp()
-		if err != nil {
-			log.Printf("got Heartbeat Response with invalid TS: %s, from: %s", err, addr)
-			break
-		}
-		log.Printf("got Heartbeat Response with TS: %s, from: %s", ts, addr)
+	ts, err := hbres.RecoveryTimeStamp.RecoveryTimeStamp()
+	if err != nil {
+		log.Fatalf("got Heartbeat Response with invalid TS: %s, from: %s", err, raddr)
 	}
+	log.Printf("got Heartbeat Response with TS: %s, from: %s", ts, raddr)
 }
@@ -0,0 +1,189 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package pfcp
+
+import (
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+// Features is the set of features available in a PFCP association.
+//
+// The UP function features are the ones advertised by the UP function, as the
+// CP function just decides whether to use them or not. The CP function
+// features are the ones advertised by the CP function, limited to the ones
+// supported by the UP function on the UP function side. The features that
+// both functions advertise, i.e., EPFAR, SSET, BUNDL and MPAS, are available
+// only if both of them do.
+type Features struct {
+	up *ie.IE
+	cp *ie.IE
+}
+
+// newFeatures creates Features from the UPFunctionFeatures IE(can be nil) and
+// the CP function features in the first octet of CPFunctionFeatures IE.
+func newFeatures(up *ie.IE, cp uint8) Features {
+	if up == nil {
+		up = ie.NewUPFunctionFeatures()
+	}
+	return Features{up: up, cp: ie.NewCPFunctionFeatures(cp)}
+}
+
+// cpFeatures returns the first octet of CPFunctionFeatures IE, or 0 if i is
+// nil or invalid.
+func cpFeatures(i *ie.IE) uint8 {
+	if i == nil {
+		return 0
+	}
+	f, err := i.CPFunctionFeatures()
+	if err != nil {
+		return 0
+	}
+	return f
+}
+
+// HasBUCP reports whether BUCP feature is available.
+func (f Features) HasBUCP() bool {
+	return f.up != nil && f.up.HasBUCP()
+}
+
+// HasDDND reports whether DDND feature is available.
+func (f Features) HasDDND() bool {
+	return f.up != nil && f.up.HasDDND()
+}
+
+// HasDLBD reports whether DLBD feature is available.
+func (f Features) HasDLBD() bool {
+	return f.up != nil && f.up.HasDLBD()
+}
+
+// HasTRST reports whether TRST feature is available.
+func (f Features) HasTRST() bool {
+	return f.up != nil && f.up.HasTRST()
+}
+
+// HasFTUP reports whether FTUP feature is available.
+func (f Features) HasFTUP() bool {
+	return f.up != nil && f.up.HasFTUP()
+}
+
+// HasPFDM reports whether PFDM feature is available.
+func (f Features) HasPFDM() bool {
+	return f.up != nil && f.up.HasPFDM()
+}
+
+// HasHEEU reports whether HEEU feature is available.
+func (f Features) HasHEEU() bool {
+	return f.up != nil && f.up.HasHEEU()
+}
+
+// HasTREU reports whether TREU feature is available.
+func (f Features) HasTREU() bool {
+	return f.up != nil && f.up.HasTREU()
+}
+
+// HasEMPU reports whether EMPU feature is available.
+func (f Features) HasEMPU() bool {
+	return f.up != nil && f.up.HasEMPU()
+}
+
+// HasPDIU reports whether PDIU feature is available.
+func (f Features) HasPDIU() bool {
+	return f.up != nil && f.up.HasPDIU()
+}
+
+// HasUDBC reports whether UDBC feature is available.
+func (f Features) HasUDBC() bool {
+	return f.up != nil && f.up.HasUDBC()
+}
+
+// HasQUOAC reports whether QUOAC feature is available.
+func (f Features) HasQUOAC() bool {
+	return f.up != nil && f.up.HasQUOAC()
+}
+
+// HasTRACE reports whether TRACE feature is available.
+func (f Features) HasTRACE() bool {
+	return f.up != nil && f.up.HasTRACE()
+}
+
+// HasFRRT reports whether FRRT feature is available.
+func (f Features) HasFRRT() bool {
+	return f.up != nil && f.up.HasFRRT()
+}
+
+// HasPFDE reports whether PFDE feature is available.
+func (f Features) HasPFDE() bool {
+	return f.up != nil && f.up.HasPFDE()
+}
+
+// HasEPFAR reports whether EPFAR feature is available.
+func (f Features) HasEPFAR() bool {
+	return f.up != nil && f.up.HasEPFAR() && f.cp != nil && f.cp.HasEPFAR()
+}
+
+// HasDPDRA reports whether DPDRA feature is available.
+func (f Features) HasDPDRA() bool {
+	return f.up != nil && f.up.HasDPDRA()
+}
+
+// HasADPDP reports whether ADPDP feature is available.
+func (f Features) HasADPDP() bool {
+	return f.up != nil && f.up.HasADPDP()
+}
+
+// HasUEIP reports whether UEIP feature is available.
+func (f Features) HasUEIP() bool {
+	return f.up != nil && f.up.HasUEIP()
+}
+
+// HasSSET reports whether SSET feature is available.
+func (f Features) HasSSET() bool {
+	return f.up != nil && f.up.HasSSET() && f.cp != nil && f.cp.HasSSET()
+}
+
+// HasMNOP reports whether MNOP feature is available.
+func (f Features) HasMNOP() bool {
+	return f.up != nil && f.up.HasMNOP()
+}
+
+// HasMTE reports whether MTE feature is available.
+func (f Features) HasMTE() bool {
+	return f.up != nil && f.up.HasMTE()
+}
+
+// HasBUNDL reports whether BUNDL feature is available.
+func (f Features) HasBUNDL() bool {
+	return f.up != nil && f.up.HasBUNDL() && f.cp != nil && f.cp.HasBUNDL()
+}
+
+// HasGCOM reports whether GCOM feature is available.
+func (f Features) HasGCOM() bool {
+	return f.up != nil && f.up.HasGCOM()
+}
+
+// HasMPAS reports whether MPAS feature is available.
+func (f Features) HasMPAS() bool {
+	return f.up != nil && f.up.HasMPAS() && f.cp != nil && f.cp.HasMPAS()
+}
+
+// HasRTTL reports whether RTTL feature is available.
+func (f Features) HasRTTL() bool {
+	return f.up != nil && f.up.HasRTTL()
+}
+
+// HasVTIME reports whether VTIME feature is available.
+func (f Features) HasVTIME() bool {
+	return f.up != nil && f.up.HasVTIME()
+}
+
+// HasLOAD reports whether LOAD feature is available.
+func (f Features) HasLOAD() bool {
+	return f.cp != nil && f.cp.HasLOAD()
+}
+
+// HasOVRL reports whether OVRL feature is available.
+func (f Features) HasOVRL() bool {
+	return f.cp != nil && f.cp.HasOVRL()
+}
@@ -0,0 +1,107 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package pfcp
+
+import (
+	"net"
+
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// Handler responds to a PFCP request received by Conn.
+//
+// ServePFCP is called in its own goroutine for each request, or in one of
+// the workers of the inbound queue if Conn.QueueSize is set. msg is the
+// typed message returned by message.Parse, and peer is the address of the
+// node that sent it.
+type Handler interface {
+	ServePFCP(w ResponseWriter, peer net.Addr, msg message.Message)
+}
+
+// HandlerFunc is an adapter to allow the use of ordinary functions as Handler.
+type HandlerFunc func(w ResponseWriter, peer net.Addr, msg message.Message)
+
+// ServePFCP calls f(w, peer, msg).
+func (f HandlerFunc) ServePFCP(w ResponseWriter, peer net.Addr, msg message.Message) {
+	f(w, peer, msg)
+}
+
+// ResponseWriter is used by a Handler to send the response to a request.
+type ResponseWriter interface {
+	// WriteMessage sends msg to the peer the request came from.
+	//
+	// The sequence number of msg is set to the one of the request. The SEID of
+	// a session related msg is set to the SEID allocated by the peer when it
+	// is known: the one in CP F-SEID of SessionEstablishmentRequest, or the
+	// remote SEID of the session that SessionRegistry routes the request to.
+	// Otherwise, the SEID set by the Handler is sent as it is.
+	WriteMessage(msg message.Message) error
+}
+
+// response is the ResponseWriter given to the Handler by Conn.
+type response struct {
+	conn *Conn
+	peer net.Addr
+	req  message.Message
+	key  requestKey
+}
+
+// log returns the Logger of the Conn.
+func (r *response) log() Logger {
+	return r.conn.log()
+}
+
+// WriteMessage sends msg to the peer the request came from.
+//
+// The serialized msg is kept in the response cache of Conn, to be sent again
+// to the retransmitted request. It has the same priority as the request in
+// the outbound queue.
+func (r *response) WriteMessage(msg message.Message) error {
+	if h, ok := msg.(interface{ SetSequenceNumber(uint32) }); ok {
+		h.SetSequenceNumber(r.req.Sequence())
+	}
+	if h, ok := msg.(interface {
+		HasSEID() bool
+		SetSEID(uint64)
+	}); ok && h.HasSEID() {
+		if seid, ok := peerSEID(r.req); ok {
+			h.SetSEID(seid)
+		}
+	}
+
+	b, err := marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	if l := r.conn.ResponseCacheLifetime; l > 0 {
+		r.conn.cache.store(r.key, &cachedResponse{
+			b:       b,
+			name:    msg.MessageTypeName(),
+			cause:   causeOf(msg),
+			expires: r.conn.clock().Now().Add(l),
+		})
+	}
+
+	if err := r.conn.send(b, priorityOf(r.req, r.conn.DefaultPriority), r.peer); err != nil {
+		return err
+	}
+	r.conn.metrics().MessageSent(msg.MessageTypeName(), causeOf(msg))
+	return nil
+}
+
+// peerSEID returns the SEID assigned by the peer that sent req, if req has it.
+func peerSEID(req message.Message) (uint64, bool) {
+	m, ok := req.(*message.SessionEstablishmentRequest)
+	if !ok || m.CPFSEID == nil {
+		return 0, false
+	}
+
+	f, err := m.CPFSEID.FSEID()
+	if err != nil {
+		return 0, false
+	}
+	return f.SEID, true
+}
@@ -0,0 +1,218 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package pfcp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// DefaultHeartbeatInterval is the default interval of HeartbeatRequests.
+const DefaultHeartbeatInterval = 10 * time.Second
+
+// Heartbeat runs the Heartbeat procedure defined in TS 29.244 §6.2.2.
+//
+// Heartbeat sends HeartbeatRequest to each peer added with AddPeer at every
+// Interval, and answers the HeartbeatRequests from the peers when it is
+// registered as a Handler, e.g., with ServeMux.Handle(message.MsgTypeHeartbeatRequest, hb).
+//
+// The RecoveryTimeStamp received from a peer is compared with the last one
+// seen, and OnPeerRestarted is called when it changes. OnPeerUnreachable is
+// called when MaxFailures HeartbeatRequests in a row are left unanswered,
+// so that the sessions with the peer can be torn down as required in §6.2.6.
+type Heartbeat struct {
+	// Interval is the interval of HeartbeatRequests sent to each peer.
+	Interval time.Duration
+	// MaxFailures is the number of consecutive HeartbeatRequests that failed
+	// (i.e., not answered after N1 retransmissions) to consider the peer unreachable.
+	MaxFailures int
+	// OnPeerRestarted is called with the new RecoveryTimeStamp when the peer
+	// is found to be restarted.
+	OnPeerRestarted func(peer net.Addr, ts time.Time)
+	// OnPeerUnreachable is called when the peer is found to be unreachable.
+	// It is called again only after the peer answers a HeartbeatRequest.
+	OnPeerUnreachable func(peer net.Addr)
+
+	conn *Conn
+
+	mu    sync.Mutex
+	peers map[string]*heartbeatPeer
+	seen  map[string]time.Time
+}
+
+type heartbeatPeer struct {
+	addr     net.Addr
+	cancel   context.CancelFunc
+	failures int
+}
+
+// NewHeartbeat creates a new Heartbeat that works on c.
+//
+// Interval is set to DefaultHeartbeatInterval, and MaxFailures to N1 of c
+// (or 1 if N1 is 0).
+func NewHeartbeat(c *Conn) *Heartbeat {
+	n := c.N1
+	if n < 1 {
+		n = 1
+	}
+
+	return &Heartbeat{
+		Interval:    DefaultHeartbeatInterval,
+		MaxFailures: n,
+		conn:        c,
+		peers:       make(map[string]*heartbeatPeer),
+		seen:        make(map[string]time.Time),
+	}
+}
+
+// AddPeer starts sending HeartbeatRequests to peer.
+//
+// It does nothing if peer is already added.
+func (h *Heartbeat) AddPeer(peer net.Addr) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := peer.String()
+	if _, ok := h.peers[key]; ok {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &heartbeatPeer{addr: peer, cancel: cancel}
+	h.peers[key] = p
+
+	go h.run(ctx, p)
+}
+
+// RemovePeer stops sending HeartbeatRequests to peer, and forgets the
+// RecoveryTimeStamp of it.
+func (h *Heartbeat) RemovePeer(peer net.Addr) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := peer.String()
+	if p, ok := h.peers[key]; ok {
+		p.cancel()
+		delete(h.peers, key)
+	}
+	delete(h.seen, key)
+}
+
+// Stop stops sending HeartbeatRequests to all the peers.
+func (h *Heartbeat) Stop() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for key, p := range h.peers {
+		p.cancel()
+		delete(h.peers, key)
+	}
+}
+
+// RecoveryTimeStamp returns the last RecoveryTimeStamp received from peer.
+func (h *Heartbeat) RecoveryTimeStamp(peer net.Addr) (time.Time, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ts, ok := h.seen[peer.String()]
+	return ts, ok
+}
+
+// ServePFCP answers a HeartbeatRequest with the RecoveryTimeStamp of the
+// Conn, and checks the RecoveryTimeStamp of the peer in it.
+func (h *Heartbeat) ServePFCP(w ResponseWriter, peer net.Addr, msg message.Message) {
+	req, ok := msg.(*message.HeartbeatRequest)
+	if !ok {
+		loggerOf(w).Log(LogLevelDebug, "ignored message: not a Heartbeat Request", "peer", peer, "type", msg.MessageTypeName())
+		return
+	}
+
+	if err := w.WriteMessage(message.NewHeartbeatResponse(0, ie.NewRecoveryTimeStamp(h.conn.RecoveryTimeStamp))); err != nil {
+		loggerOf(w).Log(LogLevelWarn, "failed to respond to Heartbeat Request", "peer", peer, "error", err)
+	}
+
+	if req.RecoveryTimeStamp != nil {
+		h.checkRecoveryTimeStamp(peer, req.RecoveryTimeStamp)
+	}
+}
+
+func (h *Heartbeat) run(ctx context.Context, p *heartbeatPeer) {
+	clock := h.conn.clock()
+	next := clock.Now()
+	for {
+		// keep the interval regardless of the time taken by the request, but
+		// don't send the missed ones in a burst.
+		now := clock.Now()
+		next = next.Add(h.Interval)
+		if next.Before(now) {
+			next = now
+		}
+
+		t := clock.NewTimer(next.Sub(now))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C():
+		}
+
+		req := message.NewHeartbeatRequest(0, ie.NewRecoveryTimeStamp(h.conn.RecoveryTimeStamp), nil)
+		rsp, err := h.conn.Request(ctx, req, p.addr)
+		if err != nil {
+			// the peer is not to blame when the Conn is closed.
+			if ctx.Err() != nil || errors.Is(err, ErrConnClosed) {
+				return
+			}
+			h.fail(p, err)
+			continue
+		}
+
+		h.mu.Lock()
+		p.failures = 0
+		h.mu.Unlock()
+
+		hbrsp, ok := rsp.(*message.HeartbeatResponse)
+		if !ok || hbrsp.RecoveryTimeStamp == nil {
+			h.conn.log().Log(LogLevelWarn, "got invalid response to Heartbeat Request", "peer", p.addr, "type", rsp.MessageTypeName())
+			continue
+		}
+		h.checkRecoveryTimeStamp(p.addr, hbrsp.RecoveryTimeStamp)
+	}
+}
+
+func (h *Heartbeat) fail(p *heartbeatPeer, err error) {
+	h.mu.Lock()
+	p.failures++
+	n := p.failures
+	h.mu.Unlock()
+
+	h.conn.log().Log(LogLevelWarn, "Heartbeat Request failed", "peer", p.addr, "failures", n, "error", err)
+	if n == h.MaxFailures && h.OnPeerUnreachable != nil {
+		h.OnPeerUnreachable(p.addr)
+	}
+}
+
+func (h *Heartbeat) checkRecoveryTimeStamp(peer net.Addr, i *ie.IE) {
+	ts, err := i.RecoveryTimeStamp()
+	if err != nil {
+		h.conn.log().Log(LogLevelWarn, "got invalid RecoveryTimeStamp", "peer", peer, "error", err)
+		return
+	}
+
+	h.mu.Lock()
+	last, ok := h.seen[peer.String()]
+	h.seen[peer.String()] = ts
+	h.mu.Unlock()
+
+	if ok && !ts.Equal(last) && h.OnPeerRestarted != nil {
+		h.OnPeerRestarted(peer, ts)
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package pfcp_test
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wmnsk/go-pfcp"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+	"github.com/wmnsk/go-pfcp/pfcptest"
+)
+
+func TestHeartbeatResponds(t *testing.T) {
+	var hb *pfcp.Heartbeat
+	srv := listen(t, func(c *pfcp.Conn) {
+		c.RecoveryTimeStamp = ts
+		hb = pfcp.NewHeartbeat(c)
+		c.Handler = hb
+	})
+	cli := listen(t, nil)
+
+	rsp, err := cli.Request(context.Background(), message.NewHeartbeatRequest(0, ie.NewRecoveryTimeStamp(ts.Add(time.Hour)), nil), srv.LocalAddr())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := rsp.(*message.HeartbeatResponse).RecoveryTimeStamp.RecoveryTimeStamp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(ts) {
+		t.Errorf("got RecoveryTimeStamp %s want %s", got, ts)
+	}
+
+	seen, ok := hb.RecoveryTimeStamp(cli.LocalAddr())
+	if !ok || !seen.Equal(ts.Add(time.Hour)) {
+		t.Errorf("got RecoveryTimeStamp of peer %s want %s", seen, ts.Add(time.Hour))
+	}
+}
+
+func TestHeartbeatPeerRestarted(t *testing.T) {
+	pc, received := listenRaw(t)
+
+	restarted := make(chan time.Time, 1)
+	var hb *pfcp.Heartbeat
+	cli := listen(t, func(c *pfcp.Conn) {
+		hb = pfcp.NewHeartbeat(c)
+		hb.Interval = 10 * time.Millisecond
+		hb.OnPeerRestarted = func(peer net.Addr, ts time.Time) {
+			restarted <- ts
+		}
+	})
+	hb.AddPeer(pc.LocalAddr())
+	defer hb.Stop()
+
+	// the peer restarts after answering the first request.
+	for i, peerTS := range []time.Time{ts, ts.Add(time.Minute)} {
+		b := <-received
+		req, err := message.ParseHeartbeatRequest(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rsp, err := message.NewHeartbeatResponse(req.Sequence(), ie.NewRecoveryTimeStamp(peerTS)).Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := pc.WriteTo(rsp, cli.LocalAddr()); err != nil {
+			t.Fatal(err)
+		}
+
+		if i == 0 {
+			select {
+			case <-restarted:
+				t.Fatal("got restart event at the first response")
+			case <-time.After(20 * time.Millisecond):
+			}
+		}
+	}
+
+	select {
+	case got := <-restarted:
+		if want := ts.Add(time.Minute); !got.Equal(want) {
+			t.Errorf("got RecoveryTimeStamp %s want %s", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for restart event")
+	}
+}
+
+func TestHeartbeatPeerUnreachable(t *testing.T) {
+	pc, _ := listenRaw(t)
+
+	unreachable := make(chan net.Addr, 1)
+	var hb *pfcp.Heartbeat
+	listen(t, func(c *pfcp.Conn) {
+		c.T1 = 10 * time.Millisecond
+		c.N1 = 1
+
+		hb = pfcp.NewHeartbeat(c)
+		hb.Interval = 10 * time.Millisecond
+		hb.MaxFailures = 2
+		hb.OnPeerUnreachable = func(peer net.Addr) {
+			unreachable <- peer
+		}
+	})
+	hb.AddPeer(pc.LocalAddr())
+	defer hb.Stop()
+
+	select {
+	case got := <-unreachable:
+		if got.String() != pc.LocalAddr().String() {
+			t.Errorf("got %s want %s", got, pc.LocalAddr())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for unreachable event")
+	}
+}
+
+func TestHeartbeatClock(t *testing.T) {
+	clock := pfcptest.NewClock(ts)
+	nw := pfcptest.NewNetwork(1)
+
+	received := make(chan struct{}, 1)
+	srvPC, err := nw.Listen("127.0.0.1:8805")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := pfcp.NewConn(srvPC)
+	srv.Handler = pfcp.HandlerFunc(func(w pfcp.ResponseWriter, peer net.Addr, msg message.Message) {
+		_ = w.WriteMessage(message.NewHeartbeatResponse(0, ie.NewRecoveryTimeStamp(ts)))
+		received <- struct{}{}
+	})
+	go func() { _ = srv.Serve() }()
+	defer srv.Close()
+
+	cliPC, err := nw.Listen("127.0.0.2:8805")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli := pfcp.NewConn(cliPC)
+	cli.Clock = clock
+	cli.T1 = time.Hour // never retransmits in the test.
+	go func() { _ = cli.Serve() }()
+	defer cli.Close()
+
+	hb := pfcp.NewHeartbeat(cli)
+	hb.Interval = time.Minute
+	hb.AddPeer(srvPC.LocalAddr())
+	defer hb.Stop()
+
+	for i := 0; i < 2; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(hb.Interval - time.Second)
+		select {
+		case <-received:
+			t.Fatal("got Heartbeat Request before Interval")
+		default:
+		}
+
+		clock.Advance(time.Second)
+		select {
+		case <-received:
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for Heartbeat Request")
+		}
+	}
+}
+
+func TestHeartbeatConnClosed(t *testing.T) {
+	clock := pfcptest.NewClock(ts)
+	nw := pfcptest.NewNetwork(1)
+
+	srvPC, err := nw.Listen("127.0.0.1:8805")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cliPC, err := nw.Listen("127.0.0.2:8805")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli := pfcp.NewConn(cliPC)
+	cli.Clock = clock
+	go func() { _ = cli.Serve() }()
+
+	var unreachable int32
+	hb := pfcp.NewHeartbeat(cli)
+	hb.Interval = time.Minute
+	hb.MaxFailures = 1
+	hb.OnPeerUnreachable = func(peer net.Addr) {
+		atomic.AddInt32(&unreachable, 1)
+	}
+	hb.AddPeer(srvPC.LocalAddr())
+	defer hb.Stop()
+
+	clock.BlockUntil(1)
+	if err := cli.Close(); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(hb.Interval)
+
+	// give the goroutine time to send the request, after which it must exit
+	// without starting the timer for the next one.
+	time.Sleep(100 * time.Millisecond)
+	if n := clock.Pending(); n != 0 {
+		t.Errorf("got %d timers pending after Close", n)
+	}
+	if n := atomic.LoadInt32(&unreachable); n != 0 {
+		t.Errorf("OnPeerUnreachable called %d times after Close", n)
+	}
+}
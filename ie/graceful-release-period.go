@@ -6,11 +6,12 @@ package ie
 
 import (
 	"io"
-	"math"
 	"time"
 )
 
 // NewGracefulReleasePeriod creates a new GracefulReleasePeriod IE.
+//
+// The duration is encoded in the same way as NewTimer.
 func NewGracefulReleasePeriod(duration time.Duration) *IE {
 	// 8.2.78 Graceful Release Period
 	// Timer unit
@@ -26,29 +27,7 @@ func NewGracefulReleasePeriod(duration time.Duration) *IE {
 	// Other values shall be interpreted as multiples of 1 minute in this version of the protocol.
 	// Timer unit and Timer value both set to all "zeros" shall be interpreted as an indication that the timer is stopped.
 
-	var unit, value uint8
-	switch {
-	case duration%(10*time.Hour) == 0:
-		unit = 0x80
-		value = uint8(duration / (10 * time.Hour))
-	case duration%(1*time.Hour) == 0:
-		unit = 0x60
-		value = uint8(duration / time.Hour)
-	case duration%(10*time.Minute) == 0:
-		unit = 0x40
-		value = uint8(duration / (10 * time.Minute))
-	case duration%(1*time.Minute) == 0:
-		unit = 0x20
-		value = uint8(duration / time.Minute)
-	case duration%(2*time.Second) == 0:
-		unit = 0x00
-		value = uint8(duration / (2 * time.Second))
-	default:
-		unit = 0xe0
-		value = 0
-	}
-
-	return newUint8ValIE(GracefulReleasePeriod, unit+(value&0x1f))
+	return newUint8ValIE(GracefulReleasePeriod, encodeTimer(duration))
 }
 
 // GracefulReleasePeriod returns GracefulReleasePeriod in time.Duration if the type of IE matches.
@@ -60,23 +39,5 @@ func (i *IE) GracefulReleasePeriod() (time.Duration, error) {
 		return 0, io.ErrUnexpectedEOF
 	}
 
-	var d time.Duration
-	switch i.Payload[0] | 0xe0 {
-	case 0xe0:
-		d = time.Duration(math.MaxInt64)
-	case 0x80:
-		d = time.Duration(i.Payload[0]|0x1f) * 10 * time.Hour
-	case 0x60:
-		d = time.Duration(i.Payload[0]|0x1f) * time.Hour
-	case 0x40:
-		d = time.Duration(i.Payload[0]|0x1f) * 10 * time.Minute
-	case 0x20:
-		d = time.Duration(i.Payload[0]|0x1f) * time.Minute
-	case 0x00:
-		d = time.Duration(i.Payload[0]|0x1f) * 2 * time.Second
-	default:
-		d = 0
-	}
-
-	return d, nil
+	return decodeTimer(i.Payload[0]), nil
 }
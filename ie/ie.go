@@ -342,8 +342,16 @@ func (i *IE) UnmarshalBinary(b []byte) error {
 	if l <= offset {
 		return nil
 	}
+	// Length includes the Enterprise ID in vendor-specific IEs.
+	end := 4 + int(i.Length)
+	if end < offset {
+		return ErrInvalidLength
+	}
+	if l < end {
+		return io.ErrUnexpectedEOF
+	}
 
-	i.Payload = b[offset : offset+int(i.Length)]
+	i.Payload = b[offset:end]
 
 	if i.IsGrouped() {
 		var err error
@@ -570,7 +578,7 @@ func newGroupedIE(itype, eid uint16, ies ...*IE) *IE {
 
 		serialized, err := ie.Marshal()
 		if err != nil {
-			logger.Logf("newGroupedIE() failed to marshal an IE(Type=%d): %v", ie.Type, err)
+			logger.Log(logger.LevelWarn, "newGroupedIE() failed to marshal an IE", "type", itype, "ie", ie.Type, "offset", len(i.Payload), "error", err)
 			return nil
 		}
 		i.Payload = append(i.Payload, serialized...)
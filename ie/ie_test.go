@@ -5,6 +5,9 @@
 package ie_test
 
 import (
+	"errors"
+	"io"
+	"math"
 	"net"
 	"testing"
 	"time"
@@ -1089,6 +1092,22 @@ func TestIEs(t *testing.T) {
 			"Timer/15min",
 			ie.NewTimer(15 * time.Minute),
 			[]byte{0x00, 0x37, 0x00, 0x01, 0x2f},
+		}, {
+			"Timer/90sec",
+			ie.NewTimer(90 * time.Second),
+			[]byte{0x00, 0x37, 0x00, 0x01, 0x22},
+		}, {
+			"Timer/1sec",
+			ie.NewTimer(time.Second),
+			[]byte{0x00, 0x37, 0x00, 0x01, 0x01},
+		}, {
+			"Timer/stopped",
+			ie.NewTimer(0),
+			[]byte{0x00, 0x37, 0x00, 0x01, 0x00},
+		}, {
+			"Timer/infinite",
+			ie.NewTimer(311 * time.Hour),
+			[]byte{0x00, 0x37, 0x00, 0x01, 0xe0},
 		}, {
 			"PDRID",
 			ie.NewPDRID(0xffff),
@@ -1703,6 +1722,14 @@ func TestIEs(t *testing.T) {
 			"GracefulReleasePeriod/15min",
 			ie.NewGracefulReleasePeriod(15 * time.Minute),
 			[]byte{0x00, 0x70, 0x00, 0x01, 0x2f},
+		}, {
+			"GracefulReleasePeriod/90sec",
+			ie.NewGracefulReleasePeriod(90 * time.Second),
+			[]byte{0x00, 0x70, 0x00, 0x01, 0x22},
+		}, {
+			"GracefulReleasePeriod/stopped",
+			ie.NewGracefulReleasePeriod(0),
+			[]byte{0x00, 0x70, 0x00, 0x01, 0x00},
 		}, {
 			"PDNType",
 			ie.NewPDNType(ie.PDNTypeIPv4),
@@ -1719,6 +1746,14 @@ func TestIEs(t *testing.T) {
 			"FailedRuleID/BAR",
 			ie.NewFailedRuleID(ie.RuleIDTypeBAR, 0xff),
 			[]byte{0x00, 0x72, 0x00, 0x02, 0x04, 0xff},
+		}, {
+			"FailedRuleID/MAR",
+			ie.NewFailedRuleID(ie.RuleIDTypeMAR, 0xffff),
+			[]byte{0x00, 0x72, 0x00, 0x03, 0x05, 0xff, 0xff},
+		}, {
+			"FailedRuleID/SRR",
+			ie.NewFailedRuleID(ie.RuleIDTypeSRR, 0xff),
+			[]byte{0x00, 0x72, 0x00, 0x02, 0x06, 0xff},
 		}, {
 			"TimeQuotaMechanism",
 			ie.NewTimeQuotaMechanism(ie.BTITCTP, 10*time.Second),
@@ -3350,3 +3385,73 @@ func TestIEs(t *testing.T) {
 		})
 	}
 }
+
+func TestTimerValue(t *testing.T) {
+	cases := []struct {
+		description string
+		b           byte
+		want        time.Duration
+	}{
+		{"stopped", 0x00, 0},
+		{"2sec", 0x0f, 30 * time.Second},
+		{"1min", 0x22, 2 * time.Minute},
+		{"10min", 0x41, 10 * time.Minute},
+		{"1hr", 0x61, time.Hour},
+		{"10hr", 0x82, 20 * time.Hour},
+		{"other", 0xa3, 3 * time.Minute},
+		{"infinite", 0xe0, time.Duration(math.MaxInt64)},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			got, err := ie.New(ie.Timer, []byte{c.b}).Timer()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != c.want {
+				t.Errorf("got Timer %s want %s", got, c.want)
+			}
+
+			got, err = ie.New(ie.GracefulReleasePeriod, []byte{c.b}).GracefulReleasePeriod()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != c.want {
+				t.Errorf("got GracefulReleasePeriod %s want %s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestForwardingPolicyIdentifier(t *testing.T) {
+	cases := []struct {
+		description string
+		i           *ie.IE
+		want        string
+		err         error
+	}{
+		{"ForwardingPolicy", ie.NewForwardingPolicy("go-pfcp"), "go-pfcp", nil},
+		{"OneOctet", ie.NewForwardingPolicy("a"), "a", nil},
+		{"Empty", ie.NewForwardingPolicy(""), "", nil},
+		{"TrailingOctets", ie.New(ie.ForwardingPolicy, []byte{0x02, 'g', 'o', '-'}), "go", nil},
+		{"Truncated", ie.New(ie.ForwardingPolicy, []byte{0x07, 'g', 'o'}), "", io.ErrUnexpectedEOF},
+		{"NoLength", ie.New(ie.ForwardingPolicy, nil), "", io.ErrUnexpectedEOF},
+		{
+			"ForwardingParameters",
+			ie.NewForwardingParameters(ie.NewForwardingPolicy("go-pfcp")),
+			"go-pfcp", nil,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			got, err := c.i.ForwardingPolicyIdentifier()
+			if !errors.Is(err, c.err) {
+				t.Fatalf("got error %v want %v", err, c.err)
+			}
+			if got != c.want {
+				t.Errorf("got %q want %q", got, c.want)
+			}
+		})
+	}
+}
@@ -37,3 +37,14 @@ func (i *IE) OCIFlags() (uint8, error) {
 		return 0, &InvalidTypeError{Type: i.Type}
 	}
 }
+
+// HasAOCI reports whether an IE has AOCI bit, which indicates the Overload
+// Control Information is associated with the Node ID of the sender.
+func (i *IE) HasAOCI() bool {
+	v, err := i.OCIFlags()
+	if err != nil {
+		return false
+	}
+
+	return has1stBit(v)
+}
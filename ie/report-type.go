@@ -4,6 +4,8 @@
 
 package ie
 
+import "io"
+
 // NewReportType creates a new ReportType IE.
 func NewReportType(upir, erir, usar, dldr int) *IE {
 	return newUint8ValIE(ReportType, uint8((upir<<3)|(erir<<2)|(usar<<1)|(dldr)))
@@ -14,6 +16,9 @@ func (i *IE) ReportType() (uint8, error) {
 	if i.Type != ReportType {
 		return 0, &InvalidTypeError{Type: i.Type}
 	}
+	if len(i.Payload) < 1 {
+		return 0, io.ErrUnexpectedEOF
+	}
 
 	return i.Payload[0], nil
 }
@@ -11,6 +11,11 @@ import (
 )
 
 // NewTimer creates a new Timer IE.
+//
+// The duration is encoded with the largest unit that represents it exactly,
+// or rounded up to the smallest unit that can hold it, e.g., 90 seconds is
+// encoded as 2 minutes. 0 or less is encoded as the timer stopped (all zeros),
+// and the duration longer than 310 hours as infinite.
 func NewTimer(duration time.Duration) *IE {
 	// 8.2.35 Timer
 	// Timer unit
@@ -26,29 +31,7 @@ func NewTimer(duration time.Duration) *IE {
 	// Other values shall be interpreted as multiples of 1 minute in this version of the protocol.
 	// Timer unit and Timer value both set to all "zeros" shall be interpreted as an indication that the timer is stopped.
 
-	var unit, value uint8
-	switch {
-	case duration%(10*time.Hour) == 0:
-		unit = 0x80
-		value = uint8(duration / (10 * time.Hour))
-	case duration%(1*time.Hour) == 0:
-		unit = 0x60
-		value = uint8(duration / time.Hour)
-	case duration%(10*time.Minute) == 0:
-		unit = 0x40
-		value = uint8(duration / (10 * time.Minute))
-	case duration%(1*time.Minute) == 0:
-		unit = 0x20
-		value = uint8(duration / time.Minute)
-	case duration%(2*time.Second) == 0:
-		unit = 0x00
-		value = uint8(duration / (2 * time.Second))
-	default:
-		unit = 0xe0
-		value = 0
-	}
-
-	return newUint8ValIE(Timer, unit+(value&0x1f))
+	return newUint8ValIE(Timer, encodeTimer(duration))
 }
 
 // Timer returns Timer in time.Duration if the type of IE matches.
@@ -59,24 +42,7 @@ func (i *IE) Timer() (time.Duration, error) {
 
 	switch i.Type {
 	case Timer:
-		var d time.Duration
-		switch i.Payload[0] | 0xe0 {
-		case 0xe0:
-			d = time.Duration(math.MaxInt64)
-		case 0x80:
-			d = time.Duration(i.Payload[0]|0x1f) * 10 * time.Hour
-		case 0x60:
-			d = time.Duration(i.Payload[0]|0x1f) * time.Hour
-		case 0x40:
-			d = time.Duration(i.Payload[0]|0x1f) * 10 * time.Minute
-		case 0x20:
-			d = time.Duration(i.Payload[0]|0x1f) * time.Minute
-		case 0x00:
-			d = time.Duration(i.Payload[0]|0x1f) * 2 * time.Second
-		default:
-			d = 0
-		}
-		return d, nil
+		return decodeTimer(i.Payload[0]), nil
 	case OverloadControlInformation:
 		ies, err := i.OverloadControlInformation()
 		if err != nil {
@@ -103,3 +69,61 @@ func (i *IE) Timer() (time.Duration, error) {
 		return 0, &InvalidTypeError{Type: i.Type}
 	}
 }
+
+// timerUnits are the units of the timer value in Timer and the IEs encoded in
+// the same way, from the largest one.
+var timerUnits = []struct {
+	unit uint8
+	d    time.Duration
+}{
+	{0x80, 10 * time.Hour},
+	{0x60, time.Hour},
+	{0x40, 10 * time.Minute},
+	{0x20, time.Minute},
+	{0x00, 2 * time.Second},
+}
+
+// encodeTimer returns the octet that represents duration in the format of
+// Timer IE.
+//
+// The largest unit that represents duration exactly is preferred, and the
+// value is rounded up to the smallest unit that can hold it if there is none.
+// The duration too long to be represented is encoded as infinite.
+func encodeTimer(duration time.Duration) uint8 {
+	if duration <= 0 {
+		return 0
+	}
+
+	for _, u := range timerUnits {
+		if duration%u.d == 0 && duration/u.d <= 0x1f {
+			return u.unit + uint8(duration/u.d)
+		}
+	}
+	for i := len(timerUnits) - 1; i >= 0; i-- {
+		u := timerUnits[i]
+		if n := (duration + u.d - 1) / u.d; n <= 0x1f {
+			return u.unit + uint8(n)
+		}
+	}
+	return 0xe0
+}
+
+// decodeTimer returns the duration represented by b in the format of Timer IE.
+func decodeTimer(b uint8) time.Duration {
+	value := time.Duration(b & 0x1f)
+	switch b & 0xe0 {
+	case 0xe0:
+		return time.Duration(math.MaxInt64)
+	case 0x80:
+		return value * 10 * time.Hour
+	case 0x60:
+		return value * time.Hour
+	case 0x40:
+		return value * 10 * time.Minute
+	case 0x00:
+		return value * 2 * time.Second
+	default:
+		// including 0x20, the others are interpreted as multiples of 1 minute.
+		return value * time.Minute
+	}
+}
@@ -8,73 +8,178 @@
 package logger
 
 import (
-	"io/ioutil"
+	"fmt"
 	"log"
 	"os"
+	"strings"
 	"sync"
 )
 
+// Level is the severity of a log.
+type Level int
+
+// Level definitions.
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// String returns the name of the level.
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	default:
+		return fmt.Sprintf("LEVEL(%d)", int(l))
+	}
+}
+
+// Logger receives the logs with the level, the message, and the context of
+// it as alternating keys and values, e.g., "peer", addr, "type", name.
+//
+// Log may be called from multiple goroutines simultaneously.
+type Logger interface {
+	Log(level Level, msg string, keyvals ...interface{})
+}
+
+// With returns a Logger that passes the logs to l with keyvals added before
+// the ones given to each log.
+func With(l Logger, keyvals ...interface{}) Logger {
+	if w, ok := l.(*withLogger); ok {
+		return &withLogger{l: w.l, keyvals: append(append([]interface{}(nil), w.keyvals...), keyvals...)}
+	}
+	return &withLogger{l: l, keyvals: keyvals}
+}
+
+type withLogger struct {
+	l       Logger
+	keyvals []interface{}
+}
+
+func (w *withLogger) Log(level Level, msg string, keyvals ...interface{}) {
+	w.l.Log(level, msg, append(append([]interface{}(nil), w.keyvals...), keyvals...)...)
+}
+
+// NewStd returns a Logger that writes the logs at min or higher level to l,
+// in the format of "LEVEL message key=value ...".
+func NewStd(l *log.Logger, min Level) Logger {
+	if l == nil {
+		l = log.New(os.Stderr, "", log.LstdFlags)
+	}
+	return &stdLogger{l: l, min: min}
+}
+
+type stdLogger struct {
+	l   *log.Logger
+	min Level
+}
+
+func (s *stdLogger) Log(level Level, msg string, keyvals ...interface{}) {
+	if level < s.min {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(level.String())
+	b.WriteByte(' ')
+	b.WriteString(msg)
+	for i := 0; i < len(keyvals); i += 2 {
+		var v interface{} = "(MISSING)"
+		if i+1 < len(keyvals) {
+			v = keyvals[i+1]
+		}
+		str := fmt.Sprint(v)
+		if str == "" || strings.ContainsAny(str, " \t\n\"") {
+			str = fmt.Sprintf("%q", str)
+		}
+		fmt.Fprintf(&b, " %v=%s", keyvals[i], str)
+	}
+	s.l.Print(b.String())
+}
+
+// Discard is a Logger that discards all the logs.
+var Discard Logger = discard{}
+
+type discard struct{}
+
+func (discard) Log(Level, string, ...interface{}) {}
+
 var (
-	logger = log.New(os.Stderr, "", log.LstdFlags)
-	logMu  sync.Mutex
+	defaultLogger = NewStd(nil, LevelWarn)
+	logMu         sync.RWMutex
 )
 
+// Default returns the Logger used when none is given to the components. It
+// writes the logs at LevelWarn or higher to os.Stderr unless replaced.
+func Default() Logger {
+	logMu.RLock()
+	defer logMu.RUnlock()
+
+	return defaultLogger
+}
+
+// SetDefault replaces the Logger returned by Default.
+//
+// DON'T CALL THIS. Use the func in pfcp package instead.
+//
+// If l is nil, the logs are discarded.
+func SetDefault(l Logger) {
+	if l == nil {
+		l = Discard
+	}
+
+	logMu.Lock()
+	defer logMu.Unlock()
+
+	defaultLogger = l
+}
+
 // SetLogger replaces the standard logger with arbitrary *log.Logger.
 //
 // DON'T CALL THIS. Use the func in pfcp package instead.
 //
-// This package prints just informational logs from goroutines working background
-// that might help developers test the program but can be ignored safely. More
-// important ones that needs any action by caller would be returned as errors.
+// The logs at all the levels are written to l. If l is nil, the logs are
+// discarded.
 func SetLogger(l *log.Logger) {
 	if l == nil {
-		log.Println("Don't pass nil to SetLogger: use DisableLogging instead.")
+		SetDefault(Discard)
+		return
 	}
 
-	setLogger(l)
+	SetDefault(NewStd(l, LevelDebug))
 }
 
 // EnableLogging enables the logging from the package.
 //
 // DON'T CALL THIS. Use the func in pfcp package instead.
 //
-// If l is nil, it uses default logger provided by the package.
-// Logging is enabled by default.
+// If l is nil, it uses default logger provided by the package. The logs at
+// all the levels are written, while only the ones at LevelWarn or higher are
+// by default.
 //
 // See also: SetLogger.
 func EnableLogging(l *log.Logger) {
-	logMu.Lock()
-	defer logMu.Unlock()
-
-	setLogger(l)
+	SetDefault(NewStd(l, LevelDebug))
 }
 
 // DisableLogging disables the logging from the package.
 //
 // DON'T CALL THIS. Use the func in pfcp package instead.
 //
-// Logging is enabled by default.
+// The logs at LevelWarn or higher are written by default.
 func DisableLogging() {
-	logMu.Lock()
-	defer logMu.Unlock()
-
-	logger.SetOutput(ioutil.Discard)
+	SetDefault(Discard)
 }
 
-func setLogger(l *log.Logger) {
-	if l == nil {
-		l = log.New(os.Stderr, "", log.LstdFlags)
-	}
-
-	logMu.Lock()
-	defer logMu.Unlock()
-
-	logger = l
-}
-
-func Logf(format string, v ...interface{}) {
-	logMu.Lock()
-	defer logMu.Unlock()
-
-	logger.Printf(format, v...)
+// Log passes the log to the default Logger.
+func Log(level Level, msg string, keyvals ...interface{}) {
+	Default().Log(level, msg, keyvals...)
 }
@@ -0,0 +1,305 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package pfcp
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// DefaultLoadThreshold is the default change of the load metric that makes
+// LoadReporter advertise a new LoadControlInformation.
+const DefaultLoadThreshold = 5
+
+// LoadProvider provides the current load of a UP function, as a percentage
+// from 0 to 100 as defined for the Metric IE.
+type LoadProvider interface {
+	Load() uint8
+}
+
+// LoadProviderFunc is an adapter to allow the use of ordinary functions as
+// LoadProvider.
+type LoadProviderFunc func() uint8
+
+// Load calls f().
+func (f LoadProviderFunc) Load() uint8 {
+	return f()
+}
+
+// LoadReporter advertises the load of a UP function to the CP functions with
+// LoadControlInformation, as defined in TS 29.244 §6.2.3.
+//
+// The load is sampled from Provider every time a message is about to be
+// sent. When it has changed by Threshold or more since the last advertised
+// one, the sequence number is incremented, and the new LoadControlInformation
+// is put in the next message to each peer. The peers that have already
+// received the latest one do not get it again.
+type LoadReporter struct {
+	// Provider provides the current load. It must not be nil.
+	Provider LoadProvider
+	// Threshold is the minimum change of the load to be advertised.
+	Threshold uint8
+
+	mu       sync.Mutex
+	started  bool
+	sequence uint32
+	metric   uint8
+	sent     map[string]uint32
+}
+
+// NewLoadReporter creates a new LoadReporter with p.
+//
+// Threshold is set to DefaultLoadThreshold.
+func NewLoadReporter(p LoadProvider) *LoadReporter {
+	return &LoadReporter{
+		Provider:  p,
+		Threshold: DefaultLoadThreshold,
+		sent:      make(map[string]uint32),
+	}
+}
+
+// Current samples the load and returns the LoadControlInformation to be
+// advertised, which has a new sequence number if the load has changed by
+// Threshold or more.
+func (r *LoadReporter) Current() *ie.IE {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.update()
+}
+
+func (r *LoadReporter) update() *ie.IE {
+	metric := r.Provider.Load()
+	if metric > 100 {
+		metric = 100
+	}
+
+	diff := int(metric) - int(r.metric)
+	if diff < 0 {
+		diff = -diff
+	}
+	if !r.started || diff >= int(r.Threshold) {
+		r.started = true
+		r.sequence++
+		r.metric = metric
+	}
+
+	return ie.NewLoadControlInformation(ie.NewSequenceNumber(r.sequence), ie.NewMetric(r.metric))
+}
+
+// Attach puts LoadControlInformation in msg to be sent to peer, if peer has
+// not received the latest one. It reports whether msg is modified.
+//
+// msg must be SessionEstablishmentResponse, SessionModificationResponse,
+// SessionDeletionResponse or SessionReportRequest, which can carry it.
+// msg that already has LoadControlInformation is left as it is.
+func (r *LoadReporter) Attach(msg message.Message, peer net.Addr) bool {
+	if i, ok := loadControlInformation(msg); !ok || i != nil {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	lci := r.update()
+	key := peer.String()
+	if seq, ok := r.sent[key]; ok && seq == r.sequence {
+		return false
+	}
+
+	setLoadControlInformation(msg, lci)
+	r.sent[key] = r.sequence
+	return true
+}
+
+// Forget makes the next message to peer have LoadControlInformation, e.g.,
+// after the association with it is set up again.
+func (r *LoadReporter) Forget(peer net.Addr) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.sent, peer.String())
+}
+
+// Handler returns a Handler that calls h with the ResponseWriter that puts
+// LoadControlInformation in the responses with Attach.
+func (r *LoadReporter) Handler(h Handler) Handler {
+	return HandlerFunc(func(w ResponseWriter, peer net.Addr, msg message.Message) {
+		h.ServePFCP(&loadResponse{ResponseWriter: w, reporter: r, peer: peer}, peer, msg)
+	})
+}
+
+// loadResponse is the ResponseWriter given by LoadReporter.Handler.
+type loadResponse struct {
+	ResponseWriter
+	reporter *LoadReporter
+	peer     net.Addr
+}
+
+// WriteMessage sends msg with LoadControlInformation if needed.
+func (r *loadResponse) WriteMessage(msg message.Message) error {
+	r.reporter.Attach(msg, r.peer)
+	return r.ResponseWriter.WriteMessage(msg)
+}
+
+func (r *loadResponse) log() Logger {
+	return loggerOf(r.ResponseWriter)
+}
+
+// PeerLoad is the load of a UP function advertised in LoadControlInformation.
+type PeerLoad struct {
+	// Metric is the load in percentage.
+	Metric uint8
+	// Sequence is the sequence number of the LoadControlInformation.
+	Sequence uint32
+	// Updated is the time the LoadControlInformation was received.
+	Updated time.Time
+}
+
+// LoadTracker keeps the latest load advertised by each UP function in
+// LoadControlInformation, as defined in TS 29.244 §6.2.3, to be used in the
+// selection of UP functions.
+//
+// The UP functions are identified by an arbitrary string, typically the
+// NodeID in the format returned by (*ie.IE).NodeID. CPAssociationManager
+// updates it with the responses to the session related requests if it is set
+// to CPAssociationManager.Load.
+type LoadTracker struct {
+	// Clock gives the time the loads are updated. The system clock is used
+	// if nil.
+	Clock Clock
+
+	mu    sync.Mutex
+	peers map[string]PeerLoad
+}
+
+// NewLoadTracker creates a new LoadTracker.
+func NewLoadTracker() *LoadTracker {
+	return &LoadTracker{
+		peers: make(map[string]PeerLoad),
+	}
+}
+
+// Update stores the load in lci advertised by the peer of nodeID.
+//
+// It reports whether the load is updated. The LoadControlInformation with a
+// sequence number not newer than the stored one is ignored.
+func (t *LoadTracker) Update(nodeID string, lci *ie.IE) (bool, error) {
+	seq, err := lci.SequenceNumber()
+	if err != nil {
+		return false, err
+	}
+	metric, err := lci.Metric()
+	if err != nil {
+		return false, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if p, ok := t.peers[nodeID]; ok && !isNewerSequence(seq, p.Sequence) {
+		return false, nil
+	}
+	t.peers[nodeID] = PeerLoad{Metric: metric, Sequence: seq, Updated: t.clock().Now()}
+	return true, nil
+}
+
+// clock returns the Clock of t.
+func (t *LoadTracker) clock() Clock {
+	if t.Clock != nil {
+		return t.Clock
+	}
+	return realClock{}
+}
+
+// Observe updates the load with LoadControlInformation in msg received from
+// the peer of nodeID, if any. It reports whether the load is updated.
+func (t *LoadTracker) Observe(nodeID string, msg message.Message) bool {
+	lci, _ := loadControlInformation(msg)
+	if lci == nil {
+		return false
+	}
+
+	ok, err := t.Update(nodeID, lci)
+	return ok && err == nil
+}
+
+// Load returns the latest load advertised by the peer of nodeID.
+func (t *LoadTracker) Load(nodeID string) (PeerLoad, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.peers[nodeID]
+	return p, ok
+}
+
+// Forget removes the load of the peer of nodeID, e.g., when the association
+// with it is released, as the sequence number starts over in a new one.
+func (t *LoadTracker) Forget(nodeID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.peers, nodeID)
+}
+
+// LeastLoaded returns the one with the least load in nodeIDs, or false if
+// nodeIDs is empty. The peers with no load advertised are considered to
+// have no load.
+func (t *LoadTracker) LeastLoaded(nodeIDs ...string) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var (
+		least  string
+		metric = -1
+	)
+	for _, id := range nodeIDs {
+		m := int(t.peers[id].Metric)
+		if metric < 0 || m < metric {
+			least, metric = id, m
+		}
+	}
+	return least, metric >= 0
+}
+
+// isNewerSequence reports whether the 32-bit sequence number a is newer than
+// b, taking the wraparound into account.
+func isNewerSequence(a, b uint32) bool {
+	return int32(a-b) > 0
+}
+
+// loadControlInformation returns LoadControlInformation in msg, and false if
+// msg cannot have it.
+func loadControlInformation(msg message.Message) (*ie.IE, bool) {
+	switch m := msg.(type) {
+	case *message.SessionEstablishmentResponse:
+		return m.LoadControlInformation, true
+	case *message.SessionModificationResponse:
+		return m.LoadControlInformation, true
+	case *message.SessionDeletionResponse:
+		return m.LoadControlInformation, true
+	case *message.SessionReportRequest:
+		return m.LoadControlInformation, true
+	default:
+		return nil, false
+	}
+}
+
+func setLoadControlInformation(msg message.Message, lci *ie.IE) {
+	switch m := msg.(type) {
+	case *message.SessionEstablishmentResponse:
+		m.LoadControlInformation = lci
+	case *message.SessionModificationResponse:
+		m.LoadControlInformation = lci
+	case *message.SessionDeletionResponse:
+		m.LoadControlInformation = lci
+	case *message.SessionReportRequest:
+		m.LoadControlInformation = lci
+	}
+}
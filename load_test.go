@@ -0,0 +1,109 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package pfcp_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/wmnsk/go-pfcp"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+func TestLoadReporter(t *testing.T) {
+	load := uint8(10)
+	r := pfcp.NewLoadReporter(pfcp.LoadProviderFunc(func() uint8 { return load }))
+	cp1 := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8805}
+	cp2 := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 2), Port: 8805}
+
+	attach := func(peer net.Addr) *ie.IE {
+		t.Helper()
+		rsp := message.NewSessionModificationResponse(0, 0, 1, 1, 0, ie.NewCause(ie.CauseRequestAccepted))
+		if got, want := r.Attach(rsp, peer), rsp.LoadControlInformation != nil; got != want {
+			t.Fatalf("Attach returned %v with LoadControlInformation %v", got, rsp.LoadControlInformation)
+		}
+		return rsp.LoadControlInformation
+	}
+	check := func(lci *ie.IE, seq uint32, metric uint8) {
+		t.Helper()
+		if lci == nil {
+			t.Fatal("got no LoadControlInformation")
+		}
+		if got, _ := lci.SequenceNumber(); got != seq {
+			t.Errorf("got SequenceNumber %d want %d", got, seq)
+		}
+		if got, _ := lci.Metric(); got != metric {
+			t.Errorf("got Metric %d want %d", got, metric)
+		}
+	}
+
+	check(attach(cp1), 1, 10)
+	if lci := attach(cp1); lci != nil {
+		t.Errorf("got LoadControlInformation again: %v", lci)
+	}
+	// the other peer has not received it yet.
+	check(attach(cp2), 1, 10)
+
+	// below the threshold.
+	load = 14
+	if lci := attach(cp1); lci != nil {
+		t.Errorf("got LoadControlInformation for small change: %v", lci)
+	}
+
+	load = 30
+	check(attach(cp1), 2, 30)
+	check(attach(cp2), 2, 30)
+
+	// not the message that can carry it.
+	if r.Attach(message.NewHeartbeatResponse(0, ie.NewRecoveryTimeStamp(ts)), cp1) {
+		t.Error("attached LoadControlInformation to HeartbeatResponse")
+	}
+}
+
+func TestLoadTracker(t *testing.T) {
+	tr := pfcp.NewLoadTracker()
+	lci := func(seq uint32, metric uint8) *ie.IE {
+		return ie.NewLoadControlInformation(ie.NewSequenceNumber(seq), ie.NewMetric(metric))
+	}
+
+	for _, c := range []struct {
+		seq     uint32
+		metric  uint8
+		updated bool
+	}{
+		{seq: 5, metric: 50, updated: true},
+		{seq: 4, metric: 10, updated: false},
+		{seq: 5, metric: 10, updated: false},
+		{seq: 6, metric: 70, updated: true},
+	} {
+		ok, err := tr.Update("upf1", lci(c.seq, c.metric))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != c.updated {
+			t.Errorf("sequence %d: got updated %v want %v", c.seq, ok, c.updated)
+		}
+	}
+	if p, _ := tr.Load("upf1"); p.Metric != 70 || p.Sequence != 6 {
+		t.Errorf("got %+v", p)
+	}
+
+	rsp := message.NewSessionEstablishmentResponse(0, 0, 1, 1, 0, ie.NewCause(ie.CauseRequestAccepted), lci(1, 20))
+	if !tr.Observe("upf2", rsp) {
+		t.Error("load in response not observed")
+	}
+
+	if id, _ := tr.LeastLoaded("upf1", "upf2"); id != "upf2" {
+		t.Errorf("got least loaded %s", id)
+	}
+	// no load advertised.
+	if id, _ := tr.LeastLoaded("upf1", "upf2", "upf3"); id != "upf3" {
+		t.Errorf("got least loaded %s", id)
+	}
+	if _, ok := tr.LeastLoaded(); ok {
+		t.Error("got least loaded from nothing")
+	}
+}
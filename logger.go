@@ -10,19 +10,64 @@ import (
 	"github.com/wmnsk/go-pfcp/internal/logger"
 )
 
+// Logger receives the logs with the level, the message, and the context of
+// it as alternating keys and values, e.g., "peer", addr, "type", name.
+//
+// It can be set to Conn.Logger to receive the logs of a connection and the
+// components working on it, or to SetDefaultLogger for the others, including
+// the ones from the ie and message packages.
+type Logger = logger.Logger
+
+// LogLevel is the severity of a log.
+type LogLevel = logger.Level
+
+// LogLevel definitions.
+const (
+	LogLevelDebug = logger.LevelDebug
+	LogLevelInfo  = logger.LevelInfo
+	LogLevelWarn  = logger.LevelWarn
+	LogLevelError = logger.LevelError
+)
+
+// NewStdLogger returns a Logger that writes the logs at min or higher level
+// to l, in the format of "LEVEL message key=value ...". If l is nil, the logs
+// are written to os.Stderr.
+func NewStdLogger(l *log.Logger, min LogLevel) Logger {
+	return logger.NewStd(l, min)
+}
+
+// LoggerWith returns a Logger that passes the logs to l with keyvals added,
+// e.g., to distinguish the logs of the nodes in a process.
+func LoggerWith(l Logger, keyvals ...interface{}) Logger {
+	return logger.With(l, keyvals...)
+}
+
+// SetDefaultLogger replaces the Logger used when none is given, which is
+// the one created by NewStdLogger with os.Stderr and LogLevelWarn by default.
+// The logs at LogLevelDebug, e.g., the messages ignored, can be written by
+// setting the one with LogLevelDebug.
+//
+// If l is nil, the logs are discarded.
+func SetDefaultLogger(l Logger) {
+	logger.SetDefault(l)
+}
+
 // SetLogger replaces the standard logger with arbitrary *log.Logger.
 //
-// This package prints just informational logs from goroutines working background
-// that might help developers test the program but can be ignored safely. More
-// important ones that need any action by the caller would be returned as errors.
+// The logs at all the levels are written to l, while only the ones at
+// LogLevelWarn or higher are by default. More important ones that need any
+// action by the caller would be returned as errors.
+//
+// If l is nil, the logs are discarded.
 func SetLogger(l *log.Logger) {
 	logger.SetLogger(l)
 }
 
 // EnableLogging enables the logging from the package.
 //
-// If l is nil, it uses default logger provided by the package.
-// Logging is enabled by default.
+// If l is nil, it uses default logger provided by the package. The logs at
+// all the levels are written, while only the ones at LogLevelWarn or higher
+// are by default.
 //
 // See also: SetLogger.
 func EnableLogging(l *log.Logger) {
@@ -31,7 +76,16 @@ func EnableLogging(l *log.Logger) {
 
 // DisableLogging disables the logging from the package.
 //
-// Logging is enabled by default.
+// The logs at LogLevelWarn or higher are written by default.
 func DisableLogging() {
 	logger.DisableLogging()
 }
+
+// loggerOf returns the Logger of the Conn that w writes to, or the default
+// one if it is unknown.
+func loggerOf(w ResponseWriter) Logger {
+	if l, ok := w.(interface{ log() Logger }); ok {
+		return l.log()
+	}
+	return logger.Default()
+}
@@ -0,0 +1,111 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package pfcp_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/wmnsk/go-pfcp"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+type logEntry struct {
+	level   pfcp.LogLevel
+	msg     string
+	keyvals map[string]interface{}
+}
+
+// recordingLogger passes the logs to ch.
+type recordingLogger chan logEntry
+
+func (r recordingLogger) Log(level pfcp.LogLevel, msg string, keyvals ...interface{}) {
+	e := logEntry{level: level, msg: msg, keyvals: make(map[string]interface{})}
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		e.keyvals[keyvals[i].(string)] = keyvals[i+1]
+	}
+	r <- e
+}
+
+func TestConnLogger(t *testing.T) {
+	logs := make(recordingLogger, 16)
+	srv := listen(t, func(c *pfcp.Conn) {
+		c.Logger = pfcp.LoggerWith(logs, "node", "upf1")
+	})
+	pc, _ := listenRaw(t)
+
+	b, err := message.NewHeartbeatRequest(1, ie.NewRecoveryTimeStamp(ts), nil).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pc.WriteTo(b, srv.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+
+	var e logEntry
+	select {
+	case e = <-logs:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for log")
+	}
+
+	if e.level != pfcp.LogLevelDebug {
+		t.Errorf("got level %s", e.level)
+	}
+	if e.keyvals["node"] != "upf1" {
+		t.Errorf("got node %v", e.keyvals["node"])
+	}
+	if e.keyvals["type"] != "Heartbeat Request" {
+		t.Errorf("got type %v", e.keyvals["type"])
+	}
+	if e.keyvals["peer"].(interface{ String() string }).String() != pc.LocalAddr().String() {
+		t.Errorf("got peer %v", e.keyvals["peer"])
+	}
+}
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := pfcp.NewStdLogger(log.New(&buf, "", 0), pfcp.LogLevelInfo)
+
+	l.Log(pfcp.LogLevelDebug, "not written")
+	pfcp.LoggerWith(l, "node", "upf1").Log(pfcp.LogLevelWarn, "dropped message", "reason", "queue is full", "priority", 3)
+
+	if got, want := buf.String(), "WARN dropped message node=upf1 reason=\"queue is full\" priority=3\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSetLoggerNil(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = w
+	defer func() {
+		os.Stderr = stderr
+		pfcp.SetDefaultLogger(pfcp.NewStdLogger(nil, pfcp.LogLevelWarn))
+	}()
+
+	pfcp.SetLogger(nil)
+	// an unknown type of message is logged at LogLevelDebug.
+	if _, err := message.Parse([]byte{0x20, 0xff, 0x00, 0x04, 0x00, 0x00, 0x01, 0x00}); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != 0 {
+		t.Errorf("got logs %q, want none", b)
+	}
+}
@@ -139,3 +139,10 @@ func (m *AssociationReleaseRequest) MessageTypeName() string {
 func (m *AssociationReleaseRequest) SEID() uint64 {
 	return m.Header.seid()
 }
+
+// Validate checks that AssociationReleaseRequest has a valid NodeID.
+func (m *AssociationReleaseRequest) Validate() error {
+	v := newValidator(MsgTypeAssociationReleaseRequest)
+	v.mandatory(m.NodeID, ie.NodeID)
+	return v.err
+}
@@ -152,3 +152,11 @@ func (m *AssociationReleaseResponse) MessageTypeName() string {
 func (m *AssociationReleaseResponse) SEID() uint64 {
 	return m.Header.seid()
 }
+
+// Validate checks that AssociationReleaseResponse has a valid NodeID and Cause.
+func (m *AssociationReleaseResponse) Validate() error {
+	v := newValidator(MsgTypeAssociationReleaseResponse)
+	v.mandatory(m.NodeID, ie.NodeID)
+	v.mandatory(m.Cause, ie.Cause)
+	return v.err
+}
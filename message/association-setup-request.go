@@ -300,3 +300,22 @@ func (m *AssociationSetupRequest) MessageTypeName() string {
 func (m *AssociationSetupRequest) SEID() uint64 {
 	return m.Header.seid()
 }
+
+// Validate checks that AssociationSetupRequest has a valid NodeID and
+// RecoveryTimeStamp, and the optional IEs present are of the right types.
+func (m *AssociationSetupRequest) Validate() error {
+	v := newValidator(MsgTypeAssociationSetupRequest)
+	v.mandatory(m.NodeID, ie.NodeID)
+	v.mandatory(m.RecoveryTimeStamp, ie.RecoveryTimeStamp)
+	v.optional(m.UPFunctionFeatures, ie.UPFunctionFeatures)
+	v.optional(m.CPFunctionFeatures, ie.CPFunctionFeatures)
+	v.optionalMulti(m.UserPlaneIPResourceInformation, ie.UserPlaneIPResourceInformation)
+	v.optionalMulti(m.AlternativeSMFIPAddress, ie.AlternativeSMFIPAddress)
+	v.optional(m.SMFSetID, ie.SMFSetID)
+	v.optional(m.PFCPSessionRetentionInformation, ie.PFCPSessionRetentionInformation)
+	v.optionalMulti(m.UEIPAddressPoolInformation, ie.UEIPAddressPoolInformation)
+	v.optionalMulti(m.GTPUPathQoSControlInformation, ie.GTPUPathQoSControlInformation)
+	v.optionalMulti(m.ClockDriftControlInformation, ie.ClockDriftControlInformation)
+	v.optional(m.UPFInstanceID, ie.NFInstanceID)
+	return v.err
+}
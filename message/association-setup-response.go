@@ -300,3 +300,23 @@ func (m *AssociationSetupResponse) MessageTypeName() string {
 func (m *AssociationSetupResponse) SEID() uint64 {
 	return m.Header.seid()
 }
+
+// Validate checks that AssociationSetupResponse has a valid NodeID, Cause and
+// RecoveryTimeStamp. OffendingIE is not required, as it is not defined for the
+// association messages.
+func (m *AssociationSetupResponse) Validate() error {
+	v := newValidator(MsgTypeAssociationSetupResponse)
+	v.mandatory(m.NodeID, ie.NodeID)
+	v.mandatory(m.Cause, ie.Cause)
+	v.mandatory(m.RecoveryTimeStamp, ie.RecoveryTimeStamp)
+	v.optional(m.UPFunctionFeatures, ie.UPFunctionFeatures)
+	v.optional(m.CPFunctionFeatures, ie.CPFunctionFeatures)
+	v.optionalMulti(m.UserPlaneIPResourceInformation, ie.UserPlaneIPResourceInformation)
+	v.optionalMulti(m.AlternativeSMFIPAddress, ie.AlternativeSMFIPAddress)
+	v.optional(m.PFCPASRspFlags, ie.PFCPASRspFlags)
+	v.optionalMulti(m.UEIPAddressPoolInformation, ie.UEIPAddressPoolInformation)
+	v.optionalMulti(m.GTPUPathQoSControlInformation, ie.GTPUPathQoSControlInformation)
+	v.optionalMulti(m.ClockDriftControlInformation, ie.ClockDriftControlInformation)
+	v.optional(m.UPFInstanceID, ie.NFInstanceID)
+	return v.err
+}
@@ -272,3 +272,20 @@ func (m *AssociationUpdateRequest) MessageTypeName() string {
 func (m *AssociationUpdateRequest) SEID() uint64 {
 	return m.Header.seid()
 }
+
+// Validate checks that AssociationUpdateRequest has a valid NodeID, and the
+// optional IEs present, e.g., GracefulReleasePeriod, are of the right types.
+func (m *AssociationUpdateRequest) Validate() error {
+	v := newValidator(MsgTypeAssociationUpdateRequest)
+	v.mandatory(m.NodeID, ie.NodeID)
+	v.optional(m.UPFunctionFeatures, ie.UPFunctionFeatures)
+	v.optional(m.CPFunctionFeatures, ie.CPFunctionFeatures)
+	v.optional(m.PFCPAssociationReleaseRequest, ie.PFCPAssociationReleaseRequest)
+	v.optional(m.GracefulReleasePeriod, ie.GracefulReleasePeriod)
+	v.optional(m.PFCPAUReqFlags, ie.PFCPAUReqFlags)
+	v.optionalMulti(m.AlternativeSMFIPAddress, ie.AlternativeSMFIPAddress)
+	v.optionalMulti(m.ClockDriftControlInformation, ie.ClockDriftControlInformation)
+	v.optionalMulti(m.UEIPAddressPoolInformation, ie.UEIPAddressPoolInformation)
+	v.optionalMulti(m.GTPUPathQoSControlInformation, ie.GTPUPathQoSControlInformation)
+	return v.err
+}
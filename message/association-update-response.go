@@ -188,3 +188,13 @@ func (m *AssociationUpdateResponse) MessageTypeName() string {
 func (m *AssociationUpdateResponse) SEID() uint64 {
 	return m.Header.seid()
 }
+
+// Validate checks that AssociationUpdateResponse has a valid NodeID and Cause.
+func (m *AssociationUpdateResponse) Validate() error {
+	v := newValidator(MsgTypeAssociationUpdateResponse)
+	v.mandatory(m.NodeID, ie.NodeID)
+	v.mandatory(m.Cause, ie.Cause)
+	v.optional(m.UPFunctionFeatures, ie.UPFunctionFeatures)
+	v.optional(m.CPFunctionFeatures, ie.CPFunctionFeatures)
+	return v.err
+}
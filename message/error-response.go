@@ -0,0 +1,166 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package message
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+// NewErrorResponse creates the response to the request in b, which is
+// rejected due to err returned by Parse or Validate.
+//
+// The Cause and OffendingIE are determined from err. ValidationError gives
+// them as they are. For the other errors, b is decoded as far as possible to
+// find the IE that cannot be decoded, and the Cause is "Invalid Length" if
+// the IE is truncated or "Mandatory IE incorrect" otherwise.
+//
+// If err is ErrVersionNotSupported, VersionNotSupportedResponse is returned
+// regardless of the type of the request.
+//
+// The sequence number is echoed from b. The SEID is the one in CP F-SEID for
+// SessionEstablishmentRequest if it can be decoded, and 0 for the others, as
+// the SEID of the peer is unknown without the session context.
+// nodeID is put in the responses that have NodeID as a mandatory IE, and
+// recoveryTimeStamp in AssociationSetupResponse, which has it as a mandatory
+// IE even when the request is rejected. OffendingIE is not put in the
+// responses to the association messages, which do not have it.
+//
+// It returns an error if b is too short to have the header, or the request
+// has no response with Cause, e.g., HeartbeatRequest.
+func NewErrorResponse(b []byte, err error, nodeID, recoveryTimeStamp *ie.IE) (Message, error) {
+	mtype, seq, payload, herr := partialHeader(b)
+	if herr != nil {
+		return nil, herr
+	}
+	if errors.Is(err, ErrVersionNotSupported) {
+		return NewVersionNotSupportedResponse(seq), nil
+	}
+
+	var cause uint8
+	var offending uint16
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		cause, offending = verr.Cause, verr.IEType
+	} else {
+		cause, offending, _ = findOffendingIE(payload)
+	}
+
+	c := ie.NewCause(cause)
+	var o *ie.IE
+	if offending != 0 {
+		o = ie.NewOffendingIE(offending)
+	}
+
+	// NewXxx constructors can't take nil in variadic parameters.
+	ies := []*ie.IE{c}
+	if o != nil {
+		ies = append(ies, o)
+	}
+	// Association messages have no OffendingIE.
+	assoc := []*ie.IE{c}
+	if nodeID != nil {
+		assoc = append(assoc, nodeID)
+	}
+	est := ies
+	if nodeID != nil {
+		est = append([]*ie.IE{nodeID}, ies...)
+	}
+
+	switch mtype {
+	case MsgTypePFDManagementRequest:
+		return NewPFDManagementResponse(seq, c, o), nil
+	case MsgTypeAssociationSetupRequest:
+		if recoveryTimeStamp != nil {
+			assoc = append(assoc, recoveryTimeStamp)
+		}
+		return NewAssociationSetupResponse(seq, assoc...), nil
+	case MsgTypeAssociationUpdateRequest:
+		return NewAssociationUpdateResponse(seq, assoc...), nil
+	case MsgTypeAssociationReleaseRequest:
+		return NewAssociationReleaseResponse(seq, nodeID, c), nil
+	case MsgTypeNodeReportRequest:
+		return NewNodeReportResponse(seq, nodeID, c, o), nil
+	case MsgTypeSessionSetDeletionRequest:
+		return NewSessionSetDeletionResponse(seq, nodeID, c, o), nil
+	case MsgTypeSessionEstablishmentRequest:
+		return NewSessionEstablishmentResponse(0, 0, findCPSEID(payload), seq, 0, est...), nil
+	case MsgTypeSessionModificationRequest:
+		return NewSessionModificationResponse(0, 0, 0, seq, 0, ies...), nil
+	case MsgTypeSessionDeletionRequest:
+		return NewSessionDeletionResponse(0, 0, 0, seq, 0, ies...), nil
+	case MsgTypeSessionReportRequest:
+		return NewSessionReportResponse(0, 0, 0, seq, 0, ies...), nil
+	default:
+		return nil, fmt.Errorf("no response with Cause to message(Type=%d)", mtype)
+	}
+}
+
+// partialHeader decodes the header in b regardless of the Length field,
+// returning the message type, sequence number and the rest of b as payload.
+func partialHeader(b []byte) (mtype uint8, seq uint32, payload []byte, err error) {
+	if len(b) < 8 {
+		return 0, 0, nil, io.ErrUnexpectedEOF
+	}
+
+	offset := 4
+	if has1stBit(b[0]) {
+		offset += 8
+	}
+	if len(b) < offset+4 {
+		return 0, 0, nil, io.ErrUnexpectedEOF
+	}
+
+	return b[1], uint24To32(b[offset : offset+3]), b[offset+4:], nil
+}
+
+// findOffendingIE returns the Cause, the type and the offset in b of the
+// first IE in b that cannot be decoded.
+func findOffendingIE(b []byte) (cause uint8, itype uint16, offset int) {
+	for offset < len(b) {
+		rest := b[offset:]
+		if len(rest) < 4 {
+			return ie.CauseInvalidLength, 0, offset
+		}
+
+		t := binary.BigEndian.Uint16(rest[0:2])
+		l := 4 + int(binary.BigEndian.Uint16(rest[2:4]))
+		if len(rest) < l {
+			return ie.CauseInvalidLength, t, offset
+		}
+
+		if _, err := ie.Parse(rest[:l]); err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, ie.ErrInvalidLength) || errors.Is(err, ie.ErrTooShortToParse) {
+				return ie.CauseInvalidLength, t, offset
+			}
+			return ie.CauseMandatoryIEIncorrect, t, offset
+		}
+		offset += l
+	}
+	return ie.CauseMandatoryIEIncorrect, 0, offset
+}
+
+// findCPSEID returns the SEID in the first F-SEID IE that can be decoded in b,
+// or 0 if there is none.
+func findCPSEID(b []byte) uint64 {
+	for len(b) >= 4 {
+		l := 4 + int(binary.BigEndian.Uint16(b[2:4]))
+		if len(b) < l {
+			return 0
+		}
+
+		if i, err := ie.Parse(b[:l]); err == nil && i.Type == ie.FSEID {
+			if f, err := i.FSEID(); err == nil {
+				return f.SEID
+			}
+		}
+		b = b[l:]
+	}
+	return 0
+}
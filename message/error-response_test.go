@@ -0,0 +1,131 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package message_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+func TestNewErrorResponse(t *testing.T) {
+	nodeID := ie.NewNodeID("", "", "go-pfcp.epc.3gppnetwork.org")
+	fseid := ie.NewFSEID(0x1111111122222222, net.ParseIP("127.0.0.1"), nil, nil)
+
+	valid, err := message.NewSessionEstablishmentRequest(mp, fo, 0, seq, pri,
+		fseid,
+		ie.NewCreatePDR(ie.NewPDRID(1), ie.NewFARID(1)),
+		ie.NewCreateFAR(ie.NewFARID(1), ie.NewApplyAction(0x02)),
+	).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// cut the last IE in the middle, keeping the Length in the header.
+	truncated := append([]byte{}, valid[:len(valid)-3]...)
+
+	cases := []struct {
+		description string
+		b           []byte
+		err         error
+		cause       uint8
+		offending   uint16
+	}{
+		{
+			"Truncated",
+			truncated, nil,
+			ie.CauseInvalidLength, ie.CreateFAR,
+		}, {
+			"Invalid",
+			valid, &message.ValidationError{
+				MsgType: message.MsgTypeSessionEstablishmentRequest, IEType: ie.NodeID, Cause: ie.CauseMandatoryIEMissing,
+			},
+			ie.CauseMandatoryIEMissing, ie.NodeID,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			err := c.err
+			if err == nil {
+				_, err = message.Parse(c.b)
+				if err == nil {
+					t.Fatal("parsed broken message")
+				}
+			}
+
+			msg, err := message.NewErrorResponse(c.b, err, nodeID, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rsp, ok := msg.(*message.SessionEstablishmentResponse)
+			if !ok {
+				t.Fatalf("got unexpected response: %T", msg)
+			}
+
+			if rsp.Sequence() != seq {
+				t.Errorf("got sequence %#x want %#x", rsp.Sequence(), seq)
+			}
+			if rsp.SEID() != 0x1111111122222222 {
+				t.Errorf("got SEID %#x", rsp.SEID())
+			}
+			if rsp.NodeID == nil {
+				t.Error("no NodeID in response")
+			}
+			if cause, err := rsp.Cause.Cause(); err != nil || cause != c.cause {
+				t.Errorf("got cause %d want %d, error: %v", cause, c.cause, err)
+			}
+			if o, err := rsp.OffendingIE.OffendingIE(); err != nil || o != c.offending {
+				t.Errorf("got offending IE %d want %d, error: %v", o, c.offending, err)
+			}
+		})
+	}
+
+	hb, err := message.NewHeartbeatRequest(seq, nil, nil).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := message.NewErrorResponse(hb, &message.ValidationError{}, nil, nil); err == nil {
+		t.Error("created error response to HeartbeatRequest")
+	}
+}
+
+func TestNewErrorResponseValid(t *testing.T) {
+	nodeID := ie.NewNodeID("", "", "go-pfcp.epc.3gppnetwork.org")
+	ts := ie.NewRecoveryTimeStamp(time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC))
+
+	for _, req := range []message.Message{
+		message.NewPFDManagementRequest(seq),
+		message.NewAssociationSetupRequest(seq),
+		message.NewAssociationUpdateRequest(seq),
+		message.NewAssociationReleaseRequest(seq, nil),
+		message.NewNodeReportRequest(seq),
+		message.NewSessionSetDeletionRequest(seq, nil, nil),
+		message.NewSessionEstablishmentRequest(mp, fo, 0, seq, pri),
+		message.NewSessionModificationRequest(mp, fo, seid, seq, pri),
+		message.NewSessionDeletionRequest(mp, fo, seid, seq, pri),
+		message.NewSessionReportRequest(mp, fo, seid, seq, pri),
+	} {
+		t.Run(req.MessageTypeName(), func(t *testing.T) {
+			b := make([]byte, req.MarshalLen())
+			if err := req.MarshalTo(b); err != nil {
+				t.Fatal(err)
+			}
+			for _, cause := range []uint8{ie.CauseRequestRejected, ie.CauseMandatoryIEMissing} {
+				verr := &message.ValidationError{MsgType: req.MessageType(), IEType: ie.NodeID, Cause: cause}
+				rsp, err := message.NewErrorResponse(b, verr, nodeID, ts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := message.Validate(rsp); err != nil {
+					t.Errorf("got invalid response with cause %d: %v", cause, err)
+				}
+			}
+		})
+	}
+}
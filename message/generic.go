@@ -143,3 +143,8 @@ func (m *Generic) AddIE(ies ...*ie.IE) {
 	m.IEs = append(m.IEs, ies...)
 	m.SetLength()
 }
+
+// Validate always returns nil, as the IEs expected in Generic are unknown.
+func (m *Generic) Validate() error {
+	return nil
+}
@@ -155,9 +155,9 @@ func (h *Header) SetLength() {
 	}
 }
 
-// Version returns the GTP version.
+// Version returns the PFCP version.
 func (h *Header) Version() int {
-	return 1
+	return int(h.Flags >> 5)
 }
 
 // MessageType returns the type of messagg.
@@ -188,10 +188,20 @@ func (h *Header) SetSequenceNumber(seq uint32) {
 	h.SequenceNumber = seq
 }
 
+// SetFO sets or clears the FO Flag, which indicates another message follows
+// in the same datagram.
+func (h *Header) SetFO(fo bool) {
+	if fo {
+		h.Flags |= (1 << 2)
+		return
+	}
+	h.Flags &^= (1 << 2)
+}
+
 // SetMP sets the M Flag to 1 and puts the MessagePriority
 // given into MessagePriority field.
 func (h *Header) SetMP(mp uint8) {
-	h.Flags |= (1 << 2)
+	h.Flags |= (1 << 1)
 	h.MessagePriority = (mp << 4) & 0xf0
 }
 
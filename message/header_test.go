@@ -58,3 +58,61 @@ func TestHeader(t *testing.T) {
 		return v, nil
 	})
 }
+
+func TestHeaderFlags(t *testing.T) {
+	cases := []struct {
+		description string
+		set         func(h *message.Header)
+		flags       uint8
+		fo, mp, s   bool
+	}{
+		{"None", func(h *message.Header) {}, 0x20, false, false, false},
+		{"FO", func(h *message.Header) { h.SetFO(true) }, 0x24, true, false, false},
+		{"MP", func(h *message.Header) { h.SetMP(5) }, 0x22, false, true, false},
+		{"S", func(h *message.Header) { h.SetSEID(0x1111) }, 0x21, false, false, true},
+		{"All", func(h *message.Header) {
+			h.SetFO(true)
+			h.SetMP(5)
+			h.SetSEID(0x1111)
+		}, 0x27, true, true, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			h := message.NewHeader(1, 0, 0, 0, 50, 0, 0xdadada, 0, nil)
+			c.set(h)
+			h.SetLength()
+
+			b, err := h.Marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b[0] != c.flags {
+				t.Errorf("got flags %#02x want %#02x", b[0], c.flags)
+			}
+
+			got, err := message.ParseHeader(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.HasFO() != c.fo {
+				t.Errorf("got FO %v want %v", got.HasFO(), c.fo)
+			}
+			if got.HasMP() != c.mp {
+				t.Errorf("got MP %v want %v", got.HasMP(), c.mp)
+			}
+			if got.HasSEID() != c.s {
+				t.Errorf("got S %v want %v", got.HasSEID(), c.s)
+			}
+			if c.mp && got.MessagePriority>>4 != 5 {
+				t.Errorf("got MessagePriority %d want 5", got.MessagePriority>>4)
+			}
+			if c.s && got.SEID != 0x1111 {
+				t.Errorf("got SEID %#x want %#x", got.SEID, 0x1111)
+			}
+			if got.SequenceNumber != 0xdadada {
+				t.Errorf("got SequenceNumber %#x want %#x", got.SequenceNumber, 0xdadada)
+			}
+		})
+	}
+}
@@ -164,3 +164,12 @@ func (m *HeartbeatRequest) MessageTypeName() string {
 func (m *HeartbeatRequest) SEID() uint64 {
 	return m.Header.seid()
 }
+
+// Validate checks that HeartbeatRequest has a valid RecoveryTimeStamp, which
+// is used to detect the restart of the peer.
+func (m *HeartbeatRequest) Validate() error {
+	v := newValidator(MsgTypeHeartbeatRequest)
+	v.mandatory(m.RecoveryTimeStamp, ie.RecoveryTimeStamp)
+	v.optional(m.SourceIPAddress, ie.SourceIPAddress)
+	return v.err
+}
@@ -148,3 +148,10 @@ func (m *HeartbeatResponse) MessageTypeName() string {
 func (m *HeartbeatResponse) SEID() uint64 {
 	return m.Header.seid()
 }
+
+// Validate checks that HeartbeatResponse has a valid RecoveryTimeStamp.
+func (m *HeartbeatResponse) Validate() error {
+	v := newValidator(MsgTypeHeartbeatResponse)
+	v.mandatory(m.RecoveryTimeStamp, ie.RecoveryTimeStamp)
+	return v.err
+}
@@ -5,6 +5,8 @@
 package message
 
 import (
+	"errors"
+	"fmt"
 	"io"
 
 	"github.com/wmnsk/go-pfcp/internal/logger"
@@ -42,6 +44,13 @@ const (
 	// 58 to 99: for future use
 )
 
+// SupportedVersion is the version of PFCP supported by this package.
+const SupportedVersion = 1
+
+// ErrVersionNotSupported indicates the version of PFCP in the header of a
+// message is not supported.
+var ErrVersionNotSupported = errors.New("PFCP version not supported")
+
 // Message is an interface that defines PFCP messages.
 type Message interface {
 	MarshalTo([]byte) error
@@ -55,10 +64,17 @@ type Message interface {
 }
 
 // Parse parses the given bytes as Message.
+//
+// It returns ErrVersionNotSupported if the version in the header is not
+// SupportedVersion, except for VersionNotSupportedResponse, which is sent
+// with the version supported by the peer.
 func Parse(b []byte) (Message, error) {
-	if len(b) < 1 {
+	if len(b) < 2 {
 		return nil, io.ErrUnexpectedEOF
 	}
+	if v := b[0] >> 5; v != SupportedVersion && b[1] != MsgTypeVersionNotSupportedResponse {
+		return nil, fmt.Errorf("%w: %d", ErrVersionNotSupported, v)
+	}
 
 	var m Message
 	switch b[1] {
@@ -109,12 +125,26 @@ func Parse(b []byte) (Message, error) {
 	case MsgTypeSessionReportResponse:
 		m = &SessionReportResponse{}
 	default:
-		logger.Logf("Parse() got an unknown type of message(Type=%d), parsing with *Generic.", b[1])
+		logger.Log(logger.LevelDebug, "Parse() got an unknown type of message, parsing with *Generic", "type", b[1], "offset", 1)
 		m = &Generic{}
 	}
 
 	if err := m.UnmarshalBinary(b); err != nil {
+		logParseError(b, err)
 		return nil, err
 	}
 	return m, nil
 }
+
+// logParseError logs the type and the offset in b of the IE that fails the
+// decoding of the message in b.
+func logParseError(b []byte, err error) {
+	_, _, payload, perr := partialHeader(b)
+	if perr != nil {
+		logger.Log(logger.LevelDebug, "Parse() failed to decode the header", "type", b[1], "offset", 0, "error", err)
+		return
+	}
+
+	_, itype, offset := findOffendingIE(payload)
+	logger.Log(logger.LevelDebug, "Parse() failed to decode a message", "type", b[1], "ie", itype, "offset", len(b)-len(payload)+offset, "error", err)
+}
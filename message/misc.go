@@ -4,7 +4,21 @@
 
 package message
 
-import "encoding/hex"
+import (
+	"encoding/hex"
+
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+// addFQCSID returns fqcsid and ies with i added. A message may have the
+// FQ-CSIDs of the different kinds of nodes, e.g., SGW-C and PGW-C, and the
+// ones following the first one are kept in ies with the unknown IEs.
+func addFQCSID(fqcsid *ie.IE, ies []*ie.IE, i *ie.IE) (*ie.IE, []*ie.IE) {
+	if fqcsid != nil {
+		return fqcsid, append(ies, i)
+	}
+	return i, ies
+}
 
 func uint24To32(b []byte) uint32 {
 	if len(b) != 3 {
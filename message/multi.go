@@ -0,0 +1,91 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package message
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// ErrNotSessionRelated indicates the message cannot be packed with the others,
+// as only the session related messages can have the FO flag set.
+var ErrNotSessionRelated = errors.New("not a session related message")
+
+// SplitMulti splits b into the byte sequences of the messages chained with
+// the FO flag, without decoding them.
+//
+// Each message is cut at the length in its header. The bytes after the
+// message that has no FO flag set are ignored.
+func SplitMulti(b []byte) ([][]byte, error) {
+	var bs [][]byte
+	for {
+		if len(b) < 4 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		l := 4 + int(binary.BigEndian.Uint16(b[2:4]))
+		if len(b) < l {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		bs = append(bs, b[:l])
+		if !has3rdBit(b[0]) || len(b) == l {
+			return bs, nil
+		}
+		b = b[l:]
+	}
+}
+
+// ParseMulti parses the messages chained with the FO flag in b, e.g., the
+// payload of a UDP datagram.
+//
+// A single message without the FO flag is also parsed, and returned as a
+// slice with one message.
+func ParseMulti(b []byte) ([]Message, error) {
+	bs, err := SplitMulti(b)
+	if err != nil {
+		return nil, err
+	}
+
+	msgs := make([]Message, 0, len(bs))
+	for _, mb := range bs {
+		m, err := Parse(mb)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs, nil
+}
+
+// MarshalMulti packs the session related messages into one byte sequence,
+// with the FO flag set on all the messages but the last one.
+//
+// The FO flag in the messages given is overwritten. It returns
+// ErrNotSessionRelated if any of the messages has no SEID in its header.
+func MarshalMulti(msgs ...Message) ([]byte, error) {
+	l := 0
+	for i, m := range msgs {
+		h, ok := m.(interface {
+			HasSEID() bool
+			SetFO(bool)
+		})
+		if !ok || !h.HasSEID() {
+			return nil, ErrNotSessionRelated
+		}
+		h.SetFO(i < len(msgs)-1)
+		l += m.MarshalLen()
+	}
+
+	b := make([]byte, l)
+	offset := 0
+	for _, m := range msgs {
+		if err := m.MarshalTo(b[offset:]); err != nil {
+			return nil, err
+		}
+		offset += m.MarshalLen()
+	}
+	return b, nil
+}
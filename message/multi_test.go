@@ -0,0 +1,69 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package message_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+func TestMultiMessages(t *testing.T) {
+	msgs := []message.Message{
+		message.NewSessionModificationRequest(mp, fo, seid, seq, pri, ie.NewRemovePDR(ie.NewPDRID(1))),
+		message.NewSessionDeletionRequest(mp, fo, seid+1, seq+1, pri),
+		message.NewSessionReportRequest(mp, fo, seid+2, seq+2, pri, ie.NewReportType(0, 0, 0, 1)),
+	}
+
+	b, err := message.MarshalMulti(msgs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := message.ParseMulti(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed) != len(msgs) {
+		t.Fatalf("got %d messages want %d", len(parsed), len(msgs))
+	}
+	for i, m := range parsed {
+		if m.MessageType() != msgs[i].MessageType() || m.SEID() != msgs[i].SEID() || m.Sequence() != msgs[i].Sequence() {
+			t.Errorf("got unexpected message at %d: %v", i, m)
+		}
+	}
+
+	if h := parsed[0].(*message.SessionModificationRequest).Header; !h.HasFO() {
+		t.Error("no FO flag in the first message")
+	}
+	if h := parsed[2].(*message.SessionReportRequest).Header; h.HasFO() {
+		t.Error("FO flag in the last message")
+	}
+	if r := parsed[0].(*message.SessionModificationRequest); len(r.RemovePDR) != 1 || len(r.IEs) != 0 {
+		t.Errorf("got IEs of the other messages: %v", r)
+	}
+
+	// single message without FO.
+	single, err := msgs[1].(*message.SessionDeletionRequest).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed, err := message.ParseMulti(single); err != nil || len(parsed) != 1 {
+		t.Errorf("got %v, %v for a single message", parsed, err)
+	}
+
+	// truncated in the second message.
+	if _, err := message.ParseMulti(b[:len(b)-4]); err == nil {
+		t.Error("parsed truncated messages")
+	}
+
+	hb := message.NewHeartbeatRequest(seq, ie.NewRecoveryTimeStamp(time.Now()), nil)
+	if _, err := message.MarshalMulti(msgs[0], hb); !errors.Is(err, message.ErrNotSessionRelated) {
+		t.Errorf("got %v want %v", err, message.ErrNotSessionRelated)
+	}
+}
@@ -216,3 +216,17 @@ func (m *NodeReportRequest) MessageTypeName() string {
 func (m *NodeReportRequest) SEID() uint64 {
 	return m.Header.seid()
 }
+
+// Validate checks that NodeReportRequest has a valid NodeID and NodeReportType,
+// and the report indicated by each flag in NodeReportType, e.g.,
+// UserPlanePathFailureReport for UPFR.
+func (m *NodeReportRequest) Validate() error {
+	v := newValidator(MsgTypeNodeReportRequest)
+	v.mandatory(m.NodeID, ie.NodeID)
+	v.mandatory(m.NodeReportType, ie.NodeReportType)
+	v.conditional(hasFlag(m.NodeReportType, ie.NodeReportType, has1stBit), m.UserPlanePathFailureReport, ie.UserPlanePathFailureReport)
+	v.conditional(hasFlag(m.NodeReportType, ie.NodeReportType, has2ndBit), m.UserPlanePathRecoveryReport, ie.UserPlanePathRecoveryReport)
+	v.conditionalMulti(hasFlag(m.NodeReportType, ie.NodeReportType, has3rdBit), m.ClockDriftReport, ie.ClockDriftReport)
+	v.conditionalMulti(hasFlag(m.NodeReportType, ie.NodeReportType, has4thBit), m.GTPUPathQoSReport, ie.GTPUPathQoSReport)
+	return v.err
+}
@@ -165,3 +165,12 @@ func (m *NodeReportResponse) MessageTypeName() string {
 func (m *NodeReportResponse) SEID() uint64 {
 	return m.Header.seid()
 }
+
+// Validate checks that NodeReportResponse has a valid NodeID and Cause, and
+// OffendingIE if the Cause indicates an IE is missing or incorrect.
+func (m *NodeReportResponse) Validate() error {
+	v := newValidator(MsgTypeNodeReportResponse)
+	v.mandatory(m.NodeID, ie.NodeID)
+	v.cause(m.Cause, m.OffendingIE)
+	return v.err
+}
@@ -146,3 +146,11 @@ func (m *PFDManagementRequest) MessageTypeName() string {
 func (m *PFDManagementRequest) SEID() uint64 {
 	return m.Header.seid()
 }
+
+// Validate checks that all the ApplicationIDsPFDs in PFDManagementRequest can
+// be decoded. The request without them is valid, which removes all the PFDs.
+func (m *PFDManagementRequest) Validate() error {
+	v := newValidator(MsgTypePFDManagementRequest)
+	v.optionalMulti(m.ApplicationIDsPFDs, ie.ApplicationIDsPFDs)
+	return v.err
+}
@@ -166,3 +166,11 @@ func (m *PFDManagementResponse) MessageTypeName() string {
 func (m *PFDManagementResponse) SEID() uint64 {
 	return m.Header.seid()
 }
+
+// Validate checks that PFDManagementResponse has a valid Cause, and OffendingIE
+// if the Cause indicates an IE is missing or incorrect.
+func (m *PFDManagementResponse) Validate() error {
+	v := newValidator(MsgTypePFDManagementResponse)
+	v.cause(m.Cause, m.OffendingIE)
+	return v.err
+}
@@ -119,3 +119,8 @@ func (m *SessionDeletionRequest) MessageTypeName() string {
 func (m *SessionDeletionRequest) SEID() uint64 {
 	return m.Header.seid()
 }
+
+// Validate always returns nil, as SessionDeletionRequest has no IE required.
+func (m *SessionDeletionRequest) Validate() error {
+	return nil
+}
@@ -219,3 +219,15 @@ func (m *SessionDeletionResponse) MessageTypeName() string {
 func (m *SessionDeletionResponse) SEID() uint64 {
 	return m.Header.seid()
 }
+
+// Validate checks that SessionDeletionResponse has a valid Cause, and
+// OffendingIE if the Cause indicates an IE is missing or incorrect.
+func (m *SessionDeletionResponse) Validate() error {
+	v := newValidator(MsgTypeSessionDeletionResponse)
+	v.cause(m.Cause, m.OffendingIE)
+	v.optional(m.LoadControlInformation, ie.LoadControlInformation)
+	v.optional(m.OverloadControlInformation, ie.OverloadControlInformation)
+	v.optionalMulti(m.UsageReport, ie.UsageReportWithinSessionDeletionResponse)
+	v.optional(m.AdditionalUsageReportsInformation, ie.AdditionalUsageReportsInformation)
+	return v.err
+}
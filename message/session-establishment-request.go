@@ -67,7 +67,7 @@ func NewSessionEstablishmentRequest(mp, fo uint8, seid uint64, seq uint32, pri u
 		case ie.PDNType:
 			m.PDNType = i
 		case ie.FQCSID:
-			m.FQCSID = i
+			m.FQCSID, m.IEs = addFQCSID(m.FQCSID, m.IEs, i)
 		case ie.UserPlaneInactivityTimer:
 			m.UserPlaneInactivityTimer = i
 		case ie.UserID:
@@ -296,7 +296,7 @@ func (m *SessionEstablishmentRequest) UnmarshalBinary(b []byte) error {
 		case ie.PDNType:
 			m.PDNType = i
 		case ie.FQCSID:
-			m.FQCSID = i
+			m.FQCSID, m.IEs = addFQCSID(m.FQCSID, m.IEs, i)
 		case ie.UserPlaneInactivityTimer:
 			m.UserPlaneInactivityTimer = i
 		case ie.UserID:
@@ -414,3 +414,31 @@ func (m *SessionEstablishmentRequest) MessageTypeName() string {
 func (m *SessionEstablishmentRequest) SEID() uint64 {
 	return m.Header.seid()
 }
+
+// Validate checks that SessionEstablishmentRequest has a valid NodeID and
+// CP F-SEID, and at least one CreatePDR and CreateFAR. The rules of each kind
+// must have the IDs that are unique in the request.
+func (m *SessionEstablishmentRequest) Validate() error {
+	v := newValidator(MsgTypeSessionEstablishmentRequest)
+	v.mandatory(m.NodeID, ie.NodeID)
+	v.mandatory(m.CPFSEID, ie.FSEID)
+	v.mandatoryMulti(m.CreatePDR, ie.CreatePDR)
+	v.mandatoryMulti(m.CreateFAR, ie.CreateFAR)
+	v.optionalMulti(m.CreateURR, ie.CreateURR)
+	v.optionalMulti(m.CreateQER, ie.CreateQER)
+	v.optional(m.CreateBAR, ie.CreateBAR)
+	v.optionalMulti(m.CreateTrafficEndpoint, ie.CreateTrafficEndpoint)
+	v.optional(m.PDNType, ie.PDNType)
+	v.optional(m.FQCSID, ie.FQCSID)
+	v.optional(m.UserPlaneInactivityTimer, ie.UserPlaneInactivityTimer)
+	v.optional(m.UserID, ie.UserID)
+	v.optional(m.TraceInformation, ie.TraceInformation)
+	v.optional(m.APNDNN, ie.APNDNN)
+	v.optionalMulti(m.CreateMAR, ie.CreateMAR)
+	v.optional(m.PFCPSEReqFlags, ie.PFCPSEReqFlags)
+	v.optional(m.CreateBridgeInfoForTSC, ie.CreateBridgeInfoForTSC)
+	v.optionalMulti(m.CreateSRR, ie.CreateSRR)
+	v.optional(m.ProvideATSSSControlInformation, ie.ProvideATSSSControlInformation)
+	v.optional(m.RecoveryTimeStamp, ie.RecoveryTimeStamp)
+	return v.err
+}
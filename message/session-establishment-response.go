@@ -302,3 +302,22 @@ func (m *SessionEstablishmentResponse) MessageTypeName() string {
 func (m *SessionEstablishmentResponse) SEID() uint64 {
 	return m.Header.seid()
 }
+
+// Validate checks that SessionEstablishmentResponse has a valid NodeID and
+// Cause, UP F-SEID if the request is accepted, and FailedRuleID if a rule
+// cannot be created.
+func (m *SessionEstablishmentResponse) Validate() error {
+	v := newValidator(MsgTypeSessionEstablishmentResponse)
+	v.mandatory(m.NodeID, ie.NodeID)
+	v.cause(m.Cause, m.OffendingIE)
+	v.conditional(hasCause(m.Cause, ie.CauseRequestAccepted), m.UPFSEID, ie.FSEID)
+	v.optionalMulti(m.CreatedPDR, ie.CreatedPDR)
+	v.optional(m.LoadControlInformation, ie.LoadControlInformation)
+	v.optional(m.OverloadControlInformation, ie.OverloadControlInformation)
+	v.optional(m.FQCSID, ie.FQCSID)
+	v.conditional(hasCause(m.Cause, ie.CauseRuleCreationModificationFailure), m.FailedRuleID, ie.FailedRuleID)
+	v.optionalMulti(m.CreatedTrafficEndpoint, ie.CreatedTrafficEndpoint)
+	v.optional(m.CreatedBridgeInfoForTSC, ie.CreatedBridgeInfoForTSC)
+	v.optional(m.ATSSSControlParameters, ie.ATSSSControlParameters)
+	return v.err
+}
@@ -109,7 +109,7 @@ func NewSessionModificationRequest(mp, fo uint8, seid uint64, seq uint32, pri ui
 		case ie.QueryURR:
 			m.QueryURR = append(m.QueryURR, i)
 		case ie.FQCSID:
-			m.FQCSID = i
+			m.FQCSID, m.IEs = addFQCSID(m.FQCSID, m.IEs, i)
 		case ie.UserPlaneInactivityTimer:
 			m.UserPlaneInactivityTimer = i
 		case ie.QueryURRReference:
@@ -466,7 +466,7 @@ func (m *SessionModificationRequest) UnmarshalBinary(b []byte) error {
 		case ie.QueryURR:
 			m.QueryURR = append(m.QueryURR, i)
 		case ie.FQCSID:
-			m.FQCSID = i
+			m.FQCSID, m.IEs = addFQCSID(m.FQCSID, m.IEs, i)
 		case ie.UserPlaneInactivityTimer:
 			m.UserPlaneInactivityTimer = i
 		case ie.QueryURRReference:
@@ -640,3 +640,47 @@ func (m *SessionModificationRequest) MessageTypeName() string {
 func (m *SessionModificationRequest) SEID() uint64 {
 	return m.Header.seid()
 }
+
+// Validate checks that the rules to be created, updated or removed in
+// SessionModificationRequest have the IDs that are unique among the ones of
+// the same kind, and CP F-SEID is valid if present.
+func (m *SessionModificationRequest) Validate() error {
+	v := newValidator(MsgTypeSessionModificationRequest)
+	v.optional(m.CPFSEID, ie.FSEID)
+	v.optionalMulti(m.RemovePDR, ie.RemovePDR)
+	v.optionalMulti(m.RemoveFAR, ie.RemoveFAR)
+	v.optionalMulti(m.RemoveURR, ie.RemoveURR)
+	v.optionalMulti(m.RemoveQER, ie.RemoveQER)
+	v.optional(m.RemoveBAR, ie.RemoveBAR)
+	v.optionalMulti(m.RemoveTrafficEndpoint, ie.RemoveTrafficEndpoint)
+	v.optionalMulti(m.CreatePDR, ie.CreatePDR)
+	v.optionalMulti(m.CreateFAR, ie.CreateFAR)
+	v.optionalMulti(m.CreateURR, ie.CreateURR)
+	v.optionalMulti(m.CreateQER, ie.CreateQER)
+	v.optional(m.CreateBAR, ie.CreateBAR)
+	v.optionalMulti(m.CreateTrafficEndpoint, ie.CreateTrafficEndpoint)
+	v.optionalMulti(m.UpdatePDR, ie.UpdatePDR)
+	v.optionalMulti(m.UpdateFAR, ie.UpdateFAR)
+	v.optionalMulti(m.UpdateURR, ie.UpdateURR)
+	v.optionalMulti(m.UpdateQER, ie.UpdateQER)
+	v.optional(m.UpdateBAR, ie.UpdateBARWithinSessionModificationRequest)
+	v.optionalMulti(m.UpdateTrafficEndpoint, ie.UpdateTrafficEndpoint)
+	v.optional(m.PFCPSMReqFlags, ie.PFCPSMReqFlags)
+	v.optionalMulti(m.QueryURR, ie.QueryURR)
+	v.optional(m.FQCSID, ie.FQCSID)
+	v.optional(m.UserPlaneInactivityTimer, ie.UserPlaneInactivityTimer)
+	v.optional(m.QueryURRReference, ie.QueryURRReference)
+	v.optional(m.TraceInformation, ie.TraceInformation)
+	v.optionalMulti(m.RemoveMAR, ie.RemoveMAR)
+	v.optionalMulti(m.UpdateMAR, ie.UpdateMAR)
+	v.optionalMulti(m.CreateMAR, ie.CreateMAR)
+	v.optional(m.NodeID, ie.NodeID)
+	v.optional(m.PortManagementInformationForTSC, ie.PortManagementInformationForTSCWithinSessionModificationRequest)
+	v.optionalMulti(m.RemoveSRR, ie.RemoveSRR)
+	v.optionalMulti(m.CreateSRR, ie.CreateSRR)
+	v.optionalMulti(m.UpdateSRR, ie.UpdateSRR)
+	v.optional(m.ProvideATSSSControlInformation, ie.ProvideATSSSControlInformation)
+	v.optional(m.EthernetContextInformation, ie.EthernetContextInformation)
+	v.optionalMulti(m.AccessAvailabilityInformation, ie.AccessAvailabilityInformation)
+	return v.err
+}
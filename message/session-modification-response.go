@@ -304,3 +304,21 @@ func (m *SessionModificationResponse) MessageTypeName() string {
 func (m *SessionModificationResponse) SEID() uint64 {
 	return m.Header.seid()
 }
+
+// Validate checks that SessionModificationResponse has a valid Cause, and
+// FailedRuleID if a rule cannot be created or modified.
+func (m *SessionModificationResponse) Validate() error {
+	v := newValidator(MsgTypeSessionModificationResponse)
+	v.cause(m.Cause, m.OffendingIE)
+	v.optionalMulti(m.CreatedPDR, ie.CreatedPDR)
+	v.optional(m.LoadControlInformation, ie.LoadControlInformation)
+	v.optional(m.OverloadControlInformation, ie.OverloadControlInformation)
+	v.optionalMulti(m.UsageReport, ie.UsageReportWithinSessionModificationResponse)
+	v.conditional(hasCause(m.Cause, ie.CauseRuleCreationModificationFailure), m.FailedRuleID, ie.FailedRuleID)
+	v.optional(m.AdditionalUsageReportsInformation, ie.AdditionalUsageReportsInformation)
+	v.optionalMulti(m.CreatedUpdatedTrafficEndpoint, ie.CreatedTrafficEndpoint)
+	v.optional(m.CreatedBridgeInfoForTSC, ie.CreatedBridgeInfoForTSC)
+	v.optional(m.ATSSSControlParameters, ie.ATSSSControlParameters)
+	v.optionalMulti(m.UpdatedPDR, ie.UpdatedPDR)
+	return v.err
+}
@@ -302,3 +302,23 @@ func (m *SessionReportRequest) MessageTypeName() string {
 func (m *SessionReportRequest) SEID() uint64 {
 	return m.Header.seid()
 }
+
+// Validate checks that SessionReportRequest has a valid ReportType, and the
+// report indicated by each flag in it, e.g., DownlinkDataReport for DLDR.
+// OldCPFSEID must be valid if present, as it is used to take over the session.
+func (m *SessionReportRequest) Validate() error {
+	v := newValidator(MsgTypeSessionReportRequest)
+	v.mandatory(m.ReportType, ie.ReportType)
+	v.conditional(hasFlag(m.ReportType, ie.ReportType, has1stBit), m.DownlinkDataReport, ie.DownlinkDataReport)
+	v.conditionalMulti(hasFlag(m.ReportType, ie.ReportType, has2ndBit), m.UsageReport, ie.UsageReportWithinSessionReportRequest)
+	v.conditional(hasFlag(m.ReportType, ie.ReportType, has3rdBit), m.ErrorIndicationReport, ie.ErrorIndicationReport)
+	v.optional(m.LoadControlInformation, ie.LoadControlInformation)
+	v.optional(m.OverloadControlInformation, ie.OverloadControlInformation)
+	v.optional(m.AdditionalUsageReportsInformation, ie.AdditionalUsageReportsInformation)
+	v.optional(m.PFCPSRReqFlags, ie.PFCPSRReqFlags)
+	v.optional(m.OldCPFSEID, ie.FSEID)
+	v.optional(m.PacketRateStatusReport, ie.PacketRateStatusReport)
+	v.optional(m.PortManagementInformationForTSC, ie.PortManagementInformationForTSCWithinSessionReportRequest)
+	v.conditionalMulti(hasFlag(m.ReportType, ie.ReportType, has6thBit), m.SessionReport, ie.SessionReport)
+	return v.err
+}
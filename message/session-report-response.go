@@ -230,3 +230,16 @@ func (m *SessionReportResponse) MessageTypeName() string {
 func (m *SessionReportResponse) SEID() uint64 {
 	return m.Header.seid()
 }
+
+// Validate checks that SessionReportResponse has a valid Cause, and CP F-SEID
+// is valid if present, as it is used to update the peer of the session.
+func (m *SessionReportResponse) Validate() error {
+	v := newValidator(MsgTypeSessionReportResponse)
+	v.cause(m.Cause, m.OffendingIE)
+	v.optional(m.UpdateBAR, ie.UpdateBARWithinSessionReportResponse)
+	v.optional(m.PFCPSRRspFlags, ie.PFCPSRRspFlags)
+	v.optional(m.CPFSEID, ie.FSEID)
+	v.optional(m.N4UFTEID, ie.FTEID)
+	v.optional(m.AlternativeSMFIPAddress, ie.AlternativeSMFIPAddress)
+	return v.err
+}
@@ -108,7 +108,7 @@ func (m *SessionSetDeletionRequest) UnmarshalBinary(b []byte) error {
 		case ie.NodeID:
 			m.NodeID = i
 		case ie.FQCSID:
-			m.FQCSID = i
+			m.FQCSID, m.IEs = addFQCSID(m.FQCSID, m.IEs, i)
 		default:
 			m.IEs = append(m.IEs, i)
 		}
@@ -152,3 +152,13 @@ func (m *SessionSetDeletionRequest) MessageTypeName() string {
 func (m *SessionSetDeletionRequest) SEID() uint64 {
 	return m.Header.seid()
 }
+
+// Validate checks that SessionSetDeletionRequest has a valid NodeID. The
+// FQ-CSIDs are all optional, as which of them are present depends on the node
+// that sends it.
+func (m *SessionSetDeletionRequest) Validate() error {
+	v := newValidator(MsgTypeSessionSetDeletionRequest)
+	v.mandatory(m.NodeID, ie.NodeID)
+	v.optional(m.FQCSID, ie.FQCSID)
+	return v.err
+}
@@ -165,3 +165,12 @@ func (m *SessionSetDeletionResponse) MessageTypeName() string {
 func (m *SessionSetDeletionResponse) SEID() uint64 {
 	return m.Header.seid()
 }
+
+// Validate checks that SessionSetDeletionResponse has a valid NodeID and
+// Cause, and OffendingIE if the Cause indicates an IE is missing or incorrect.
+func (m *SessionSetDeletionResponse) Validate() error {
+	v := newValidator(MsgTypeSessionSetDeletionResponse)
+	v.mandatory(m.NodeID, ie.NodeID)
+	v.cause(m.Cause, m.OffendingIE)
+	return v.err
+}
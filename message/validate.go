@@ -0,0 +1,258 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package message
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+// ValidationError indicates a message is invalid, with the IE that makes it
+// invalid.
+//
+// Cause is the value that the receiver of the message should put in the
+// Cause IE of the response: ie.CauseMandatoryIEMissing,
+// ie.CauseConditionalIEMissing or ie.CauseMandatoryIEIncorrect.
+type ValidationError struct {
+	MsgType uint8
+	IEType  uint16
+	Cause   uint8
+}
+
+// Error returns message with the type of message and IE.
+func (e *ValidationError) Error() string {
+	var reason string
+	switch e.Cause {
+	case ie.CauseMandatoryIEMissing:
+		reason = "mandatory IE missing"
+	case ie.CauseConditionalIEMissing:
+		reason = "conditional IE missing"
+	default:
+		reason = "IE incorrect"
+	}
+	return fmt.Sprintf("invalid message(Type=%d): %s(Type=%d)", e.MsgType, reason, e.IEType)
+}
+
+// validator checks the presence, types, contents and multiplicity of the IEs
+// in a message. It keeps the first error found.
+type validator struct {
+	msgType uint8
+	err     error
+}
+
+func newValidator(msgType uint8) *validator {
+	return &validator{msgType: msgType}
+}
+
+func (v *validator) fail(t uint16, cause uint8) {
+	if v.err != nil {
+		return
+	}
+	v.err = &ValidationError{MsgType: v.msgType, IEType: t, Cause: cause}
+}
+
+// mandatory checks that i is present and is a valid IE of type t.
+func (v *validator) mandatory(i *ie.IE, t uint16) {
+	if i == nil {
+		v.fail(t, ie.CauseMandatoryIEMissing)
+		return
+	}
+	v.optional(i, t)
+}
+
+// conditional checks that i is present if cond is true, and is a valid IE of
+// type t if present.
+func (v *validator) conditional(cond bool, i *ie.IE, t uint16) {
+	if cond && i == nil {
+		v.fail(t, ie.CauseConditionalIEMissing)
+		return
+	}
+	v.optional(i, t)
+}
+
+// optional checks that i is a valid IE of type t if present.
+func (v *validator) optional(i *ie.IE, t uint16) {
+	if i == nil {
+		return
+	}
+	if i.Type != t || decode(i) != nil {
+		v.fail(t, ie.CauseMandatoryIEIncorrect)
+	}
+}
+
+// mandatoryMulti checks that one or more IEs are present, and they are valid
+// as checked by optionalMulti.
+func (v *validator) mandatoryMulti(ies []*ie.IE, t uint16) {
+	if len(ies) == 0 {
+		v.fail(t, ie.CauseMandatoryIEMissing)
+		return
+	}
+	v.optionalMulti(ies, t)
+}
+
+// conditionalMulti checks that one or more IEs are present if cond is true,
+// and they are valid as checked by optionalMulti.
+func (v *validator) conditionalMulti(cond bool, ies []*ie.IE, t uint16) {
+	if cond && len(ies) == 0 {
+		v.fail(t, ie.CauseConditionalIEMissing)
+		return
+	}
+	v.optionalMulti(ies, t)
+}
+
+// optionalMulti checks that all the IEs are valid IEs of type t. If they are
+// the rules, e.g., CreatePDR, each of them must have the rule ID that is not
+// the same as the others.
+func (v *validator) optionalMulti(ies []*ie.IE, t uint16) {
+	seen := make(map[string]bool)
+	for _, i := range ies {
+		if i == nil {
+			continue
+		}
+		v.optional(i, t)
+
+		idType, ok := ruleIDTypes[t]
+		if !ok || i.Type != t {
+			continue
+		}
+		id, err := ruleID(i, idType)
+		if err != nil || seen[id] {
+			v.fail(t, ie.CauseMandatoryIEIncorrect)
+			continue
+		}
+		seen[id] = true
+	}
+}
+
+// decode returns the error in decoding i, for the types of IEs whose contents
+// are used to handle the messages. Grouped IEs are checked to have the valid
+// IEs in them.
+func decode(i *ie.IE) error {
+	var err error
+	switch i.Type {
+	case ie.Cause:
+		_, err = i.Cause()
+	case ie.NodeID:
+		_, err = i.NodeID()
+	case ie.RecoveryTimeStamp:
+		_, err = i.RecoveryTimeStamp()
+	case ie.FSEID:
+		_, err = i.FSEID()
+	case ie.OffendingIE:
+		_, err = i.OffendingIE()
+	case ie.FailedRuleID:
+		_, err = i.FailedRuleID()
+	case ie.ReportType:
+		_, err = i.ReportType()
+	case ie.NodeReportType:
+		_, err = i.NodeReportType()
+	default:
+		if i.IsGrouped() {
+			_, err = ie.ParseMultiIEs(i.Payload)
+		}
+	}
+	return err
+}
+
+// ruleIDTypes are the types of the IDs in the IEs of the rules, which
+// identify each of them in a message.
+var ruleIDTypes = map[uint16]uint16{
+	ie.CreatePDR:             ie.PDRID,
+	ie.UpdatePDR:             ie.PDRID,
+	ie.RemovePDR:             ie.PDRID,
+	ie.CreatedPDR:            ie.PDRID,
+	ie.UpdatedPDR:            ie.PDRID,
+	ie.CreateFAR:             ie.FARID,
+	ie.UpdateFAR:             ie.FARID,
+	ie.RemoveFAR:             ie.FARID,
+	ie.CreateURR:             ie.URRID,
+	ie.UpdateURR:             ie.URRID,
+	ie.RemoveURR:             ie.URRID,
+	ie.QueryURR:              ie.URRID,
+	ie.CreateQER:             ie.QERID,
+	ie.UpdateQER:             ie.QERID,
+	ie.RemoveQER:             ie.QERID,
+	ie.CreateTrafficEndpoint: ie.TrafficEndpointID,
+	ie.UpdateTrafficEndpoint: ie.TrafficEndpointID,
+	ie.RemoveTrafficEndpoint: ie.TrafficEndpointID,
+	ie.CreateMAR:             ie.MARID,
+	ie.UpdateMAR:             ie.MARID,
+	ie.RemoveMAR:             ie.MARID,
+	ie.CreateSRR:             ie.SRRID,
+	ie.UpdateSRR:             ie.SRRID,
+	ie.RemoveSRR:             ie.SRRID,
+}
+
+// ruleID returns the value of the ID of idType in the grouped IE i.
+func ruleID(i *ie.IE, idType uint16) (string, error) {
+	ies, err := ie.ParseMultiIEs(i.Payload)
+	if err != nil {
+		return "", err
+	}
+	for _, x := range ies {
+		if x.Type != idType {
+			continue
+		}
+		if len(x.Payload) == 0 {
+			return "", io.ErrUnexpectedEOF
+		}
+		return string(x.Payload), nil
+	}
+	return "", ie.ErrIENotFound
+}
+
+// cause checks the mandatory Cause IE, and OffendingIE IE that is required
+// if the cause indicates the request is rejected due to an IE.
+func (v *validator) cause(cause, offending *ie.IE) {
+	v.mandatory(cause, ie.Cause)
+	v.conditional(hasCause(cause,
+		ie.CauseMandatoryIEMissing, ie.CauseConditionalIEMissing,
+		ie.CauseInvalidLength, ie.CauseMandatoryIEIncorrect,
+	), offending, ie.OffendingIE)
+}
+
+// hasCause reports whether i is Cause IE with any of the causes.
+func hasCause(i *ie.IE, causes ...uint8) bool {
+	if i == nil {
+		return false
+	}
+	c, err := i.Cause()
+	if err != nil {
+		return false
+	}
+	for _, cause := range causes {
+		if c == cause {
+			return true
+		}
+	}
+	return false
+}
+
+// hasFlag reports whether i is an IE of type t with the flag in the first
+// octet set.
+func hasFlag(i *ie.IE, t uint16, flag func(uint8) bool) bool {
+	if i == nil || i.Type != t || len(i.Payload) < 1 {
+		return false
+	}
+	return flag(i.Payload[0])
+}
+
+// Validate checks the IEs in m, if m has Validate method. All the messages
+// defined in this package have it.
+//
+// The mandatory IEs and the conditional IEs whose conditions are met must be
+// present. The IEs present must be of the right types, and the ones used to
+// handle the message, e.g., NodeID, F-SEID and the grouped IEs, must be
+// decoded successfully. The rules in a message, e.g., CreatePDRs, must have
+// their IDs, which must not be the same as each other. It returns
+// *ValidationError with the first IE that violates them.
+func Validate(m Message) error {
+	if v, ok := m.(interface{ Validate() error }); ok {
+		return v.Validate()
+	}
+	return nil
+}
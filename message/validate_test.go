@@ -0,0 +1,191 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package message_test
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+func TestValidate(t *testing.T) {
+	ts := ie.NewRecoveryTimeStamp(time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC))
+	nodeID := ie.NewNodeID("", "", "go-pfcp.epc.3gppnetwork.org")
+	fseid := ie.NewFSEID(0x1111111122222222, net.ParseIP("127.0.0.1"), nil, nil)
+	pdr := ie.NewCreatePDR(ie.NewPDRID(1), ie.NewFARID(1))
+	far := ie.NewCreateFAR(ie.NewFARID(1), ie.NewApplyAction(0x02))
+
+	cases := []struct {
+		description string
+		msg         message.Message
+		err         *message.ValidationError
+	}{
+		{
+			"HeartbeatRequest/Valid",
+			message.NewHeartbeatRequest(seq, ts, nil),
+			nil,
+		}, {
+			"HeartbeatRequest/MissingRecoveryTimeStamp",
+			message.NewHeartbeatRequest(seq, nil, nil),
+			&message.ValidationError{
+				MsgType: message.MsgTypeHeartbeatRequest, IEType: ie.RecoveryTimeStamp, Cause: ie.CauseMandatoryIEMissing,
+			},
+		}, {
+			"HeartbeatRequest/IncorrectType",
+			message.NewHeartbeatRequest(seq, nodeID, nil),
+			&message.ValidationError{
+				MsgType: message.MsgTypeHeartbeatRequest, IEType: ie.RecoveryTimeStamp, Cause: ie.CauseMandatoryIEIncorrect,
+			},
+		}, {
+			"SessionEstablishmentRequest/Valid",
+			message.NewSessionEstablishmentRequest(mp, fo, 0, seq, pri, nodeID, fseid, pdr, far),
+			nil,
+		}, {
+			"SessionEstablishmentRequest/MissingCPFSEID",
+			message.NewSessionEstablishmentRequest(mp, fo, 0, seq, pri, nodeID, pdr, far),
+			&message.ValidationError{
+				MsgType: message.MsgTypeSessionEstablishmentRequest, IEType: ie.FSEID, Cause: ie.CauseMandatoryIEMissing,
+			},
+		}, {
+			"SessionEstablishmentRequest/MissingCreateFAR",
+			message.NewSessionEstablishmentRequest(mp, fo, 0, seq, pri, nodeID, fseid, pdr),
+			&message.ValidationError{
+				MsgType: message.MsgTypeSessionEstablishmentRequest, IEType: ie.CreateFAR, Cause: ie.CauseMandatoryIEMissing,
+			},
+		}, {
+			"SessionEstablishmentRequest/UndecodableNodeID",
+			message.NewSessionEstablishmentRequest(mp, fo, 0, seq, pri, ie.New(ie.NodeID, nil), fseid, pdr, far),
+			&message.ValidationError{
+				MsgType: message.MsgTypeSessionEstablishmentRequest, IEType: ie.NodeID, Cause: ie.CauseMandatoryIEIncorrect,
+			},
+		}, {
+			"SessionEstablishmentRequest/UndecodableCPFSEID",
+			message.NewSessionEstablishmentRequest(mp, fo, 0, seq, pri, nodeID, ie.New(ie.FSEID, []byte{0x02}), pdr, far),
+			&message.ValidationError{
+				MsgType: message.MsgTypeSessionEstablishmentRequest, IEType: ie.FSEID, Cause: ie.CauseMandatoryIEIncorrect,
+			},
+		}, {
+			"SessionEstablishmentRequest/DuplicatePDRID",
+			message.NewSessionEstablishmentRequest(mp, fo, 0, seq, pri, nodeID, fseid,
+				pdr, ie.NewCreatePDR(ie.NewPDRID(1), ie.NewFARID(2)), far,
+			),
+			&message.ValidationError{
+				MsgType: message.MsgTypeSessionEstablishmentRequest, IEType: ie.CreatePDR, Cause: ie.CauseMandatoryIEIncorrect,
+			},
+		}, {
+			"SessionEstablishmentRequest/MissingPDRID",
+			message.NewSessionEstablishmentRequest(mp, fo, 0, seq, pri, nodeID, fseid,
+				ie.NewCreatePDR(ie.NewPrecedence(100), ie.NewFARID(1)), far,
+			),
+			&message.ValidationError{
+				MsgType: message.MsgTypeSessionEstablishmentRequest, IEType: ie.CreatePDR, Cause: ie.CauseMandatoryIEIncorrect,
+			},
+		}, {
+			"SessionModificationRequest/SameIDsInDifferentKinds",
+			message.NewSessionModificationRequest(mp, fo, seid, seq, pri,
+				ie.NewRemoveFAR(ie.NewFARID(1)), ie.NewUpdateFAR(ie.NewFARID(1), ie.NewApplyAction(0x02)),
+			),
+			nil,
+		}, {
+			"SessionEstablishmentResponse/MissingUPFSEID",
+			message.NewSessionEstablishmentResponse(mp, fo, seid, seq, pri,
+				nodeID, ie.NewCause(ie.CauseRequestAccepted),
+			),
+			&message.ValidationError{
+				MsgType: message.MsgTypeSessionEstablishmentResponse, IEType: ie.FSEID, Cause: ie.CauseConditionalIEMissing,
+			},
+		}, {
+			"SessionEstablishmentResponse/Rejected",
+			message.NewSessionEstablishmentResponse(mp, fo, seid, seq, pri,
+				nodeID, ie.NewCause(ie.CauseMandatoryIEMissing), ie.NewOffendingIE(ie.FSEID),
+			),
+			nil,
+		}, {
+			"SessionModificationResponse/MissingFailedRuleID",
+			message.NewSessionModificationResponse(mp, fo, seid, seq, pri,
+				ie.NewCause(ie.CauseRuleCreationModificationFailure),
+			),
+			&message.ValidationError{
+				MsgType: message.MsgTypeSessionModificationResponse, IEType: ie.FailedRuleID, Cause: ie.CauseConditionalIEMissing,
+			},
+		}, {
+			"SessionReportRequest/MissingUsageReport",
+			message.NewSessionReportRequest(mp, fo, seid, seq, pri, ie.NewReportType(0, 0, 1, 0)),
+			&message.ValidationError{
+				MsgType: message.MsgTypeSessionReportRequest, IEType: ie.UsageReportWithinSessionReportRequest, Cause: ie.CauseConditionalIEMissing,
+			},
+		}, {
+			"NodeReportRequest/MissingUserPlanePathFailureReport",
+			message.NewNodeReportRequest(seq, nodeID, ie.NewNodeReportType(0x01)),
+			&message.ValidationError{
+				MsgType: message.MsgTypeNodeReportRequest, IEType: ie.UserPlanePathFailureReport, Cause: ie.CauseConditionalIEMissing,
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			err := message.Validate(c.msg)
+			if c.err == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			var verr *message.ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("got unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(c.err, verr); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestValidateParsed(t *testing.T) {
+	b, err := message.NewSessionEstablishmentRequest(mp, fo, 0, seq, pri,
+		ie.NewCreatePDR(ie.NewPDRID(1), ie.NewFARID(1)),
+		ie.NewCreateFAR(ie.NewFARID(1), ie.NewApplyAction(0x02)),
+	).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Parse accepts the message without NodeID and CP F-SEID.
+	msg, err := message.Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := message.Validate(msg); err == nil {
+		t.Error("no error for the message without mandatory IEs")
+	}
+}
+
+func TestValidateParsedNodeID(t *testing.T) {
+	b, err := message.NewAssociationSetupRequest(seq,
+		ie.New(ie.NodeID, []byte{0x0f, 0x7f}), // unknown Node ID type
+		ie.NewRecoveryTimeStamp(time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)),
+	).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Parse does not decode the contents of NodeID.
+	msg, err := message.Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var verr *message.ValidationError
+	if err := message.Validate(msg); !errors.As(err, &verr) || verr.IEType != ie.NodeID || verr.Cause != ie.CauseMandatoryIEIncorrect {
+		t.Errorf("got %v, want incorrect NodeID", err)
+	}
+}
@@ -119,3 +119,8 @@ func (m *VersionNotSupportedResponse) MessageTypeName() string {
 func (m *VersionNotSupportedResponse) SEID() uint64 {
 	return m.Header.seid()
 }
+
+// Validate always returns nil, as VersionNotSupportedResponse has no IE.
+func (m *VersionNotSupportedResponse) Validate() error {
+	return nil
+}
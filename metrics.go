@@ -0,0 +1,222 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package pfcp
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// Metrics receives the events to be measured from Conn, the association
+// managers and SessionRegistry.
+//
+// msgType is the name of the message type returned by MessageTypeName, and
+// cause is the value of the Cause in the message, or 0 if it has none.
+//
+// The methods may be called from multiple goroutines simultaneously, and
+// should return quickly as they are called in the middle of the handling of
+// the messages.
+type Metrics interface {
+	// MessageSent is called for each message sent, except the
+	// retransmissions of the requests.
+	MessageSent(msgType string, cause uint8)
+	// MessageReceived is called for each message received and decoded.
+	MessageReceived(msgType string, cause uint8)
+	// Retransmission is called each time a request is sent again.
+	Retransmission(msgType string)
+	// Timeout is called when a request is left unanswered after all the
+	// retransmissions.
+	Timeout(msgType string)
+	// RequestLatency is called with the time from the first transmission of
+	// a request to the arrival of the response to it.
+	RequestLatency(msgType string, d time.Duration)
+	// ActiveAssociations is called with the number of the associations
+	// established, each time it may have changed.
+	ActiveAssociations(n int)
+	// ActiveSessions is called with the number of the sessions registered,
+	// each time it may have changed.
+	ActiveSessions(n int)
+}
+
+// noMetrics is the Metrics used when none is given.
+type noMetrics struct{}
+
+func (noMetrics) MessageSent(string, uint8)            {}
+func (noMetrics) MessageReceived(string, uint8)        {}
+func (noMetrics) Retransmission(string)                {}
+func (noMetrics) Timeout(string)                       {}
+func (noMetrics) RequestLatency(string, time.Duration) {}
+func (noMetrics) ActiveAssociations(int)               {}
+func (noMetrics) ActiveSessions(int)                   {}
+
+// DefaultLatencyBuckets are the upper bounds of the buckets of the request
+// latency histogram in ExpvarMetrics.
+var DefaultLatencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// ExpvarMetrics is the Metrics that publishes the values with expvar, as an
+// expvar.Map with the following keys.
+//
+//   - "sent", "received": the number of the messages by "type/cause",
+//     e.g., "Session Establishment Response/1", or "type" for the messages
+//     without Cause.
+//   - "retransmissions", "timeouts": the number of the events by type.
+//   - "latency": the histogram of the request latency by type, which has the
+//     cumulative count of each bucket by the upper bound, e.g., "10ms" and
+//     "+Inf", and "count" and "sum_us"(the sum in microseconds).
+//   - "associations", "sessions": the number of the active ones.
+type ExpvarMetrics struct {
+	vars            *expvar.Map
+	sent            *expvar.Map
+	received        *expvar.Map
+	retransmissions *expvar.Map
+	timeouts        *expvar.Map
+	latency         *expvar.Map
+	associations    *expvar.Int
+	sessions        *expvar.Int
+	buckets         []time.Duration
+
+	mu sync.Mutex
+}
+
+// NewExpvarMetrics creates a new ExpvarMetrics published with name, with
+// the latency buckets given by DefaultLatencyBuckets.
+//
+// It panics if name is already used, like expvar.Publish.
+func NewExpvarMetrics(name string) *ExpvarMetrics {
+	m := &ExpvarMetrics{
+		vars:            new(expvar.Map).Init(),
+		sent:            new(expvar.Map).Init(),
+		received:        new(expvar.Map).Init(),
+		retransmissions: new(expvar.Map).Init(),
+		timeouts:        new(expvar.Map).Init(),
+		latency:         new(expvar.Map).Init(),
+		associations:    new(expvar.Int),
+		sessions:        new(expvar.Int),
+		buckets:         DefaultLatencyBuckets,
+	}
+	m.vars.Set("sent", m.sent)
+	m.vars.Set("received", m.received)
+	m.vars.Set("retransmissions", m.retransmissions)
+	m.vars.Set("timeouts", m.timeouts)
+	m.vars.Set("latency", m.latency)
+	m.vars.Set("associations", m.associations)
+	m.vars.Set("sessions", m.sessions)
+
+	expvar.Publish(name, m.vars)
+	return m
+}
+
+// Vars returns the expvar.Map that has all the values.
+func (m *ExpvarMetrics) Vars() *expvar.Map {
+	return m.vars
+}
+
+// MessageSent increments "sent" of msgType and cause.
+func (m *ExpvarMetrics) MessageSent(msgType string, cause uint8) {
+	m.sent.Add(messageKey(msgType, cause), 1)
+}
+
+// MessageReceived increments "received" of msgType and cause.
+func (m *ExpvarMetrics) MessageReceived(msgType string, cause uint8) {
+	m.received.Add(messageKey(msgType, cause), 1)
+}
+
+// Retransmission increments "retransmissions" of msgType.
+func (m *ExpvarMetrics) Retransmission(msgType string) {
+	m.retransmissions.Add(msgType, 1)
+}
+
+// Timeout increments "timeouts" of msgType.
+func (m *ExpvarMetrics) Timeout(msgType string) {
+	m.timeouts.Add(msgType, 1)
+}
+
+// RequestLatency observes d in the "latency" histogram of msgType.
+func (m *ExpvarMetrics) RequestLatency(msgType string, d time.Duration) {
+	m.mu.Lock()
+	h, ok := m.latency.Get(msgType).(*expvar.Map)
+	if !ok {
+		h = new(expvar.Map).Init()
+		m.latency.Set(msgType, h)
+	}
+	m.mu.Unlock()
+
+	for _, b := range m.buckets {
+		if d <= b {
+			h.Add(b.String(), 1)
+		}
+	}
+	h.Add("+Inf", 1)
+	h.Add("count", 1)
+	h.Add("sum_us", d.Microseconds())
+}
+
+// ActiveAssociations sets "associations" to n.
+func (m *ExpvarMetrics) ActiveAssociations(n int) {
+	m.associations.Set(int64(n))
+}
+
+// ActiveSessions sets "sessions" to n.
+func (m *ExpvarMetrics) ActiveSessions(n int) {
+	m.sessions.Set(int64(n))
+}
+
+// messageKey returns the key of a message in ExpvarMetrics.
+func messageKey(msgType string, cause uint8) string {
+	if cause == 0 {
+		return msgType
+	}
+	return fmt.Sprintf("%s/%d", msgType, cause)
+}
+
+// causeOf returns the value of the Cause in msg, or 0 if it has none.
+func causeOf(msg message.Message) uint8 {
+	var cause *ie.IE
+	switch m := msg.(type) {
+	case *message.PFDManagementResponse:
+		cause = m.Cause
+	case *message.AssociationSetupResponse:
+		cause = m.Cause
+	case *message.AssociationUpdateResponse:
+		cause = m.Cause
+	case *message.AssociationReleaseResponse:
+		cause = m.Cause
+	case *message.NodeReportResponse:
+		cause = m.Cause
+	case *message.SessionSetDeletionResponse:
+		cause = m.Cause
+	case *message.SessionEstablishmentResponse:
+		cause = m.Cause
+	case *message.SessionModificationResponse:
+		cause = m.Cause
+	case *message.SessionDeletionResponse:
+		cause = m.Cause
+	case *message.SessionReportResponse:
+		cause = m.Cause
+	}
+	if cause == nil {
+		return 0
+	}
+
+	c, err := cause.Cause()
+	if err != nil {
+		return 0
+	}
+	return c
+}
@@ -0,0 +1,126 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package pfcp_test
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wmnsk/go-pfcp"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+var metricsSeq int32
+
+// newMetrics returns a new ExpvarMetrics with the name unique in the process.
+func newMetrics(t *testing.T) *pfcp.ExpvarMetrics {
+	t.Helper()
+	return pfcp.NewExpvarMetrics(fmt.Sprintf("%s_%d", t.Name(), atomic.AddInt32(&metricsSeq, 1)))
+}
+
+// metric returns the value of the key in the map of name in m, or "" if it
+// does not exist.
+func metric(m *pfcp.ExpvarMetrics, name, key string) string {
+	v, ok := m.Vars().Get(name).(*expvar.Map)
+	if !ok {
+		return ""
+	}
+	if i := v.Get(key); i != nil {
+		return i.String()
+	}
+	return ""
+}
+
+func TestConnMetrics(t *testing.T) {
+	srvMetrics, cliMetrics := newMetrics(t), newMetrics(t)
+	srv := listen(t, func(c *pfcp.Conn) {
+		c.Handler = heartbeatResponder{}
+		c.Metrics = srvMetrics
+	})
+	cli := listen(t, func(c *pfcp.Conn) {
+		c.T1 = 50 * time.Millisecond
+		c.N1 = 1
+		c.Metrics = cliMetrics
+	})
+
+	if _, err := cli.Request(context.Background(), message.NewHeartbeatRequest(0, ie.NewRecoveryTimeStamp(ts), nil), srv.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		m         *pfcp.ExpvarMetrics
+		name, key string
+		want      string
+	}{
+		{cliMetrics, "sent", "Heartbeat Request", "1"},
+		{cliMetrics, "received", "Heartbeat Response", "1"},
+	} {
+		if got := metric(c.m, c.name, c.key); got != c.want {
+			t.Errorf("%s[%s]: got %q, want %q", c.name, c.key, got, c.want)
+		}
+	}
+
+	h, ok := cliMetrics.Vars().Get("latency").(*expvar.Map).Get("Heartbeat Request").(*expvar.Map)
+	if !ok {
+		t.Fatal("no latency recorded")
+	}
+	if got := h.Get("count").String(); got != "1" {
+		t.Errorf("got latency count %s", got)
+	}
+	if got := h.Get("5s").String(); got != "1" {
+		t.Errorf("got latency bucket 5s %s", got)
+	}
+
+	// the request to the peer that never answers.
+	pc, _ := listenRaw(t)
+	_, err := cli.Request(context.Background(), message.NewHeartbeatRequest(0, ie.NewRecoveryTimeStamp(ts), nil), pc.LocalAddr())
+	if !errors.Is(err, pfcp.ErrTimeout) {
+		t.Fatalf("got %v, want ErrTimeout", err)
+	}
+	if got := metric(cliMetrics, "retransmissions", "Heartbeat Request"); got != "1" {
+		t.Errorf("got %q retransmissions", got)
+	}
+	if got := metric(cliMetrics, "timeouts", "Heartbeat Request"); got != "1" {
+		t.Errorf("got %q timeouts", got)
+	}
+
+	// the server counts its response after sending it.
+	if got := metric(srvMetrics, "received", "Heartbeat Request"); got != "1" {
+		t.Errorf("server got %q requests", got)
+	}
+	if got := metric(srvMetrics, "sent", "Heartbeat Response"); got != "1" {
+		t.Errorf("server sent %q responses", got)
+	}
+}
+
+func TestSessionRegistryMetrics(t *testing.T) {
+	m := newMetrics(t)
+	r := pfcp.NewSessionRegistry()
+	r.Metrics = m
+
+	peer := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8805}
+	s, err := r.New(peer, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.New(peer, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Vars().Get("sessions").String(); got != "2" {
+		t.Errorf("got %s sessions", got)
+	}
+
+	r.Delete(s.LocalSEID)
+	if got := m.Vars().Get("sessions").String(); got != "1" {
+		t.Errorf("got %s sessions", got)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package pfcp
+
+import (
+	"net"
+	"sync"
+
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// ServeMux is a PFCP request multiplexer.
+//
+// It dispatches each request to the handler registered for its message type.
+// The requests with no handler are answered with the Cause "Service not
+// supported", or silently discarded if DropUnhandled is true. The requests that
+// have no Cause in its response(e.g., HeartbeatRequest) and the messages of
+// unknown type are always discarded.
+type ServeMux struct {
+	// NodeID is put in the responses sent by ServeMux itself, to the requests
+	// that have NodeID as a mandatory IE in its response.
+	NodeID *ie.IE
+	// DropUnhandled makes ServeMux discard the requests with no handler instead
+	// of responding with the Cause "Service not supported".
+	DropUnhandled bool
+
+	mu       sync.RWMutex
+	handlers map[uint8]Handler
+}
+
+// NewServeMux allocates and returns a new ServeMux.
+func NewServeMux() *ServeMux {
+	return &ServeMux{
+		handlers: make(map[uint8]Handler),
+	}
+}
+
+// Handle registers the handler for the given message type.
+//
+// If a handler already exists for mtype, Handle replaces it.
+func (mux *ServeMux) Handle(mtype uint8, handler Handler) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	if mux.handlers == nil {
+		mux.handlers = make(map[uint8]Handler)
+	}
+	mux.handlers[mtype] = handler
+}
+
+// HandleFunc registers the handler function for the given message type.
+func (mux *ServeMux) HandleFunc(mtype uint8, handler func(w ResponseWriter, peer net.Addr, msg message.Message)) {
+	mux.Handle(mtype, HandlerFunc(handler))
+}
+
+// Handler returns the handler registered for the given message type, or nil
+// if there is none.
+func (mux *ServeMux) Handler(mtype uint8) Handler {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+
+	return mux.handlers[mtype]
+}
+
+// ServePFCP dispatches the request to the handler registered for its type.
+func (mux *ServeMux) ServePFCP(w ResponseWriter, peer net.Addr, msg message.Message) {
+	if h := mux.Handler(msg.MessageType()); h != nil {
+		h.ServePFCP(w, peer, msg)
+		return
+	}
+
+	if mux.DropUnhandled {
+		loggerOf(w).Log(LogLevelDebug, "ignored message: no handler", "peer", peer, "type", msg.MessageTypeName())
+		return
+	}
+
+	rsp := newCauseResponse(w, msg, ie.CauseServiceNotSupported, mux.NodeID)
+	if rsp == nil {
+		loggerOf(w).Log(LogLevelDebug, "ignored message: no handler", "peer", peer, "type", msg.MessageTypeName())
+		return
+	}
+
+	if err := w.WriteMessage(rsp); err != nil {
+		loggerOf(w).Log(LogLevelWarn, "failed to respond to request", "peer", peer, "type", msg.MessageTypeName(), "error", err)
+	}
+}
+
+// newCauseResponse creates the response to req that has only the cause given
+// and the mandatory IEs, e.g., nodeID, with message.NewErrorResponse. The
+// RecoveryTimeStamp is the one of the Conn that w writes to, if known.
+//
+// It returns nil if the response to req has no Cause.
+func newCauseResponse(w ResponseWriter, req message.Message, cause uint8, nodeID *ie.IE) message.Message {
+	b, err := marshal(req)
+	if err != nil {
+		return nil
+	}
+
+	var ts *ie.IE
+	if r, ok := w.(*response); ok {
+		ts = ie.NewRecoveryTimeStamp(r.conn.RecoveryTimeStamp)
+	}
+	rsp, err := message.NewErrorResponse(b, &message.ValidationError{MsgType: req.MessageType(), Cause: cause}, nodeID, ts)
+	if err != nil {
+		return nil
+	}
+	return rsp
+}
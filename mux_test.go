@@ -0,0 +1,96 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package pfcp_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/wmnsk/go-pfcp"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+func TestServeMux(t *testing.T) {
+	mux := pfcp.NewServeMux()
+	mux.NodeID = ie.NewNodeID("127.0.0.1", "", "")
+	mux.HandleFunc(message.MsgTypeSessionEstablishmentRequest, func(w pfcp.ResponseWriter, peer net.Addr, msg message.Message) {
+		_ = w.WriteMessage(message.NewSessionEstablishmentResponse(
+			0, 0, 0, 0, 0,
+			ie.NewNodeID("127.0.0.1", "", ""),
+			ie.NewCause(ie.CauseRequestAccepted),
+		))
+	})
+
+	srv := listen(t, func(c *pfcp.Conn) {
+		c.Handler = mux
+	})
+	cli := listen(t, nil)
+
+	t.Run("Handled", func(t *testing.T) {
+		req := message.NewSessionEstablishmentRequest(
+			0, 0, 0, 0, 0,
+			ie.NewNodeID("127.0.0.2", "", ""),
+			ie.NewFSEID(0xdeadbeef, net.ParseIP("127.0.0.2"), nil, nil),
+		)
+		rsp, err := cli.Request(context.Background(), req, srv.LocalAddr())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok := rsp.(*message.SessionEstablishmentResponse); !ok {
+			t.Fatalf("got unexpected response: %s", rsp.MessageTypeName())
+		}
+		if got, want := rsp.Sequence(), req.Sequence(); got != want {
+			t.Errorf("got SequenceNumber %#x want %#x", got, want)
+		}
+		if got, want := rsp.SEID(), uint64(0xdeadbeef); got != want {
+			t.Errorf("got SEID %#x want %#x", got, want)
+		}
+	})
+
+	t.Run("Unhandled", func(t *testing.T) {
+		rsp, err := cli.Request(context.Background(), message.NewAssociationSetupRequest(0, ie.NewNodeID("127.0.0.2", "", "")), srv.LocalAddr())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		asrsp, ok := rsp.(*message.AssociationSetupResponse)
+		if !ok {
+			t.Fatalf("got unexpected response: %s", rsp.MessageTypeName())
+		}
+		cause, err := asrsp.Cause.Cause()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := cause, ie.CauseServiceNotSupported; got != want {
+			t.Errorf("got Cause %d want %d", got, want)
+		}
+		if asrsp.NodeID == nil {
+			t.Error("NodeID is missing")
+		}
+	})
+}
+
+func TestServeMuxDropUnhandled(t *testing.T) {
+	mux := pfcp.NewServeMux()
+	mux.DropUnhandled = true
+
+	srv := listen(t, func(c *pfcp.Conn) {
+		c.Handler = mux
+	})
+	cli := listen(t, func(c *pfcp.Conn) {
+		c.T1 = 10 * time.Millisecond
+		c.N1 = 1
+	})
+
+	_, err := cli.Request(context.Background(), message.NewSessionDeletionRequest(0, 0, 1, 0, 0), srv.LocalAddr())
+	if !errors.Is(err, pfcp.ErrTimeout) {
+		t.Fatalf("got %v want %v", err, pfcp.ErrTimeout)
+	}
+}
@@ -0,0 +1,385 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package pfcp
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// DefaultOverloadPeriod is the default validity period of the overload
+// control advertised by OverloadReporter.
+const DefaultOverloadPeriod = 30 * time.Second
+
+// OverloadProvider provides the current overload of a UP function, as the
+// percentage of the session related requests the CP functions are asked to
+// reduce, from 0(not overloaded) to 100 as defined for the Metric IE.
+type OverloadProvider interface {
+	Overload() uint8
+}
+
+// OverloadProviderFunc is an adapter to allow the use of ordinary functions
+// as OverloadProvider.
+type OverloadProviderFunc func() uint8
+
+// Overload calls f().
+func (f OverloadProviderFunc) Overload() uint8 {
+	return f()
+}
+
+// OverloadReporter advertises the overload of a UP function to the CP
+// functions with OverloadControlInformation, as defined in TS 29.244 §6.2.4.
+//
+// The overload is sampled from Provider every time a message is about to be
+// sent. When it has changed since the last advertised one, the sequence
+// number is incremented, and the new OverloadControlInformation is put in the
+// next message to each peer. The peers that have already received the latest
+// one do not get it again, and the ones that have never received any do not
+// get it while the UP function is not overloaded.
+type OverloadReporter struct {
+	// Provider provides the current overload. It must not be nil.
+	Provider OverloadProvider
+	// Period is the validity period of the overload control advertised.
+	Period time.Duration
+	// AssociateNodeID sets the AOCI flag to associate the overload control
+	// with the Node ID of the UP function instead of its IP address.
+	AssociateNodeID bool
+
+	mu        sync.Mutex
+	sequence  uint32
+	reduction uint8
+	sent      map[string]uint32
+}
+
+// NewOverloadReporter creates a new OverloadReporter with p.
+//
+// Period is set to DefaultOverloadPeriod.
+func NewOverloadReporter(p OverloadProvider) *OverloadReporter {
+	return &OverloadReporter{
+		Provider: p,
+		Period:   DefaultOverloadPeriod,
+		sent:     make(map[string]uint32),
+	}
+}
+
+// Current samples the overload and returns the OverloadControlInformation to
+// be advertised, which has a new sequence number if the overload has changed.
+func (r *OverloadReporter) Current() *ie.IE {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.update()
+}
+
+func (r *OverloadReporter) update() *ie.IE {
+	reduction := r.Provider.Overload()
+	if reduction > 100 {
+		reduction = 100
+	}
+
+	if reduction != r.reduction {
+		r.sequence++
+		r.reduction = reduction
+	}
+
+	// the overload control ends with the zero period.
+	period := r.Period
+	if r.reduction == 0 {
+		period = 0
+	}
+
+	ies := []*ie.IE{
+		ie.NewSequenceNumber(r.sequence),
+		ie.NewMetric(r.reduction),
+		ie.NewTimer(period),
+	}
+	if r.AssociateNodeID {
+		ies = append(ies, ie.NewOCIFlags(0x01))
+	}
+	return ie.NewOverloadControlInformation(ies...)
+}
+
+// Attach puts OverloadControlInformation in msg to be sent to peer, if peer
+// has not received the latest one. It reports whether msg is modified.
+//
+// msg must be SessionEstablishmentResponse, SessionModificationResponse,
+// SessionDeletionResponse or SessionReportRequest, which can carry it.
+// msg that already has OverloadControlInformation is left as it is.
+func (r *OverloadReporter) Attach(msg message.Message, peer net.Addr) bool {
+	if i, ok := overloadControlInformation(msg); !ok || i != nil {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	oci := r.update()
+	key := peer.String()
+	seq, ok := r.sent[key]
+	if ok && seq == r.sequence {
+		return false
+	}
+	if !ok && r.reduction == 0 {
+		return false
+	}
+
+	setOverloadControlInformation(msg, oci)
+	r.sent[key] = r.sequence
+	return true
+}
+
+// Forget makes the next message to peer have OverloadControlInformation if
+// the UP function is overloaded, e.g., after the association with it is set
+// up again.
+func (r *OverloadReporter) Forget(peer net.Addr) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.sent, peer.String())
+}
+
+// Handler returns a Handler that calls h with the ResponseWriter that puts
+// OverloadControlInformation in the responses with Attach.
+func (r *OverloadReporter) Handler(h Handler) Handler {
+	return HandlerFunc(func(w ResponseWriter, peer net.Addr, msg message.Message) {
+		h.ServePFCP(&overloadResponse{ResponseWriter: w, reporter: r, peer: peer}, peer, msg)
+	})
+}
+
+// overloadResponse is the ResponseWriter given by OverloadReporter.Handler.
+type overloadResponse struct {
+	ResponseWriter
+	reporter *OverloadReporter
+	peer     net.Addr
+}
+
+// WriteMessage sends msg with OverloadControlInformation if needed.
+func (r *overloadResponse) WriteMessage(msg message.Message) error {
+	r.reporter.Attach(msg, r.peer)
+	return r.ResponseWriter.WriteMessage(msg)
+}
+
+func (r *overloadResponse) log() Logger {
+	return loggerOf(r.ResponseWriter)
+}
+
+// PeerOverload is the overload of a UP function advertised in
+// OverloadControlInformation.
+type PeerOverload struct {
+	// Reduction is the percentage of the requests to be throttled.
+	Reduction uint8
+	// Sequence is the sequence number of the OverloadControlInformation.
+	Sequence uint32
+	// Expires is when the overload control ends.
+	Expires time.Time
+
+	// throttled accumulates Reduction for each request to throttle one
+	// every time it reaches 100.
+	throttled int
+}
+
+// Active reports whether the overload control is in effect at t.
+func (p PeerOverload) Active(t time.Time) bool {
+	return p.Reduction > 0 && t.Before(p.Expires)
+}
+
+// OverloadTracker keeps the overload advertised by each UP function in
+// OverloadControlInformation, and throttles the session related requests to
+// the overloaded ones, as defined in TS 29.244 §6.2.4.
+//
+// The overload control is associated with the Node ID of the UP function if
+// the AOCI flag is set in OverloadControlInformation, and with its IP address
+// otherwise. CPAssociationManager updates it with the responses to the
+// session related requests and throttles the requests with it if it is set
+// to CPAssociationManager.Overload.
+type OverloadTracker struct {
+	// Clock gives the time the overload controls expire. The system clock is
+	// used if nil.
+	Clock Clock
+
+	mu    sync.Mutex
+	peers map[string]*PeerOverload
+}
+
+// NewOverloadTracker creates a new OverloadTracker.
+func NewOverloadTracker() *OverloadTracker {
+	return &OverloadTracker{
+		peers: make(map[string]*PeerOverload),
+	}
+}
+
+// Update stores the overload in oci advertised by the peer of nodeID at the
+// address peer.
+//
+// It reports whether the overload is updated. The OverloadControlInformation
+// with a sequence number not newer than the stored one is ignored.
+func (t *OverloadTracker) Update(nodeID string, peer net.Addr, oci *ie.IE) (bool, error) {
+	seq, err := oci.SequenceNumber()
+	if err != nil {
+		return false, err
+	}
+	reduction, err := oci.Metric()
+	if err != nil {
+		return false, err
+	}
+	period, err := oci.Timer()
+	if err != nil {
+		return false, err
+	}
+	if reduction > 100 {
+		reduction = 100
+	}
+
+	key := overloadKey(nodeID, peer, oci.HasAOCI())
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if p, ok := t.peers[key]; ok && !isNewerSequence(seq, p.Sequence) {
+		return false, nil
+	}
+
+	p := &PeerOverload{Reduction: reduction, Sequence: seq}
+	if period > 0 {
+		p.Expires = t.clock().Now().Add(period)
+	}
+	t.peers[key] = p
+	return true, nil
+}
+
+// Observe updates the overload with OverloadControlInformation in msg
+// received from the peer of nodeID at the address peer, if any. It reports
+// whether the overload is updated.
+func (t *OverloadTracker) Observe(nodeID string, peer net.Addr, msg message.Message) bool {
+	oci, _ := overloadControlInformation(msg)
+	if oci == nil {
+		return false
+	}
+
+	ok, err := t.Update(nodeID, peer, oci)
+	return ok && err == nil
+}
+
+// Overload returns the overload control in effect for the peer of nodeID at
+// the address peer. The one associated with the Node ID takes precedence.
+func (t *OverloadTracker) Overload(nodeID string, peer net.Addr) (PeerOverload, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p := t.active(nodeID, peer, t.clock().Now())
+	if p == nil {
+		return PeerOverload{}, false
+	}
+	return *p, true
+}
+
+// Throttle reports whether msg to the peer of nodeID at the address peer
+// should not be sent, by returning *ThrottledError.
+//
+// Only SessionEstablishmentRequest and SessionModificationRequest are
+// throttled, by the percentage advertised by the peer until the overload
+// control expires. The other messages, including SessionDeletionRequest that
+// helps the peer to recover, are never throttled.
+func (t *OverloadTracker) Throttle(nodeID string, peer net.Addr, msg message.Message) error {
+	switch msg.(type) {
+	case *message.SessionEstablishmentRequest, *message.SessionModificationRequest:
+	default:
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p := t.active(nodeID, peer, t.clock().Now())
+	if p == nil {
+		return nil
+	}
+
+	p.throttled += int(p.Reduction)
+	if p.throttled < 100 {
+		return nil
+	}
+	p.throttled -= 100
+	return &ThrottledError{NodeID: nodeID, Reduction: p.Reduction, Expires: p.Expires}
+}
+
+// Forget removes the overload of the peer of nodeID at the address peer,
+// e.g., when the association with it is released, as the sequence number
+// starts over in a new one.
+func (t *OverloadTracker) Forget(nodeID string, peer net.Addr) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.peers, overloadKey(nodeID, peer, true))
+	delete(t.peers, overloadKey(nodeID, peer, false))
+}
+
+// active returns the overload control in effect at now.
+func (t *OverloadTracker) active(nodeID string, peer net.Addr, now time.Time) *PeerOverload {
+	for _, aoci := range []bool{true, false} {
+		if p, ok := t.peers[overloadKey(nodeID, peer, aoci)]; ok && p.Active(now) {
+			return p
+		}
+	}
+	return nil
+}
+
+// clock returns the Clock of t.
+func (t *OverloadTracker) clock() Clock {
+	if t.Clock != nil {
+		return t.Clock
+	}
+	return realClock{}
+}
+
+// overloadKey returns the key of OverloadTracker, which is the Node ID if
+// aoci is true, or the IP address of peer otherwise.
+func overloadKey(nodeID string, peer net.Addr, aoci bool) string {
+	if aoci || peer == nil {
+		return "node:" + nodeID
+	}
+	if a, ok := peer.(*net.UDPAddr); ok {
+		return "addr:" + a.IP.String()
+	}
+	host, _, err := net.SplitHostPort(peer.String())
+	if err != nil {
+		return "addr:" + peer.String()
+	}
+	return "addr:" + host
+}
+
+// overloadControlInformation returns OverloadControlInformation in msg, and
+// false if msg cannot have it.
+func overloadControlInformation(msg message.Message) (*ie.IE, bool) {
+	switch m := msg.(type) {
+	case *message.SessionEstablishmentResponse:
+		return m.OverloadControlInformation, true
+	case *message.SessionModificationResponse:
+		return m.OverloadControlInformation, true
+	case *message.SessionDeletionResponse:
+		return m.OverloadControlInformation, true
+	case *message.SessionReportRequest:
+		return m.OverloadControlInformation, true
+	default:
+		return nil, false
+	}
+}
+
+func setOverloadControlInformation(msg message.Message, oci *ie.IE) {
+	switch m := msg.(type) {
+	case *message.SessionEstablishmentResponse:
+		m.OverloadControlInformation = oci
+	case *message.SessionModificationResponse:
+		m.OverloadControlInformation = oci
+	case *message.SessionDeletionResponse:
+		m.OverloadControlInformation = oci
+	case *message.SessionReportRequest:
+		m.OverloadControlInformation = oci
+	}
+}
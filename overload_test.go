@@ -0,0 +1,146 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package pfcp_test
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/wmnsk/go-pfcp"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+	"github.com/wmnsk/go-pfcp/pfcptest"
+)
+
+func TestOverloadReporter(t *testing.T) {
+	overload := uint8(0)
+	r := pfcp.NewOverloadReporter(pfcp.OverloadProviderFunc(func() uint8 { return overload }))
+	r.AssociateNodeID = true
+	cp := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8805}
+
+	attach := func() *ie.IE {
+		t.Helper()
+		rsp := message.NewSessionEstablishmentResponse(0, 0, 1, 1, 0, ie.NewCause(ie.CauseRequestAccepted))
+		r.Attach(rsp, cp)
+		return rsp.OverloadControlInformation
+	}
+
+	if oci := attach(); oci != nil {
+		t.Errorf("got OverloadControlInformation while not overloaded: %v", oci)
+	}
+
+	overload = 50
+	oci := attach()
+	if oci == nil {
+		t.Fatal("got no OverloadControlInformation")
+	}
+	if got, _ := oci.Metric(); got != 50 {
+		t.Errorf("got Metric %d", got)
+	}
+	if got, _ := oci.Timer(); got != pfcp.DefaultOverloadPeriod {
+		t.Errorf("got Timer %s", got)
+	}
+	if !oci.HasAOCI() {
+		t.Error("AOCI not set")
+	}
+	if oci := attach(); oci != nil {
+		t.Errorf("got OverloadControlInformation again: %v", oci)
+	}
+
+	// the end of overload is advertised to the peer.
+	overload = 0
+	oci = attach()
+	if oci == nil {
+		t.Fatal("got no OverloadControlInformation for the end of overload")
+	}
+	if got, _ := oci.Timer(); got != 0 {
+		t.Errorf("got Timer %s", got)
+	}
+}
+
+func TestOverloadTracker(t *testing.T) {
+	tr := pfcp.NewOverloadTracker()
+	upf := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 2), Port: 8805}
+	req := message.NewSessionEstablishmentRequest(0, 0, 0, 1, 0)
+
+	throttled := func() int {
+		n := 0
+		for i := 0; i < 100; i++ {
+			err := tr.Throttle("upf1", upf, req)
+			if err == nil {
+				continue
+			}
+			if !errors.Is(err, pfcp.ErrThrottled) {
+				t.Fatalf("got unexpected error: %v", err)
+			}
+			n++
+		}
+		return n
+	}
+
+	if n := throttled(); n != 0 {
+		t.Errorf("%d requests throttled without overload", n)
+	}
+
+	// associated with the IP address of the peer.
+	rsp := message.NewSessionEstablishmentResponse(0, 0, 1, 1, 0,
+		ie.NewCause(ie.CauseRequestAccepted),
+		ie.NewOverloadControlInformation(ie.NewSequenceNumber(1), ie.NewMetric(30), ie.NewTimer(time.Minute)),
+	)
+	if !tr.Observe("upf1", upf, rsp) {
+		t.Fatal("overload in response not observed")
+	}
+	if n := throttled(); n != 30 {
+		t.Errorf("got %d requests throttled, want 30", n)
+	}
+	if _, ok := tr.Overload("upf2", upf); !ok {
+		t.Error("overload not associated with the address")
+	}
+	if err := tr.Throttle("upf1", upf, message.NewSessionDeletionRequest(0, 0, 1, 2, 0)); err != nil {
+		t.Errorf("SessionDeletionRequest throttled: %v", err)
+	}
+
+	// associated with the Node ID, which takes precedence.
+	oci := ie.NewOverloadControlInformation(ie.NewSequenceNumber(1), ie.NewMetric(100), ie.NewTimer(time.Minute), ie.NewOCIFlags(0x01))
+	if ok, err := tr.Update("upf1", upf, oci); !ok || err != nil {
+		t.Fatalf("not updated: %v", err)
+	}
+	if n := throttled(); n != 100 {
+		t.Errorf("got %d requests throttled, want 100", n)
+	}
+
+	// the zero timer ends the overload control.
+	oci = ie.NewOverloadControlInformation(ie.NewSequenceNumber(2), ie.NewMetric(100), ie.NewTimer(0), ie.NewOCIFlags(0x01))
+	if ok, err := tr.Update("upf1", upf, oci); !ok || err != nil {
+		t.Fatalf("not updated: %v", err)
+	}
+	tr.Forget("upf1", upf)
+	if n := throttled(); n != 0 {
+		t.Errorf("%d requests throttled after overload", n)
+	}
+}
+
+func TestOverloadTrackerExpires(t *testing.T) {
+	clock := pfcptest.NewClock(ts)
+	tr := pfcp.NewOverloadTracker()
+	tr.Clock = clock
+	upf := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 2), Port: 8805}
+
+	oci := ie.NewOverloadControlInformation(ie.NewSequenceNumber(1), ie.NewMetric(50), ie.NewTimer(time.Minute))
+	if ok, err := tr.Update("upf1", upf, oci); !ok || err != nil {
+		t.Fatalf("not updated: %v", err)
+	}
+
+	clock.Advance(time.Minute - time.Second)
+	if _, ok := tr.Overload("upf1", upf); !ok {
+		t.Error("overload expired before Timer")
+	}
+	clock.Advance(time.Second)
+	if p, ok := tr.Overload("upf1", upf); ok {
+		t.Errorf("overload not expired: %+v", p)
+	}
+}
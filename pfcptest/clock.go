@@ -0,0 +1,168 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package pfcptest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wmnsk/go-pfcp"
+)
+
+// Clock is a pfcp.Clock whose time goes forward only when Advance is called,
+// so that the tests of the timers and the latency don't sleep.
+//
+// Set it to pfcp.Conn.Clock and Network.Clock to control the time of both,
+// and to pfcp.LoadTracker.Clock and pfcp.OverloadTracker.Clock if used.
+type Clock struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	seq    uint64
+	timers []*timer
+}
+
+// NewClock creates a new Clock that starts at t.
+func NewClock(t time.Time) *Clock {
+	c := &Clock{now: t}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now returns the current time of c.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// NewTimer creates a new Timer that sends the time on its channel after c
+// has advanced by d.
+func (c *Clock) NewTimer(d time.Duration) pfcp.Timer {
+	t := &timer{clock: c, ch: make(chan time.Time, 1)}
+	c.start(t, d)
+	return t
+}
+
+// AfterFunc calls f after c has advanced by d. The returned Timer can be used
+// to cancel the call with Stop.
+//
+// f is called by Advance before it returns, in the order of the time it is
+// due, and of the order it is scheduled for the same time. f must not block.
+func (c *Clock) AfterFunc(d time.Duration, f func()) pfcp.Timer {
+	t := &timer{clock: c, f: f}
+	c.start(t, d)
+	return t
+}
+
+func (c *Clock) start(t *timer, d time.Duration) {
+	c.mu.Lock()
+	t.when = c.now.Add(d)
+	c.seq++
+	t.seq = c.seq
+	c.timers = append(c.timers, t)
+	c.cond.Broadcast()
+	c.mu.Unlock()
+
+	if d <= 0 {
+		c.Advance(0)
+	}
+}
+
+// Advance moves the time of c forward by d, and fires the timers that are
+// due in the order of the time they are due.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+
+	var due, rest []*timer
+	for _, t := range c.timers {
+		if t.when.After(now) {
+			rest = append(rest, t)
+		} else {
+			due = append(due, t)
+		}
+	}
+	c.timers = rest
+	c.cond.Broadcast()
+	c.mu.Unlock()
+
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].when.Equal(due[j].when) {
+			return due[i].when.Before(due[j].when)
+		}
+		return due[i].seq < due[j].seq
+	})
+	for _, t := range due {
+		t.fire(now)
+	}
+}
+
+// BlockUntil waits until n timers are pending, e.g., to make sure the
+// request is sent and its retransmission timer has started before calling
+// Advance.
+func (c *Clock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.timers) != n {
+		c.cond.Wait()
+	}
+}
+
+// Pending returns the number of the timers pending.
+func (c *Clock) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.timers)
+}
+
+// stop removes t from the pending timers, and reports whether it has been
+// pending.
+func (c *Clock) stop(t *timer) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, p := range c.timers {
+		if p == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			c.cond.Broadcast()
+			return true
+		}
+	}
+	return false
+}
+
+// timer is the pfcp.Timer created by Clock.
+type timer struct {
+	clock *Clock
+	when  time.Time
+	seq   uint64
+	ch    chan time.Time
+	f     func()
+}
+
+func (t *timer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *timer) Stop() bool {
+	return t.clock.stop(t)
+}
+
+func (t *timer) fire(now time.Time) {
+	if t.f != nil {
+		t.f()
+		return
+	}
+	select {
+	case t.ch <- now:
+	default:
+	}
+}
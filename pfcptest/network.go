@@ -0,0 +1,326 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+// Package pfcptest provides the utilities to test the PFCP nodes built with
+// go-pfcp in one process, without the sockets.
+//
+// Network connects the net.PacketConns created by Listen in memory, and
+// passes the datagrams between them with the loss, duplication, reordering
+// and latency given by Link. The random decisions are made with the seed
+// given, so that the same test gives the same result every time. Clock is the
+// pfcp.Clock controlled by the test, which drives the timers of pfcp.Conn,
+// e.g., the retransmissions and the Heartbeat interval, and the latency of
+// Network without sleeping.
+//
+//	clock := pfcptest.NewClock(time.Now())
+//	nw := pfcptest.NewNetwork(1)
+//	nw.Clock = clock
+//
+//	pc, _ := nw.Listen("127.0.0.1:8805")
+//	conn := pfcp.NewConn(pc)
+//	conn.Clock = clock
+package pfcptest
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrAddressInUse is returned by Listen if the address is already used.
+var ErrAddressInUse = errors.New("address already in use")
+
+// errClosed is returned by the operations on a closed PacketConn, with the
+// same message as the one of net package.
+var errClosed = errors.New("use of closed network connection")
+
+// Link is the characteristics of the path from a PacketConn to another.
+type Link struct {
+	// Loss is the probability of a datagram being dropped, from 0 to 1.
+	Loss float64
+	// Duplicate is the probability of a datagram being delivered twice.
+	Duplicate float64
+	// Reorder is the probability of a datagram being held until the next
+	// one on the same path is delivered, which comes after the next one.
+	Reorder float64
+	// Latency is the time it takes for a datagram to be delivered.
+	Latency time.Duration
+}
+
+// Stats is the number of the datagrams passed by Network.
+type Stats struct {
+	Sent       int
+	Lost       int
+	Duplicated int
+	Reordered  int
+	Delivered  int
+}
+
+// path identifies the direction between two addresses.
+type path struct {
+	from, to string
+}
+
+// datagram is a datagram in transit.
+type datagram struct {
+	b    []byte
+	from net.Addr
+}
+
+// Network is the in-memory network that connects the PacketConns created
+// with Listen.
+//
+// Clock and Default should be set before calling Listen, as they are read by
+// the PacketConns sending the datagrams. Use SetLink to change the Link of a
+// path while the PacketConns are in use.
+type Network struct {
+	// Clock schedules the delivery of the datagrams with Latency, which is
+	// done with the system clock if nil.
+	Clock *Clock
+	// Default is the Link of the paths with no Link set with SetLink.
+	Default Link
+
+	mu       sync.Mutex
+	rand     *rand.Rand
+	conns    map[string]*PacketConn
+	links    map[path]Link
+	held     map[path]*datagram
+	stats    Stats
+	lastPort int
+}
+
+// NewNetwork creates a new Network that makes the random decisions with the
+// seed given.
+func NewNetwork(seed int64) *Network {
+	return &Network{
+		rand:     rand.New(rand.NewSource(seed)),
+		conns:    make(map[string]*PacketConn),
+		links:    make(map[path]Link),
+		held:     make(map[path]*datagram),
+		lastPort: 49151,
+	}
+}
+
+// SetLink sets the Link of the path from the address from to to. It does not
+// affect the other direction.
+func (n *Network) SetLink(from, to net.Addr, l Link) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.links[path{from.String(), to.String()}] = l
+}
+
+// Stats returns the number of the datagrams passed so far.
+func (n *Network) Stats() Stats {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.stats
+}
+
+// Listen creates a new PacketConn on the UDP address, e.g., "127.0.0.1:8805".
+// If the port is 0, an unused one is chosen.
+func (n *Network) Listen(address string) (*PacketConn, error) {
+	addr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if addr.Port == 0 {
+		for {
+			n.lastPort++
+			if n.lastPort > 65535 {
+				n.lastPort = 49152
+			}
+			addr.Port = n.lastPort
+			if _, ok := n.conns[addr.String()]; !ok {
+				break
+			}
+		}
+	}
+	if _, ok := n.conns[addr.String()]; ok {
+		return nil, fmt.Errorf("listen %s: %w", addr, ErrAddressInUse)
+	}
+
+	pc := &PacketConn{
+		network: n,
+		addr:    addr,
+		queue:   make(chan *datagram, 1024),
+		closeCh: make(chan struct{}),
+	}
+	n.conns[addr.String()] = pc
+	return pc, nil
+}
+
+// send passes b from the address from to to, with the Link of the path.
+func (n *Network) send(b []byte, from, to net.Addr) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	p := path{from.String(), to.String()}
+	l, ok := n.links[p]
+	if !ok {
+		l = n.Default
+	}
+
+	// always draw all the numbers to keep the results independent of Link.
+	loss, dup, reorder := n.rand.Float64(), n.rand.Float64(), n.rand.Float64()
+
+	n.stats.Sent++
+	if loss < l.Loss {
+		n.stats.Lost++
+		return
+	}
+
+	d := &datagram{b: b, from: from}
+	if reorder < l.Reorder && n.held[p] == nil {
+		n.stats.Reordered++
+		n.held[p] = d
+		return
+	}
+
+	n.deliver(p, d, l.Latency)
+	if dup < l.Duplicate {
+		n.stats.Duplicated++
+		n.deliver(p, d, l.Latency)
+	}
+	if h := n.held[p]; h != nil {
+		delete(n.held, p)
+		n.deliver(p, h, l.Latency)
+	}
+}
+
+// deliver puts d in the queue of the PacketConn at the destination of p
+// after latency.
+func (n *Network) deliver(p path, d *datagram, latency time.Duration) {
+	if latency <= 0 {
+		n.enqueue(p, d)
+		return
+	}
+
+	f := func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		n.enqueue(p, d)
+	}
+	if n.Clock != nil {
+		n.Clock.AfterFunc(latency, f)
+	} else {
+		time.AfterFunc(latency, f)
+	}
+}
+
+// enqueue puts d in the queue of the PacketConn at the destination of p. d is
+// dropped if there is no PacketConn, or the queue is full.
+func (n *Network) enqueue(p path, d *datagram) {
+	pc, ok := n.conns[p.to]
+	if !ok {
+		return
+	}
+
+	select {
+	case pc.queue <- d:
+		n.stats.Delivered++
+	default:
+	}
+}
+
+// PacketConn is the net.PacketConn on Network.
+type PacketConn struct {
+	network *Network
+	addr    *net.UDPAddr
+	queue   chan *datagram
+
+	mu           sync.Mutex
+	readDeadline time.Time
+
+	closeOnce sync.Once
+	closeCh   chan struct{}
+}
+
+// ReadFrom reads a datagram sent to c.
+func (c *PacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	c.mu.Lock()
+	deadline := c.readDeadline
+	c.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		t := time.NewTimer(time.Until(deadline))
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	select {
+	case d := <-c.queue:
+		return copy(b, d.b), d.from, nil
+	case <-c.closeCh:
+		return 0, nil, c.opError("read", errClosed)
+	case <-timeout:
+		return 0, nil, c.opError("read", os.ErrDeadlineExceeded)
+	}
+}
+
+// WriteTo sends a datagram with b to addr. It never fails unless c is
+// closed, even if addr does not exist, as UDP does.
+func (c *PacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	select {
+	case <-c.closeCh:
+		return 0, c.opError("write", errClosed)
+	default:
+	}
+
+	c.network.send(append([]byte(nil), b...), c.addr, addr)
+	return len(b), nil
+}
+
+// Close closes c and removes it from the Network.
+func (c *PacketConn) Close() error {
+	err := c.opError("close", errClosed)
+	c.closeOnce.Do(func() {
+		close(c.closeCh)
+
+		c.network.mu.Lock()
+		delete(c.network.conns, c.addr.String())
+		c.network.mu.Unlock()
+		err = nil
+	})
+	return err
+}
+
+// LocalAddr returns the address of c, which is *net.UDPAddr.
+func (c *PacketConn) LocalAddr() net.Addr {
+	return c.addr
+}
+
+// SetDeadline sets the read deadline. There is no write deadline, as WriteTo
+// never blocks.
+func (c *PacketConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+// SetReadDeadline sets the deadline of ReadFrom in the system clock.
+func (c *PacketConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.readDeadline = t
+	return nil
+}
+
+// SetWriteDeadline does nothing, as WriteTo never blocks.
+func (c *PacketConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *PacketConn) opError(op string, err error) error {
+	return &net.OpError{Op: op, Net: "udp", Source: c.addr, Err: err}
+}
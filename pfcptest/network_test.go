@@ -0,0 +1,228 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package pfcptest_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/wmnsk/go-pfcp"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+	"github.com/wmnsk/go-pfcp/pfcptest"
+)
+
+var ts = time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+type heartbeatResponder struct{}
+
+func (heartbeatResponder) ServePFCP(w pfcp.ResponseWriter, peer net.Addr, msg message.Message) {
+	_ = w.WriteMessage(message.NewHeartbeatResponse(0, ie.NewRecoveryTimeStamp(ts)))
+}
+
+// listen returns a new Conn on nw at address, serving with h.
+func listen(t *testing.T, nw *pfcptest.Network, clock *pfcptest.Clock, address string, h pfcp.Handler) *pfcp.Conn {
+	t.Helper()
+
+	pc, err := nw.Listen(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := pfcp.NewConn(pc)
+	c.Clock = clock
+	c.Handler = h
+	go func() { _ = c.Serve() }()
+	t.Cleanup(func() { _ = c.Close() })
+
+	return c
+}
+
+// read returns the payload of the next datagram to pc.
+func read(t *testing.T, pc net.PacketConn) string {
+	t.Helper()
+
+	if err := pc.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 16)
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf[:n])
+}
+
+func TestNetworkRetransmission(t *testing.T) {
+	clock := pfcptest.NewClock(ts)
+	nw := pfcptest.NewNetwork(1)
+	nw.Clock = clock
+
+	srv := listen(t, nw, clock, "127.0.0.1:8805", heartbeatResponder{})
+	cli := listen(t, nw, clock, "127.0.0.2:8805", nil)
+
+	// the first request is lost.
+	nw.SetLink(cli.LocalAddr(), srv.LocalAddr(), pfcptest.Link{Loss: 1})
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := cli.Request(context.Background(), message.NewHeartbeatRequest(0, ie.NewRecoveryTimeStamp(ts), nil), srv.LocalAddr())
+		errCh <- err
+	}()
+
+	clock.BlockUntil(1)
+	nw.SetLink(cli.LocalAddr(), srv.LocalAddr(), pfcptest.Link{})
+	clock.Advance(cli.T1)
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for response")
+	}
+
+	if got, want := nw.Stats(), (pfcptest.Stats{Sent: 3, Lost: 1, Delivered: 2}); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestNetworkTimeout(t *testing.T) {
+	clock := pfcptest.NewClock(ts)
+	nw := pfcptest.NewNetwork(1)
+	nw.Clock = clock
+	nw.Default = pfcptest.Link{Loss: 1}
+
+	srv := listen(t, nw, clock, "127.0.0.1:8805", heartbeatResponder{})
+	cli := listen(t, nw, clock, "127.0.0.2:8805", nil)
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := cli.Request(context.Background(), message.NewHeartbeatRequest(0, ie.NewRecoveryTimeStamp(ts), nil), srv.LocalAddr())
+		errCh <- err
+	}()
+
+	for i := 0; i <= cli.N1; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(cli.T1)
+	}
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, pfcp.ErrTimeout) {
+			t.Fatalf("got %v, want ErrTimeout", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for timeout")
+	}
+
+	if got := nw.Stats().Lost; got != cli.N1+1 {
+		t.Errorf("got %d lost, want %d", got, cli.N1+1)
+	}
+}
+
+func TestNetworkLink(t *testing.T) {
+	clock := pfcptest.NewClock(ts)
+	nw := pfcptest.NewNetwork(1)
+	nw.Clock = clock
+
+	a, err := nw.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := nw.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(s string) {
+		t.Helper()
+		if _, err := a.WriteTo([]byte(s), b.LocalAddr()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("Duplicate", func(t *testing.T) {
+		nw.SetLink(a.LocalAddr(), b.LocalAddr(), pfcptest.Link{Duplicate: 1})
+		send("dup")
+		if got := read(t, b) + read(t, b); got != "dupdup" {
+			t.Errorf("got %q", got)
+		}
+	})
+
+	t.Run("Reorder", func(t *testing.T) {
+		nw.SetLink(a.LocalAddr(), b.LocalAddr(), pfcptest.Link{Reorder: 1})
+		send("1")
+		send("2")
+		if got := read(t, b) + read(t, b); got != "21" {
+			t.Errorf("got %q", got)
+		}
+	})
+
+	t.Run("Latency", func(t *testing.T) {
+		nw.SetLink(a.LocalAddr(), b.LocalAddr(), pfcptest.Link{Latency: 10 * time.Millisecond})
+		before := nw.Stats().Delivered
+		send("late")
+
+		clock.Advance(9 * time.Millisecond)
+		if got := nw.Stats().Delivered; got != before {
+			t.Fatalf("delivered before latency: %d", got-before)
+		}
+		clock.Advance(time.Millisecond)
+		if got := read(t, b); got != "late" {
+			t.Errorf("got %q", got)
+		}
+	})
+}
+
+func TestNetworkDeterministic(t *testing.T) {
+	lost := func() int {
+		nw := pfcptest.NewNetwork(42)
+		nw.Default = pfcptest.Link{Loss: 0.5}
+
+		a, err := nw.Listen("127.0.0.1:8805")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 100; i++ {
+			if _, err := a.WriteTo([]byte{0}, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 2), Port: 8805}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return nw.Stats().Lost
+	}
+
+	first, second := lost(), lost()
+	if first != second {
+		t.Errorf("got different results with the same seed: %d, %d", first, second)
+	}
+	if first == 0 || first == 100 {
+		t.Errorf("got %d lost of 100 with Loss 0.5", first)
+	}
+}
+
+func TestNetworkListen(t *testing.T) {
+	nw := pfcptest.NewNetwork(1)
+	pc, err := nw.Listen("127.0.0.1:8805")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := nw.Listen("127.0.0.1:8805"); !errors.Is(err, pfcptest.ErrAddressInUse) {
+		t.Errorf("got %v, want ErrAddressInUse", err)
+	}
+
+	if err := pc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := pc.ReadFrom(make([]byte, 1)); err == nil {
+		t.Error("read from closed conn")
+	}
+	if _, err := nw.Listen("127.0.0.1:8805"); err != nil {
+		t.Errorf("address not released on close: %v", err)
+	}
+}
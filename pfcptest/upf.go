@@ -0,0 +1,455 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package pfcptest
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+
+	"github.com/wmnsk/go-pfcp"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+	"github.com/wmnsk/go-pfcp/rules"
+)
+
+// Matcher selects the requests received by UPF.
+type Matcher func(msg message.Message) bool
+
+// MessageType returns the Matcher that selects the requests of mtype.
+func MessageType(mtype uint8) Matcher {
+	return func(msg message.Message) bool {
+		return msg.MessageType() == mtype
+	}
+}
+
+// script is the action taken by UPF instead of handling the request.
+type script struct {
+	match Matcher
+	cause uint8
+	drop  bool
+	times int
+}
+
+// UPF is a mock UP function to test the CP functions with.
+//
+// It accepts the associations with Acceptor, and establishes, modifies and
+// deletes the sessions keeping the rules in the requests, which can be
+// inspected with Session. The F-TEIDs with the CH flag in the PDIs are
+// allocated by UPF with the IP address of the Conn.
+//
+// The behavior on the specific requests can be changed with Reject and Drop,
+// and the SessionReportRequests are sent to the CP function with Report and
+// the other ReportXxx methods.
+type UPF struct {
+	// Acceptor accepts the associations from the CP functions. The callbacks
+	// can be set to observe them.
+	Acceptor *pfcp.UPAssociationAcceptor
+	// Registry keeps the sessions established.
+	Registry *pfcp.SessionRegistry
+
+	conn    *pfcp.Conn
+	nodeID  *ie.IE
+	handler pfcp.Handler
+
+	mu       sync.Mutex
+	sessions map[uint64]*UPFSession
+	received []message.Message
+	scripts  []*script
+	lastTEID uint32
+}
+
+// NewUPF creates a new UPF with nodeID that works on c, by setting itself to
+// the Handler of c. Serve of c must be called to start it.
+func NewUPF(c *pfcp.Conn, nodeID *ie.IE) *UPF {
+	u := &UPF{
+		Acceptor: pfcp.NewUPAssociationAcceptor(c, nodeID),
+		Registry: pfcp.NewSessionRegistry(),
+		conn:     c,
+		nodeID:   nodeID,
+		sessions: make(map[uint64]*UPFSession),
+	}
+	u.Registry.NodeID = nodeID
+	u.Registry.OnSetDeletion = func(s *pfcp.Session) {
+		u.forget(s.RemoteSEID())
+	}
+
+	mux := pfcp.NewServeMux()
+	mux.NodeID = nodeID
+	mux.Handle(message.MsgTypeHeartbeatRequest, pfcp.NewHeartbeat(c))
+	mux.Handle(message.MsgTypeAssociationSetupRequest, u.Acceptor)
+	mux.Handle(message.MsgTypeAssociationUpdateRequest, u.Acceptor)
+	mux.Handle(message.MsgTypeAssociationReleaseRequest, u.Acceptor)
+	mux.HandleFunc(message.MsgTypeSessionEstablishmentRequest, u.establish)
+	mux.Handle(message.MsgTypeSessionModificationRequest, u.Registry)
+	mux.Handle(message.MsgTypeSessionDeletionRequest, u.Registry)
+	mux.Handle(message.MsgTypeSessionSetDeletionRequest, u.Registry)
+	u.handler = u.Acceptor.Handler(mux)
+
+	c.Handler = u
+	return u
+}
+
+// ServePFCP records msg, and handles it unless Reject or Drop applies to it.
+func (u *UPF) ServePFCP(w pfcp.ResponseWriter, peer net.Addr, msg message.Message) {
+	u.mu.Lock()
+	u.received = append(u.received, msg)
+	s := u.script(msg)
+	u.mu.Unlock()
+
+	switch {
+	case s == nil:
+		u.handler.ServePFCP(w, peer, msg)
+	case s.drop:
+	default:
+		if rsp := u.reject(msg, s.cause); rsp != nil {
+			_ = w.WriteMessage(rsp)
+		}
+	}
+}
+
+// script returns the script that applies to msg, if any.
+func (u *UPF) script(msg message.Message) *script {
+	for i, s := range u.scripts {
+		if !s.match(msg) {
+			continue
+		}
+		if s.times > 0 {
+			s.times--
+			if s.times == 0 {
+				u.scripts = append(u.scripts[:i], u.scripts[i+1:]...)
+			}
+		}
+		return s
+	}
+	return nil
+}
+
+// Reject makes UPF answer the next times requests selected by match with the
+// cause given, without handling them. It applies to all the requests selected
+// if times is 0 or less.
+func (u *UPF) Reject(match Matcher, cause uint8, times int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.scripts = append(u.scripts, &script{match: match, cause: cause, times: times})
+}
+
+// Drop makes UPF ignore the next times requests selected by match, to let the
+// CP function time out. It applies to all the requests selected if times is 0
+// or less.
+//
+// The retransmissions of a dropped request are not answered either, as long
+// as the response cache of the Conn is enabled.
+func (u *UPF) Drop(match Matcher, times int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.scripts = append(u.scripts, &script{match: match, drop: true, times: times})
+}
+
+// Received returns the requests received so far that are selected by match,
+// or all of them if match is nil.
+func (u *UPF) Received(match Matcher) []message.Message {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var msgs []message.Message
+	for _, m := range u.received {
+		if match == nil || match(m) {
+			msgs = append(msgs, m)
+		}
+	}
+	return msgs
+}
+
+// Session returns the session identified by cpSEID, the SEID allocated by
+// the CP function.
+func (u *UPF) Session(cpSEID uint64) (*UPFSession, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	s, ok := u.sessions[cpSEID]
+	return s, ok
+}
+
+// Sessions returns the sessions established, in the order of the SEID
+// allocated by the CP function.
+func (u *UPF) Sessions() []*UPFSession {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	sessions := make([]*UPFSession, 0, len(u.sessions))
+	for _, s := range u.sessions {
+		sessions = append(sessions, s)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].CPSEID < sessions[j].CPSEID })
+	return sessions
+}
+
+// Report sends SessionReportRequest with ies to the CP function of the
+// session identified by cpSEID, and returns the response to it.
+//
+// It returns pfcp.ErrSessionNotFound if there is no such session.
+func (u *UPF) Report(ctx context.Context, cpSEID uint64, ies ...*ie.IE) (*message.SessionReportResponse, error) {
+	s, ok := u.Session(cpSEID)
+	if !ok {
+		return nil, pfcp.ErrSessionNotFound
+	}
+	return u.Acceptor.Report(ctx, s.Session, message.NewSessionReportRequest(0, 0, 0, 0, 0, ies...))
+}
+
+// ReportUsage reports the usage of the URR of urrID with Report. The UR-SEQN
+// is counted for each URR, and ies(e.g., UsageReportTrigger and
+// VolumeMeasurement) are put in the UsageReport.
+func (u *UPF) ReportUsage(ctx context.Context, cpSEID uint64, urrID uint32, ies ...*ie.IE) (*message.SessionReportResponse, error) {
+	s, ok := u.Session(cpSEID)
+	if !ok {
+		return nil, pfcp.ErrSessionNotFound
+	}
+
+	s.mu.Lock()
+	seq := s.urSEQN[urrID]
+	s.urSEQN[urrID]++
+	s.mu.Unlock()
+
+	return u.Report(ctx, cpSEID,
+		ie.NewReportType(0, 0, 1, 0),
+		ie.NewUsageReportWithinSessionReportRequest(append([]*ie.IE{ie.NewURRID(urrID), ie.NewURSEQN(seq)}, ies...)...),
+	)
+}
+
+// ReportDownlinkData reports the arrival of the downlink data for the PDR of
+// pdrID with Report.
+func (u *UPF) ReportDownlinkData(ctx context.Context, cpSEID uint64, pdrID uint16) (*message.SessionReportResponse, error) {
+	return u.Report(ctx, cpSEID,
+		ie.NewReportType(0, 0, 0, 1),
+		ie.NewDownlinkDataReport(ie.NewPDRID(pdrID)),
+	)
+}
+
+// ReportErrorIndication reports the Error Indication received from the peer
+// GTP-U entity of the remote F-TEID given, with Report.
+func (u *UPF) ReportErrorIndication(ctx context.Context, cpSEID uint64, fteid *ie.IE) (*message.SessionReportResponse, error) {
+	return u.Report(ctx, cpSEID,
+		ie.NewReportType(0, 1, 0, 0),
+		ie.NewErrorIndicationReport(fteid),
+	)
+}
+
+func (u *UPF) establish(w pfcp.ResponseWriter, peer net.Addr, msg message.Message) {
+	req, ok := msg.(*message.SessionEstablishmentRequest)
+	if !ok {
+		return
+	}
+
+	reject := func(cause uint8, offending uint16) {
+		_ = w.WriteMessage(message.NewSessionEstablishmentResponse(0, 0, 0, 0, 0, u.nodeID, ie.NewCause(cause), ie.NewOffendingIE(offending)))
+	}
+	if req.NodeID == nil {
+		reject(ie.CauseMandatoryIEMissing, ie.NodeID)
+		return
+	}
+	nodeID, err := req.NodeID.NodeID()
+	if err != nil {
+		reject(ie.CauseMandatoryIEIncorrect, ie.NodeID)
+		return
+	}
+	if _, ok := u.Acceptor.Association(nodeID); !ok {
+		_ = w.WriteMessage(message.NewSessionEstablishmentResponse(0, 0, 0, 0, 0, u.nodeID, ie.NewCause(ie.CauseNoEstablishedPFCPAssociation)))
+		return
+	}
+	if req.CPFSEID == nil {
+		reject(ie.CauseMandatoryIEMissing, ie.FSEID)
+		return
+	}
+	cp, err := req.CPFSEID.FSEID()
+	if err != nil {
+		reject(ie.CauseMandatoryIEIncorrect, ie.FSEID)
+		return
+	}
+
+	set := rules.NewSet()
+	res := set.ApplyEstablishment(req)
+	if !res.Accepted() {
+		_ = w.WriteMessage(message.NewSessionEstablishmentResponse(0, 0, 0, 0, 0, append([]*ie.IE{u.nodeID}, res.IEs()...)...))
+		return
+	}
+	u.allocate(res.CreatedPDRs)
+
+	s := &UPFSession{CPSEID: cp.SEID, rules: set, urSEQN: make(map[uint32]uint32)}
+	s.Session, err = u.Registry.New(peer, req.CPFSEID, pfcp.HandlerFunc(func(w pfcp.ResponseWriter, peer net.Addr, msg message.Message) {
+		u.serveSession(s, w, msg)
+	}))
+	if err != nil {
+		reject(ie.CauseMandatoryIEIncorrect, ie.FSEID)
+		return
+	}
+
+	u.mu.Lock()
+	u.sessions[cp.SEID] = s
+	u.mu.Unlock()
+
+	v4, v6 := u.addrs()
+	ies := append([]*ie.IE{u.nodeID}, res.IEs()...)
+	ies = append(ies, ie.NewFSEID(s.LocalSEID, v4, v6, nil))
+	_ = w.WriteMessage(message.NewSessionEstablishmentResponse(0, 0, 0, 0, 0, ies...))
+}
+
+func (u *UPF) serveSession(s *UPFSession, w pfcp.ResponseWriter, msg message.Message) {
+	switch req := msg.(type) {
+	case *message.SessionModificationRequest:
+		s.mu.Lock()
+		res := s.rules.ApplyModification(req)
+		if res.Accepted() {
+			u.allocate(res.CreatedPDRs)
+			u.allocate(res.UpdatedPDRs)
+		}
+		s.mu.Unlock()
+		_ = w.WriteMessage(message.NewSessionModificationResponse(0, 0, 0, 0, 0, res.IEs()...))
+	case *message.SessionDeletionRequest:
+		u.Registry.Delete(s.LocalSEID)
+		u.forget(s.CPSEID)
+		_ = w.WriteMessage(message.NewSessionDeletionResponse(0, 0, 0, 0, 0, ie.NewCause(ie.CauseRequestAccepted)))
+	default:
+		if rsp := u.reject(msg, ie.CauseRequestRejected); rsp != nil {
+			_ = w.WriteMessage(rsp)
+		}
+	}
+}
+
+// reject returns the response to msg with cause, in the same way as the
+// responses to the invalid requests sent by pfcp.Conn. It returns nil if msg
+// has no response with Cause.
+//
+// The SEID of the response to a request in an established session is set to
+// the one of the CP function.
+func (u *UPF) reject(msg message.Message, cause uint8) message.Message {
+	b := make([]byte, msg.MarshalLen())
+	if err := msg.MarshalTo(b); err != nil {
+		return nil
+	}
+	rsp, err := message.NewErrorResponse(b, &message.ValidationError{MsgType: msg.MessageType(), Cause: cause}, u.nodeID, ie.NewRecoveryTimeStamp(u.conn.RecoveryTimeStamp))
+	if err != nil {
+		return nil
+	}
+
+	if s, ok := u.Registry.Session(msg.SEID()); ok && msg.SEID() != 0 {
+		if h, ok := rsp.(interface{ SetSEID(uint64) }); ok {
+			h.SetSEID(s.RemoteSEID())
+		}
+	}
+	return rsp
+}
+
+// forget removes the session identified by cpSEID.
+func (u *UPF) forget(cpSEID uint64) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	delete(u.sessions, cpSEID)
+}
+
+// allocate replaces the Local F-TEIDs with the CH flag in the PDIs with the
+// ones allocated. The ones with the same CHOOSE ID share the same F-TEID.
+func (u *UPF) allocate(pdrs []*rules.PDR) {
+	v4, v6 := u.addrs()
+	chosen := make(map[string]*ie.FTEIDFields)
+	for _, p := range pdrs {
+		if p.PDI == nil || p.PDI.LocalFTEID == nil || !p.PDI.LocalFTEID.HasCh() {
+			continue
+		}
+
+		chid := p.PDI.LocalFTEID.ChooseID
+		if f, ok := chosen[string(chid)]; ok && chid != nil {
+			p.PDI.LocalFTEID = f
+			continue
+		}
+
+		u.mu.Lock()
+		u.lastTEID++
+		teid := u.lastTEID
+		u.mu.Unlock()
+
+		f := ie.NewFTEIDFields(teid, v4, v6, nil)
+		chosen[string(chid)] = f
+		p.PDI.LocalFTEID = f
+	}
+}
+
+// addrs returns the IP address of the Conn as IPv4 or IPv6 one.
+func (u *UPF) addrs() (v4, v6 net.IP) {
+	a, ok := u.conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return nil, nil
+	}
+	if ip := a.IP.To4(); ip != nil {
+		return ip, nil
+	}
+	return nil, a.IP
+}
+
+// UPFSession is a session established on UPF.
+type UPFSession struct {
+	*pfcp.Session
+	// CPSEID is the SEID allocated by the CP function.
+	CPSEID uint64
+
+	mu     sync.Mutex
+	rules  *rules.Set
+	urSEQN map[uint32]uint32
+}
+
+// Rules returns a copy of the rules of s. The rules in it are shared with s,
+// and must not be modified.
+func (s *UPFSession) Rules() *rules.Set {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.rules.Clone()
+}
+
+// PDRs returns the PDRs of s selected by match in the order of the ID, or
+// all of them if match is nil.
+func (s *UPFSession) PDRs(match func(p *rules.PDR) bool) []*rules.PDR {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var pdrs []*rules.PDR
+	for _, p := range s.rules.PDRs {
+		if match == nil || match(p) {
+			pdrs = append(pdrs, p)
+		}
+	}
+	sort.Slice(pdrs, func(i, j int) bool { return pdrs[i].PDRID < pdrs[j].PDRID })
+	return pdrs
+}
+
+// FARs returns the FARs of s selected by match in the order of the ID, or
+// all of them if match is nil.
+func (s *UPFSession) FARs(match func(f *rules.FAR) bool) []*rules.FAR {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var fars []*rules.FAR
+	for _, f := range s.rules.FARs {
+		if match == nil || match(f) {
+			fars = append(fars, f)
+		}
+	}
+	sort.Slice(fars, func(i, j int) bool { return fars[i].FARID < fars[j].FARID })
+	return fars
+}
+
+// DestinationInterface returns the matcher of FARs for UPFSession.FARs that
+// selects the ones forwarding to the interface iface, e.g.,
+// ie.DstInterfaceCore.
+func DestinationInterface(iface uint8) func(f *rules.FAR) bool {
+	return func(f *rules.FAR) bool {
+		p := f.ForwardingParameters
+		return p != nil && p.DestinationInterface != nil && *p.DestinationInterface == iface
+	}
+}
@@ -0,0 +1,268 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package pfcptest_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/wmnsk/go-pfcp"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+	"github.com/wmnsk/go-pfcp/pfcptest"
+)
+
+// smf is the CP function to test UPF with.
+type smf struct {
+	conn    *pfcp.Conn
+	assoc   *pfcp.CPAssociationManager
+	reports chan *message.SessionReportRequest
+}
+
+// setup returns UPF and the SMF associated with it on a new Network.
+func setup(t *testing.T) (*pfcptest.UPF, *smf) {
+	t.Helper()
+
+	nw := pfcptest.NewNetwork(1)
+
+	upc, err := nw.Listen("127.0.0.1:8805")
+	if err != nil {
+		t.Fatal(err)
+	}
+	up := pfcp.NewConn(upc)
+	upf := pfcptest.NewUPF(up, ie.NewNodeID("127.0.0.1", "", ""))
+	go func() { _ = up.Serve() }()
+	t.Cleanup(func() { _ = up.Close() })
+
+	cpc, err := nw.Listen("127.0.0.2:8805")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp := pfcp.NewConn(cpc)
+	cp.T1 = 10 * time.Millisecond
+	cp.N1 = 1
+	s := &smf{
+		conn:    cp,
+		assoc:   pfcp.NewCPAssociationManager(cp, ie.NewNodeID("127.0.0.2", "", "")),
+		reports: make(chan *message.SessionReportRequest, 8),
+	}
+	mux := pfcp.NewServeMux()
+	mux.HandleFunc(message.MsgTypeSessionReportRequest, func(w pfcp.ResponseWriter, peer net.Addr, msg message.Message) {
+		s.reports <- msg.(*message.SessionReportRequest)
+		_ = w.WriteMessage(message.NewSessionReportResponse(0, 0, 0, 0, 0, ie.NewCause(ie.CauseRequestAccepted)))
+	})
+	cp.Handler = mux
+	go func() { _ = cp.Serve() }()
+	t.Cleanup(func() { _ = cp.Close() })
+
+	if _, err := s.assoc.Setup(context.Background(), "127.0.0.1", upc.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	return upf, s
+}
+
+// establish establishes a session with cpSEID, which has an uplink PDR with
+// F-TEID to be allocated by UPF and a FAR to the core.
+func (s *smf) establish(t *testing.T, cpSEID uint64) *message.SessionEstablishmentResponse {
+	t.Helper()
+
+	req := message.NewSessionEstablishmentRequest(0, 0, 0, 0, 0,
+		ie.NewNodeID("127.0.0.2", "", ""),
+		ie.NewFSEID(cpSEID, net.ParseIP("127.0.0.2"), nil, nil),
+		ie.NewCreatePDR(
+			ie.NewPDRID(1),
+			ie.NewPrecedence(100),
+			ie.NewPDI(
+				ie.NewSourceInterface(ie.SrcInterfaceAccess),
+				ie.New(ie.FTEID, []byte{0x0c, 0x01}), // CH and CHID
+			),
+			ie.NewFARID(1),
+		),
+		ie.NewCreateFAR(
+			ie.NewFARID(1),
+			ie.NewApplyAction(0x02),
+			ie.NewForwardingParameters(ie.NewDestinationInterface(ie.DstInterfaceCore)),
+		),
+	)
+	rsp, err := s.assoc.Request(context.Background(), "127.0.0.1", req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rsp.(*message.SessionEstablishmentResponse)
+}
+
+func TestUPFSession(t *testing.T) {
+	upf, smf := setup(t)
+
+	rsp := smf.establish(t, 0x1111)
+	if err := pfcp.ResponseError(rsp); err != nil {
+		t.Fatal(err)
+	}
+	if rsp.SEID() != 0x1111 {
+		t.Errorf("got SEID %#x", rsp.SEID())
+	}
+	if len(rsp.CreatedPDR) != 1 {
+		t.Fatalf("got %d CreatedPDRs", len(rsp.CreatedPDR))
+	}
+	fteid, err := rsp.CreatedPDR[0].FTEID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fteid.TEID == 0 || !fteid.IPv4Address.Equal(net.ParseIP("127.0.0.1")) {
+		t.Errorf("got invalid F-TEID: %+v", fteid)
+	}
+	upFSEID, err := rsp.UPFSEID.FSEID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, ok := upf.Session(0x1111)
+	if !ok {
+		t.Fatal("session not found")
+	}
+	if got := len(s.FARs(pfcptest.DestinationInterface(ie.DstInterfaceCore))); got != 1 {
+		t.Errorf("got %d FARs to core", got)
+	}
+	if got := s.PDRs(nil); len(got) != 1 || got[0].PDI.LocalFTEID.TEID != fteid.TEID {
+		t.Errorf("got PDRs %+v", got)
+	}
+
+	// the modification is rejected once, and then accepted.
+	upf.Reject(pfcptest.MessageType(message.MsgTypeSessionModificationRequest), ie.CauseRequestRejected, 1)
+	mod := func() error {
+		req := message.NewSessionModificationRequest(0, 0, upFSEID.SEID, 0, 0,
+			ie.NewUpdateFAR(
+				ie.NewFARID(1),
+				ie.NewUpdateForwardingParameters(ie.NewDestinationInterface(ie.DstInterfaceSGiLANN6LAN)),
+			),
+		)
+		rsp, err := smf.assoc.Request(context.Background(), "127.0.0.1", req)
+		if err != nil {
+			return err
+		}
+		return pfcp.ResponseError(rsp)
+	}
+	if err := mod(); !errors.Is(err, pfcp.ErrRequestRejected) {
+		t.Errorf("got %v, want ErrRequestRejected", err)
+	}
+	if err := mod(); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(s.FARs(pfcptest.DestinationInterface(ie.DstInterfaceSGiLANN6LAN))); got != 1 {
+		t.Errorf("got %d FARs to SGi-LAN", got)
+	}
+	if got := len(upf.Received(pfcptest.MessageType(message.MsgTypeSessionModificationRequest))); got != 2 {
+		t.Errorf("got %d modifications", got)
+	}
+
+	// the deletion is dropped to time out.
+	upf.Drop(pfcptest.MessageType(message.MsgTypeSessionDeletionRequest), 1)
+	del := message.NewSessionDeletionRequest(0, 0, upFSEID.SEID, 0, 0)
+	if _, err := smf.assoc.Request(context.Background(), "127.0.0.1", del); !errors.Is(err, pfcp.ErrTimeout) {
+		t.Errorf("got %v, want ErrTimeout", err)
+	}
+	if _, ok := upf.Session(0x1111); !ok {
+		t.Error("session deleted by dropped request")
+	}
+
+	rsp2, err := smf.assoc.Request(context.Background(), "127.0.0.1", message.NewSessionDeletionRequest(0, 0, upFSEID.SEID, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pfcp.ResponseError(rsp2); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := upf.Session(0x1111); ok {
+		t.Error("session not deleted")
+	}
+}
+
+func TestUPFReport(t *testing.T) {
+	upf, smf := setup(t)
+	if err := pfcp.ResponseError(smf.establish(t, 0x2222)); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	for _, c := range []struct {
+		description string
+		report      func() (*message.SessionReportResponse, error)
+		check       func(req *message.SessionReportRequest) bool
+	}{
+		{
+			"Usage",
+			func() (*message.SessionReportResponse, error) {
+				return upf.ReportUsage(ctx, 0x2222, 3, ie.NewUsageReportTrigger(0x01, 0x00))
+			},
+			func(req *message.SessionReportRequest) bool {
+				if !req.ReportType.HasUSAR() || len(req.UsageReport) != 1 {
+					return false
+				}
+				id, err := req.UsageReport[0].URRID()
+				return err == nil && id == 3
+			},
+		}, {
+			"DownlinkData",
+			func() (*message.SessionReportResponse, error) {
+				return upf.ReportDownlinkData(ctx, 0x2222, 1)
+			},
+			func(req *message.SessionReportRequest) bool {
+				return req.ReportType.HasDLDR() && req.DownlinkDataReport != nil
+			},
+		}, {
+			"ErrorIndication",
+			func() (*message.SessionReportResponse, error) {
+				return upf.ReportErrorIndication(ctx, 0x2222, ie.NewFTEID(0x1234, net.ParseIP("127.0.0.3"), nil, nil))
+			},
+			func(req *message.SessionReportRequest) bool {
+				return req.ReportType.HasERIR() && req.ErrorIndicationReport != nil
+			},
+		},
+	} {
+		t.Run(c.description, func(t *testing.T) {
+			if _, err := c.report(); err != nil {
+				t.Fatal(err)
+			}
+
+			req := <-smf.reports
+			if req.SEID() != 0x2222 {
+				t.Errorf("got SEID %#x", req.SEID())
+			}
+			if !c.check(req) {
+				t.Errorf("got unexpected report: %+v", req)
+			}
+		})
+	}
+
+	if _, err := upf.ReportDownlinkData(ctx, 0xdead, 1); !errors.Is(err, pfcp.ErrSessionNotFound) {
+		t.Errorf("got %v, want ErrSessionNotFound", err)
+	}
+}
+
+func TestUPFNoAssociation(t *testing.T) {
+	upf, smf := setup(t)
+	if err := smf.assoc.Release(context.Background(), "127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// send it directly, as CPAssociationManager refuses it.
+	req := message.NewSessionEstablishmentRequest(0, 0, 0, 0, 0,
+		ie.NewNodeID("127.0.0.2", "", ""),
+		ie.NewFSEID(0x3333, net.ParseIP("127.0.0.2"), nil, nil),
+	)
+	rsp, err := smf.conn.Request(context.Background(), req, &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 8805})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pfcp.ResponseError(rsp); !errors.Is(err, pfcp.ErrNoEstablishedPFCPAssociation) {
+		t.Errorf("got %v, want ErrNoEstablishedPFCPAssociation", err)
+	}
+	if got := upf.Sessions(); len(got) != 0 {
+		t.Errorf("got %d sessions", len(got))
+	}
+}
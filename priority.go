@@ -0,0 +1,180 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package pfcp
+
+import (
+	"net"
+	"sync"
+
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// NumPriorities is the number of the message priority levels, which are
+// 0(highest) to 15(lowest) as defined in TS 29.244 §7.2.2.
+const NumPriorities = 16
+
+// DefaultMessagePriority is the priority given to the session related
+// messages without the MP flag.
+const DefaultMessagePriority = 8
+
+// QueueStats is the counters of a message queue of Conn, indexed by the
+// message priority.
+type QueueStats struct {
+	// Enqueued is the number of the messages put in the queue.
+	Enqueued [NumPriorities]uint64
+	// Dequeued is the number of the messages taken out of the queue to be
+	// sent or handled.
+	Dequeued [NumPriorities]uint64
+	// Dropped is the number of the messages discarded as the queue is full.
+	Dropped [NumPriorities]uint64
+}
+
+// queuedMessage is a message waiting in priorityQueue.
+type queuedMessage struct {
+	pri  uint8
+	b    []byte
+	peer net.Addr
+
+	// msg and key are set only for the inbound requests.
+	msg message.Message
+	key requestKey
+}
+
+// priorityQueue is a bounded queue that serves the messages in the order of
+// priority, and in FIFO order within the same priority.
+//
+// When the queue is full, the message with the lowest priority is dropped to
+// make room for the new one with a higher priority. If the new one has the
+// lowest priority, it is dropped instead.
+type priorityQueue struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	size   int
+	n      int
+	levels [NumPriorities][]*queuedMessage
+	stats  QueueStats
+	closed bool
+}
+
+func newPriorityQueue(size int) *priorityQueue {
+	q := &priorityQueue{size: size}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// push puts m in the queue, and returns the message dropped to do so, which
+// may be m itself, or nil if nothing is dropped.
+func (q *priorityQueue) push(m *queuedMessage) *queuedMessage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		q.stats.Dropped[m.pri]++
+		return m
+	}
+
+	var dropped *queuedMessage
+	if q.n >= q.size {
+		lowest := q.lowest()
+		if lowest < 0 || int(m.pri) >= lowest {
+			q.stats.Dropped[m.pri]++
+			return m
+		}
+
+		// drop the newest one in the lowest level, which has waited the least.
+		l := q.levels[lowest]
+		dropped = l[len(l)-1]
+		l[len(l)-1] = nil
+		q.levels[lowest] = l[:len(l)-1]
+		q.n--
+		q.stats.Dropped[lowest]++
+	}
+
+	q.levels[m.pri] = append(q.levels[m.pri], m)
+	q.n++
+	q.stats.Enqueued[m.pri]++
+	q.cond.Signal()
+	return dropped
+}
+
+// lowest returns the lowest priority that has any message, or -1 if the
+// queue is empty.
+func (q *priorityQueue) lowest() int {
+	for p := NumPriorities - 1; p >= 0; p-- {
+		if len(q.levels[p]) > 0 {
+			return p
+		}
+	}
+	return -1
+}
+
+// pop takes out the message with the highest priority, blocking until any
+// message is available. It returns nil after the queue is closed.
+func (q *priorityQueue) pop() *queuedMessage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for q.n == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return nil
+	}
+
+	for p := range q.levels {
+		l := q.levels[p]
+		if len(l) == 0 {
+			continue
+		}
+
+		m := l[0]
+		l[0] = nil
+		q.levels[p] = l[1:]
+		q.n--
+		q.stats.Dequeued[p]++
+		return m
+	}
+	return nil
+}
+
+// close discards the messages in the queue and unblocks the pop calls.
+func (q *priorityQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	for p := range q.levels {
+		q.levels[p] = nil
+	}
+	q.n = 0
+	q.cond.Broadcast()
+}
+
+func (q *priorityQueue) snapshot() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.stats
+}
+
+// priorityOf returns the priority of msg used in the queues of Conn.
+//
+// The session related messages have the priority in the header if the MP
+// flag is set, or def otherwise. The node related messages always have the
+// highest priority, as they are few and the sessions depend on them.
+func priorityOf(msg message.Message, def uint8) uint8 {
+	h, ok := msg.(interface {
+		HasSEID() bool
+		HasMP() bool
+		MP() uint8
+	})
+	if !ok || !h.HasSEID() {
+		return 0
+	}
+	if h.HasMP() {
+		return h.MP()
+	}
+	return def & 0x0f
+}
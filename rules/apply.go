@@ -0,0 +1,674 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package rules
+
+import (
+	"sort"
+
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// Result is the result of applying a request to Set.
+type Result struct {
+	// Cause is the value to be put in the Cause IE of the response.
+	Cause uint8
+	// OffendingIE is the OffendingIE IE to be put in the response, if any.
+	OffendingIE *ie.IE
+	// FailedRuleID is the FailedRuleID IE to be put in the response, if any.
+	FailedRuleID *ie.IE
+
+	// CreatedPDRs and UpdatedPDRs are the PDRs created or updated by the
+	// request. They are the ones in Set, and the F-TEID or UE IP address
+	// allocated by the UP function should be set in their PDIs before
+	// calling IEs.
+	CreatedPDRs []*PDR
+	UpdatedPDRs []*PDR
+}
+
+// Accepted reports whether the request is accepted.
+func (r *Result) Accepted() bool {
+	return r.Cause == ie.CauseRequestAccepted
+}
+
+// IEs returns the IEs to be put in the response: Cause, OffendingIE,
+// FailedRuleID, CreatedPDR and UpdatedPDR.
+//
+// CreatedPDR and UpdatedPDR are created for the PDRs that have the Local
+// F-TEID or UE IP address in PDI.
+func (r *Result) IEs() []*ie.IE {
+	ies := []*ie.IE{ie.NewCause(r.Cause)}
+	ies = appendIfNotNil(ies, r.OffendingIE, r.FailedRuleID)
+	if !r.Accepted() {
+		return ies
+	}
+
+	for _, p := range r.CreatedPDRs {
+		if c := p.allocatedIEs(); c != nil {
+			ies = append(ies, ie.NewCreatedPDR(c...))
+		}
+	}
+	for _, p := range r.UpdatedPDRs {
+		if c := p.allocatedIEs(); c != nil {
+			ies = append(ies, ie.NewUpdatedPDR(c...))
+		}
+	}
+	return ies
+}
+
+// allocatedIEs returns the child IEs of CreatedPDR/UpdatedPDR, or nil if PDI
+// has nothing to be reported.
+func (p *PDR) allocatedIEs() []*ie.IE {
+	if p.PDI == nil || (p.PDI.LocalFTEID == nil && p.PDI.UEIPAddresses == nil) {
+		return nil
+	}
+
+	ies := []*ie.IE{ie.NewPDRID(p.PDRID)}
+	if p.PDI.LocalFTEID != nil {
+		ies = append(ies, newFieldsIE(ie.FTEID, p.PDI.LocalFTEID))
+	}
+	for _, f := range p.PDI.UEIPAddresses {
+		ies = append(ies, newFieldsIE(ie.UEIPAddress, f))
+	}
+	return ies
+}
+
+// failure is the reason why a request is rejected.
+type failure struct {
+	cause     uint8
+	offending uint16
+	rule      *ie.IE
+}
+
+func incorrect(itype uint16) *failure {
+	return &failure{cause: ie.CauseMandatoryIEIncorrect, offending: itype}
+}
+
+func ruleFailure(typ uint8, id uint32) *failure {
+	return &failure{cause: ie.CauseRuleCreationModificationFailure, rule: ie.NewFailedRuleID(typ, id)}
+}
+
+// trafficEndpointFailure is the failure of a Traffic Endpoint, which has no
+// Rule ID Type to be identified by FailedRuleID.
+func trafficEndpointFailure() *failure {
+	return &failure{cause: ie.CauseRuleCreationModificationFailure}
+}
+
+func (f *failure) result() *Result {
+	r := &Result{Cause: f.cause, FailedRuleID: f.rule}
+	if f.offending != 0 {
+		r.OffendingIE = ie.NewOffendingIE(f.offending)
+	}
+	return r
+}
+
+// ApplyEstablishment creates the rules in SessionEstablishmentRequest in s.
+//
+// If any of the rules cannot be created, or a rule refers to a rule that
+// does not exist(e.g., FAR ID in PDR), s is left unchanged and the Result
+// has the Cause to reject the request.
+func (s *Set) ApplyEstablishment(req *message.SessionEstablishmentRequest) *Result {
+	n := s.Clone()
+	res := &Result{Cause: ie.CauseRequestAccepted}
+
+	var bars []*ie.IE
+	if req.CreateBAR != nil {
+		bars = []*ie.IE{req.CreateBAR}
+	}
+
+	for _, f := range []func() *failure{
+		func() *failure { return n.createTrafficEndpoints(req.CreateTrafficEndpoint) },
+		func() *failure { return n.createFARs(req.CreateFAR) },
+		func() *failure { return n.createQERs(req.CreateQER) },
+		func() *failure { return n.createURRs(req.CreateURR) },
+		func() *failure { return n.createBAR(bars) },
+		func() *failure { return n.createMARs(req.CreateMAR) },
+		func() *failure { return n.createSRRs(req.CreateSRR) },
+		func() *failure {
+			var fail *failure
+			res.CreatedPDRs, fail = n.createPDRs(req.CreatePDR)
+			return fail
+		},
+		n.validate,
+	} {
+		if fail := f(); fail != nil {
+			return fail.result()
+		}
+	}
+
+	*s = *n
+	return res
+}
+
+// ApplyModification removes, creates and updates the rules in
+// SessionModificationRequest in s, in this order.
+//
+// If any of the rules cannot be removed, created or updated, or a rule refers
+// to a rule that does not exist(e.g., FAR ID in PDR), s is left unchanged and
+// the Result has the Cause to reject the request.
+func (s *Set) ApplyModification(req *message.SessionModificationRequest) *Result {
+	n := s.Clone()
+	res := &Result{Cause: ie.CauseRequestAccepted}
+
+	var createBARs, updateBARs, removeBARs []*ie.IE
+	if req.CreateBAR != nil {
+		createBARs = []*ie.IE{req.CreateBAR}
+	}
+	if req.UpdateBAR != nil {
+		updateBARs = []*ie.IE{req.UpdateBAR}
+	}
+	if req.RemoveBAR != nil {
+		removeBARs = []*ie.IE{req.RemoveBAR}
+	}
+
+	for _, f := range []func() *failure{
+		func() *failure { return n.removePDRs(req.RemovePDR) },
+		func() *failure { return n.removeFARs(req.RemoveFAR) },
+		func() *failure { return n.removeQERs(req.RemoveQER) },
+		func() *failure { return n.removeURRs(req.RemoveURR) },
+		func() *failure { return n.removeBAR(removeBARs) },
+		func() *failure { return n.removeTrafficEndpoints(req.RemoveTrafficEndpoint) },
+		func() *failure { return n.removeMARs(req.RemoveMAR) },
+		func() *failure { return n.removeSRRs(req.RemoveSRR) },
+
+		func() *failure { return n.createTrafficEndpoints(req.CreateTrafficEndpoint) },
+		func() *failure { return n.createFARs(req.CreateFAR) },
+		func() *failure { return n.createQERs(req.CreateQER) },
+		func() *failure { return n.createURRs(req.CreateURR) },
+		func() *failure { return n.createBAR(createBARs) },
+		func() *failure { return n.createMARs(req.CreateMAR) },
+		func() *failure { return n.createSRRs(req.CreateSRR) },
+		func() *failure {
+			var fail *failure
+			res.CreatedPDRs, fail = n.createPDRs(req.CreatePDR)
+			return fail
+		},
+
+		func() *failure { return n.updateTrafficEndpoints(req.UpdateTrafficEndpoint) },
+		func() *failure { return n.updateFARs(req.UpdateFAR) },
+		func() *failure { return n.updateQERs(req.UpdateQER) },
+		func() *failure { return n.updateURRs(req.UpdateURR) },
+		func() *failure { return n.updateBAR(updateBARs) },
+		func() *failure { return n.updateMARs(req.UpdateMAR) },
+		func() *failure { return n.updateSRRs(req.UpdateSRR) },
+		func() *failure {
+			var fail *failure
+			res.UpdatedPDRs, fail = n.updatePDRs(req.UpdatePDR)
+			return fail
+		},
+		n.validate,
+	} {
+		if fail := f(); fail != nil {
+			return fail.result()
+		}
+	}
+
+	*s = *n
+	return res
+}
+
+func (s *Set) createPDRs(ies []*ie.IE) ([]*PDR, *failure) {
+	var created []*PDR
+	for _, i := range ies {
+		p, err := ParsePDR(i)
+		if err != nil {
+			return nil, incorrect(i.Type)
+		}
+		if _, ok := s.PDRs[p.PDRID]; ok {
+			return nil, ruleFailure(ie.RuleIDTypePDR, uint32(p.PDRID))
+		}
+		s.PDRs[p.PDRID] = p
+		created = append(created, p)
+	}
+	return created, nil
+}
+
+func (s *Set) updatePDRs(ies []*ie.IE) ([]*PDR, *failure) {
+	var updated []*PDR
+	for _, i := range ies {
+		upd, err := parseUpdatePDR(i)
+		if err != nil {
+			return nil, incorrect(i.Type)
+		}
+		p, ok := s.PDRs[upd.PDRID]
+		if !ok {
+			return nil, ruleFailure(ie.RuleIDTypePDR, uint32(upd.PDRID))
+		}
+		p = p.merge(upd)
+		s.PDRs[p.PDRID] = p
+		updated = append(updated, p)
+	}
+	return updated, nil
+}
+
+func (s *Set) removePDRs(ies []*ie.IE) *failure {
+	for _, i := range ies {
+		c, err := removedID(i, ie.PDRID)
+		if err != nil {
+			return incorrect(i.Type)
+		}
+		id, err := c.PDRID()
+		if err != nil {
+			return incorrect(i.Type)
+		}
+		if _, ok := s.PDRs[id]; !ok {
+			return ruleFailure(ie.RuleIDTypePDR, uint32(id))
+		}
+		delete(s.PDRs, id)
+	}
+	return nil
+}
+
+func (s *Set) createFARs(ies []*ie.IE) *failure {
+	for _, i := range ies {
+		f, err := ParseFAR(i)
+		if err != nil {
+			return incorrect(i.Type)
+		}
+		if _, ok := s.FARs[f.FARID]; ok {
+			return ruleFailure(ie.RuleIDTypeFAR, f.FARID)
+		}
+		s.FARs[f.FARID] = f
+	}
+	return nil
+}
+
+func (s *Set) updateFARs(ies []*ie.IE) *failure {
+	for _, i := range ies {
+		upd, err := parseUpdateFAR(i)
+		if err != nil {
+			return incorrect(i.Type)
+		}
+		f, ok := s.FARs[upd.FARID]
+		if !ok {
+			return ruleFailure(ie.RuleIDTypeFAR, upd.FARID)
+		}
+		s.FARs[f.FARID] = f.merge(upd)
+	}
+	return nil
+}
+
+func (s *Set) removeFARs(ies []*ie.IE) *failure {
+	for _, i := range ies {
+		c, err := removedID(i, ie.FARID)
+		if err != nil {
+			return incorrect(i.Type)
+		}
+		id, err := c.FARID()
+		if err != nil {
+			return incorrect(i.Type)
+		}
+		if _, ok := s.FARs[id]; !ok {
+			return ruleFailure(ie.RuleIDTypeFAR, id)
+		}
+		delete(s.FARs, id)
+	}
+	return nil
+}
+
+func (s *Set) createQERs(ies []*ie.IE) *failure {
+	for _, i := range ies {
+		q, err := ParseQER(i)
+		if err != nil {
+			return incorrect(i.Type)
+		}
+		if _, ok := s.QERs[q.QERID]; ok {
+			return ruleFailure(ie.RuleIDTypeQER, q.QERID)
+		}
+		s.QERs[q.QERID] = q
+	}
+	return nil
+}
+
+func (s *Set) updateQERs(ies []*ie.IE) *failure {
+	for _, i := range ies {
+		upd, err := parseUpdateQER(i)
+		if err != nil {
+			return incorrect(i.Type)
+		}
+		q, ok := s.QERs[upd.QERID]
+		if !ok {
+			return ruleFailure(ie.RuleIDTypeQER, upd.QERID)
+		}
+		s.QERs[q.QERID] = q.merge(upd)
+	}
+	return nil
+}
+
+func (s *Set) removeQERs(ies []*ie.IE) *failure {
+	for _, i := range ies {
+		c, err := removedID(i, ie.QERID)
+		if err != nil {
+			return incorrect(i.Type)
+		}
+		id, err := c.QERID()
+		if err != nil {
+			return incorrect(i.Type)
+		}
+		if _, ok := s.QERs[id]; !ok {
+			return ruleFailure(ie.RuleIDTypeQER, id)
+		}
+		delete(s.QERs, id)
+	}
+	return nil
+}
+
+func (s *Set) createURRs(ies []*ie.IE) *failure {
+	for _, i := range ies {
+		u, err := ParseURR(i)
+		if err != nil {
+			return incorrect(i.Type)
+		}
+		if _, ok := s.URRs[u.URRID]; ok {
+			return ruleFailure(ie.RuleIDTypeURR, u.URRID)
+		}
+		s.URRs[u.URRID] = u
+	}
+	return nil
+}
+
+func (s *Set) updateURRs(ies []*ie.IE) *failure {
+	for _, i := range ies {
+		upd, err := parseUpdateURR(i)
+		if err != nil {
+			return incorrect(i.Type)
+		}
+		u, ok := s.URRs[upd.URRID]
+		if !ok {
+			return ruleFailure(ie.RuleIDTypeURR, upd.URRID)
+		}
+		s.URRs[u.URRID] = u.merge(upd)
+	}
+	return nil
+}
+
+func (s *Set) removeURRs(ies []*ie.IE) *failure {
+	for _, i := range ies {
+		c, err := removedID(i, ie.URRID)
+		if err != nil {
+			return incorrect(i.Type)
+		}
+		id, err := c.URRID()
+		if err != nil {
+			return incorrect(i.Type)
+		}
+		if _, ok := s.URRs[id]; !ok {
+			return ruleFailure(ie.RuleIDTypeURR, id)
+		}
+		delete(s.URRs, id)
+	}
+	return nil
+}
+
+func (s *Set) createBAR(ies []*ie.IE) *failure {
+	for _, i := range ies {
+		b, err := ParseBAR(i)
+		if err != nil {
+			return incorrect(i.Type)
+		}
+		if s.BAR != nil {
+			return ruleFailure(ie.RuleIDTypeBAR, uint32(b.BARID))
+		}
+		s.BAR = b
+	}
+	return nil
+}
+
+func (s *Set) updateBAR(ies []*ie.IE) *failure {
+	for _, i := range ies {
+		upd, err := parseUpdateBAR(i)
+		if err != nil {
+			return incorrect(i.Type)
+		}
+		if s.BAR == nil || s.BAR.BARID != upd.BARID {
+			return ruleFailure(ie.RuleIDTypeBAR, uint32(upd.BARID))
+		}
+		s.BAR = s.BAR.merge(upd)
+	}
+	return nil
+}
+
+func (s *Set) removeBAR(ies []*ie.IE) *failure {
+	for _, i := range ies {
+		c, err := removedID(i, ie.BARID)
+		if err != nil {
+			return incorrect(i.Type)
+		}
+		id, err := c.BARID()
+		if err != nil {
+			return incorrect(i.Type)
+		}
+		if s.BAR == nil || s.BAR.BARID != id {
+			return ruleFailure(ie.RuleIDTypeBAR, uint32(id))
+		}
+		s.BAR = nil
+	}
+	return nil
+}
+
+func (s *Set) createMARs(ies []*ie.IE) *failure {
+	for _, i := range ies {
+		m, err := ParseMAR(i)
+		if err != nil {
+			return incorrect(i.Type)
+		}
+		if _, ok := s.MARs[m.MARID]; ok {
+			return ruleFailure(ie.RuleIDTypeMAR, uint32(m.MARID))
+		}
+		s.MARs[m.MARID] = m
+	}
+	return nil
+}
+
+func (s *Set) updateMARs(ies []*ie.IE) *failure {
+	for _, i := range ies {
+		upd, err := parseUpdateMAR(i)
+		if err != nil {
+			return incorrect(i.Type)
+		}
+		m, ok := s.MARs[upd.MARID]
+		if !ok {
+			return ruleFailure(ie.RuleIDTypeMAR, uint32(upd.MARID))
+		}
+		s.MARs[m.MARID] = m.merge(upd)
+	}
+	return nil
+}
+
+func (s *Set) removeMARs(ies []*ie.IE) *failure {
+	for _, i := range ies {
+		c, err := removedID(i, ie.MARID)
+		if err != nil {
+			return incorrect(i.Type)
+		}
+		id, err := c.MARID()
+		if err != nil {
+			return incorrect(i.Type)
+		}
+		if _, ok := s.MARs[id]; !ok {
+			return ruleFailure(ie.RuleIDTypeMAR, uint32(id))
+		}
+		delete(s.MARs, id)
+	}
+	return nil
+}
+
+func (s *Set) createSRRs(ies []*ie.IE) *failure {
+	for _, i := range ies {
+		r, err := ParseSRR(i)
+		if err != nil {
+			return incorrect(i.Type)
+		}
+		if _, ok := s.SRRs[r.SRRID]; ok {
+			return ruleFailure(ie.RuleIDTypeSRR, uint32(r.SRRID))
+		}
+		s.SRRs[r.SRRID] = r
+	}
+	return nil
+}
+
+func (s *Set) updateSRRs(ies []*ie.IE) *failure {
+	for _, i := range ies {
+		upd, err := parseUpdateSRR(i)
+		if err != nil {
+			return incorrect(i.Type)
+		}
+		r, ok := s.SRRs[upd.SRRID]
+		if !ok {
+			return ruleFailure(ie.RuleIDTypeSRR, uint32(upd.SRRID))
+		}
+		s.SRRs[r.SRRID] = r.merge(upd)
+	}
+	return nil
+}
+
+func (s *Set) removeSRRs(ies []*ie.IE) *failure {
+	for _, i := range ies {
+		c, err := removedID(i, ie.SRRID)
+		if err != nil {
+			return incorrect(i.Type)
+		}
+		id, err := c.SRRID()
+		if err != nil {
+			return incorrect(i.Type)
+		}
+		if _, ok := s.SRRs[id]; !ok {
+			return ruleFailure(ie.RuleIDTypeSRR, uint32(id))
+		}
+		delete(s.SRRs, id)
+	}
+	return nil
+}
+
+func (s *Set) createTrafficEndpoints(ies []*ie.IE) *failure {
+	for _, i := range ies {
+		t, err := ParseTrafficEndpoint(i)
+		if err != nil {
+			return incorrect(i.Type)
+		}
+		if _, ok := s.TrafficEndpoints[t.TrafficEndpointID]; ok {
+			return trafficEndpointFailure()
+		}
+		s.TrafficEndpoints[t.TrafficEndpointID] = t
+	}
+	return nil
+}
+
+func (s *Set) updateTrafficEndpoints(ies []*ie.IE) *failure {
+	for _, i := range ies {
+		upd, err := parseUpdateTrafficEndpoint(i)
+		if err != nil {
+			return incorrect(i.Type)
+		}
+		t, ok := s.TrafficEndpoints[upd.TrafficEndpointID]
+		if !ok {
+			return trafficEndpointFailure()
+		}
+		s.TrafficEndpoints[t.TrafficEndpointID] = t.merge(upd)
+	}
+	return nil
+}
+
+func (s *Set) removeTrafficEndpoints(ies []*ie.IE) *failure {
+	for _, i := range ies {
+		c, err := removedID(i, ie.TrafficEndpointID)
+		if err != nil {
+			return incorrect(i.Type)
+		}
+		id, err := c.TrafficEndpointID()
+		if err != nil {
+			return incorrect(i.Type)
+		}
+		if _, ok := s.TrafficEndpoints[id]; !ok {
+			return trafficEndpointFailure()
+		}
+		delete(s.TrafficEndpoints, id)
+	}
+	return nil
+}
+
+// removedID returns the rule ID IE of itype in a RemoveXXX IE.
+func removedID(i *ie.IE, itype uint16) (*ie.IE, error) {
+	ies, err := children(i)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range ies {
+		if c.Type == itype {
+			return c, nil
+		}
+	}
+	return nil, ie.ErrIENotFound
+}
+
+// validate checks that all the rules referred by the other rules exist.
+func (s *Set) validate() *failure {
+	pdrIDs := make([]uint16, 0, len(s.PDRs))
+	for id := range s.PDRs {
+		pdrIDs = append(pdrIDs, id)
+	}
+	sort.Slice(pdrIDs, func(i, j int) bool { return pdrIDs[i] < pdrIDs[j] })
+
+	for _, id := range pdrIDs {
+		p := s.PDRs[id]
+		fail := ruleFailure(ie.RuleIDTypePDR, uint32(p.PDRID))
+		if p.FARID != nil {
+			if _, ok := s.FARs[*p.FARID]; !ok {
+				return fail
+			}
+		}
+		for _, id := range p.QERIDs {
+			if _, ok := s.QERs[id]; !ok {
+				return fail
+			}
+		}
+		for _, id := range p.URRIDs {
+			if _, ok := s.URRs[id]; !ok {
+				return fail
+			}
+		}
+		if p.MARID != nil {
+			if _, ok := s.MARs[*p.MARID]; !ok {
+				return fail
+			}
+		}
+		if p.PDI != nil {
+			for _, id := range p.PDI.TrafficEndpointIDs {
+				if _, ok := s.TrafficEndpoints[id]; !ok {
+					return fail
+				}
+			}
+		}
+	}
+
+	farIDs := make([]uint32, 0, len(s.FARs))
+	for id := range s.FARs {
+		farIDs = append(farIDs, id)
+	}
+	sort.Slice(farIDs, func(i, j int) bool { return farIDs[i] < farIDs[j] })
+
+	for _, id := range farIDs {
+		f := s.FARs[id]
+		if f.BARID != nil && (s.BAR == nil || s.BAR.BARID != *f.BARID) {
+			return ruleFailure(ie.RuleIDTypeFAR, f.FARID)
+		}
+	}
+
+	urrIDs := make([]uint32, 0, len(s.URRs))
+	for id := range s.URRs {
+		urrIDs = append(urrIDs, id)
+	}
+	sort.Slice(urrIDs, func(i, j int) bool { return urrIDs[i] < urrIDs[j] })
+
+	for _, id := range urrIDs {
+		u := s.URRs[id]
+		for _, id := range u.LinkedURRIDs {
+			if _, ok := s.URRs[id]; !ok {
+				return ruleFailure(ie.RuleIDTypeURR, u.URRID)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package rules_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+	"github.com/wmnsk/go-pfcp/rules"
+)
+
+func TestApply(t *testing.T) {
+	s := rules.NewSet()
+
+	est := message.NewSessionEstablishmentRequest(0, 0, 0, 1, 0,
+		ie.NewCreatePDR(
+			ie.NewPDRID(1),
+			ie.NewPDI(
+				ie.NewSourceInterface(ie.SrcInterfaceAccess),
+				ie.NewFTEID(0x11111111, net.ParseIP("127.0.0.1"), nil, nil),
+			),
+			ie.NewFARID(1),
+		),
+		ie.NewCreatePDR(
+			ie.NewPDRID(2),
+			ie.NewPDI(ie.NewSourceInterface(ie.SrcInterfaceCore)),
+			ie.NewFARID(2),
+		),
+		ie.NewCreateFAR(ie.NewFARID(1), ie.NewApplyAction(0x02)),
+	)
+
+	// FAR 2 is missing.
+	res := s.ApplyEstablishment(est)
+	if res.Cause != ie.CauseRuleCreationModificationFailure {
+		t.Fatalf("got cause %d", res.Cause)
+	}
+	if typ, _ := res.FailedRuleID.RuleIDType(); typ != ie.RuleIDTypePDR {
+		t.Errorf("got rule ID type %d", typ)
+	}
+	if id, _ := res.FailedRuleID.FailedRuleID(); id != 2 {
+		t.Errorf("got rule ID %d", id)
+	}
+	if len(s.PDRs) != 0 || len(s.FARs) != 0 {
+		t.Fatalf("rules created in rejected request: %+v", s)
+	}
+
+	est.CreateFAR = append(est.CreateFAR, ie.NewCreateFAR(ie.NewFARID(2), ie.NewApplyAction(0x02)))
+	res = s.ApplyEstablishment(est)
+	if !res.Accepted() {
+		t.Fatalf("got cause %d", res.Cause)
+	}
+	if len(s.PDRs) != 2 || len(s.FARs) != 2 {
+		t.Fatalf("got unexpected rules: %+v", s)
+	}
+	ies := res.IEs()
+	if len(ies) != 2 || ies[1].Type != ie.CreatedPDR {
+		t.Fatalf("got unexpected IEs: %v", ies)
+	}
+	if f, err := ies[1].FTEID(); err != nil || f.TEID != 0x11111111 {
+		t.Errorf("got unexpected F-TEID: %+v, %v", f, err)
+	}
+
+	mod := message.NewSessionModificationRequest(0, 0, 1, 2, 0,
+		ie.NewRemovePDR(ie.NewPDRID(2)),
+		ie.NewRemoveFAR(ie.NewFARID(2)),
+		ie.NewUpdateFAR(
+			ie.NewFARID(1),
+			ie.NewUpdateForwardingParameters(ie.NewDestinationInterface(ie.DstInterfaceCore)),
+		),
+	)
+	res = s.ApplyModification(mod)
+	if !res.Accepted() {
+		t.Fatalf("got cause %d", res.Cause)
+	}
+	if _, ok := s.PDRs[2]; ok {
+		t.Error("PDR 2 not removed")
+	}
+	f := s.FARs[1]
+	if f.ApplyAction == nil || f.ForwardingParameters == nil || *f.ForwardingParameters.DestinationInterface != ie.DstInterfaceCore {
+		t.Errorf("got unexpected FAR: %+v", f)
+	}
+
+	// FAR 1 is still referred by PDR 1.
+	res = s.ApplyModification(message.NewSessionModificationRequest(0, 0, 1, 3, 0,
+		ie.NewRemoveFAR(ie.NewFARID(1)),
+	))
+	if res.Cause != ie.CauseRuleCreationModificationFailure {
+		t.Fatalf("got cause %d", res.Cause)
+	}
+	if _, ok := s.FARs[1]; !ok {
+		t.Error("FAR 1 removed in rejected request")
+	}
+
+	res = s.ApplyModification(message.NewSessionModificationRequest(0, 0, 1, 4, 0,
+		ie.NewUpdateQER(ie.NewQERID(1)),
+	))
+	if id, _ := res.FailedRuleID.FailedRuleID(); res.Cause != ie.CauseRuleCreationModificationFailure || id != 1 {
+		t.Errorf("got unexpected result: %+v", res)
+	}
+}
+
+func TestApplyFailedRuleIDOrder(t *testing.T) {
+	// FARs 1-8 all refer to the missing BAR, and FAR 1 is always reported.
+	est := message.NewSessionEstablishmentRequest(0, 0, 0, 1, 0)
+	for id := uint32(8); id > 0; id-- {
+		est.CreateFAR = append(est.CreateFAR, ie.NewCreateFAR(ie.NewFARID(id), ie.NewApplyAction(0x04), ie.NewBARID(1)))
+	}
+
+	for i := 0; i < 10; i++ {
+		res := rules.NewSet().ApplyEstablishment(est)
+		if typ, _ := res.FailedRuleID.RuleIDType(); typ != ie.RuleIDTypeFAR {
+			t.Fatalf("got rule ID type %d", typ)
+		}
+		if id, _ := res.FailedRuleID.FailedRuleID(); id != 1 {
+			t.Fatalf("got rule ID %d", id)
+		}
+	}
+}
+
+func TestApplyFailedRuleIDType(t *testing.T) {
+	cases := []struct {
+		description string
+		ies         []*ie.IE
+		typ         uint8
+		id          uint32
+	}{
+		{
+			"UpdateMAR",
+			[]*ie.IE{ie.NewUpdateMAR(ie.NewMARID(0x1111), ie.NewSteeringFunctionality(0x01))},
+			ie.RuleIDTypeMAR, 0x1111,
+		}, {
+			"RemoveMAR",
+			[]*ie.IE{ie.NewRemoveMAR(ie.NewMARID(0x2222))},
+			ie.RuleIDTypeMAR, 0x2222,
+		}, {
+			"UpdateSRR",
+			[]*ie.IE{ie.NewUpdateSRR(ie.NewSRRID(0x11))},
+			ie.RuleIDTypeSRR, 0x11,
+		}, {
+			"RemoveSRR",
+			[]*ie.IE{ie.NewRemoveSRR(ie.NewSRRID(0x22))},
+			ie.RuleIDTypeSRR, 0x22,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			res := rules.NewSet().ApplyModification(message.NewSessionModificationRequest(0, 0, 1, 1, 0, c.ies...))
+			if res.Cause != ie.CauseRuleCreationModificationFailure {
+				t.Fatalf("got cause %d", res.Cause)
+			}
+			if typ, err := res.FailedRuleID.RuleIDType(); err != nil || typ != c.typ {
+				t.Errorf("got rule ID type %d, %v want %d", typ, err, c.typ)
+			}
+			if id, err := res.FailedRuleID.FailedRuleID(); err != nil || id != c.id {
+				t.Errorf("got rule ID %#x, %v want %#x", id, err, c.id)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package rules
+
+import (
+	"time"
+
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+// BAR is a Buffering Action Rule.
+type BAR struct {
+	BARID                          uint8
+	DownlinkDataNotificationDelay  *time.Duration
+	SuggestedBufferingPacketsCount *uint8
+	// IEs are the other child IEs, e.g., MTEDTControlInformation.
+	IEs []*ie.IE
+}
+
+// ParseBAR parses CreateBAR IE into BAR.
+func ParseBAR(i *ie.IE) (*BAR, error) {
+	b := &BAR{}
+	if err := b.FromIE(i); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// FromIE parses CreateBAR IE into b.
+func (b *BAR) FromIE(i *ie.IE) error {
+	return b.parse(i, ie.CreateBAR)
+}
+
+// parseUpdateBAR parses UpdateBAR(within SessionModificationRequest) IE into
+// BAR, which has only the fields to be changed.
+func parseUpdateBAR(i *ie.IE) (*BAR, error) {
+	b := &BAR{}
+	if err := b.parse(i, ie.UpdateBARWithinSessionModificationRequest); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// parse parses the IE of type typ into b.
+func (b *BAR) parse(i *ie.IE, typ uint16) error {
+	if i.Type != typ {
+		return &ie.InvalidTypeError{Type: i.Type}
+	}
+
+	ies, err := children(i)
+	if err != nil {
+		return err
+	}
+	if !hasChild(ies, ie.BARID) {
+		return ie.ErrIENotFound
+	}
+
+	*b = BAR{}
+	for _, c := range ies {
+		switch c.Type {
+		case ie.BARID:
+			v, err := c.BARID()
+			if err != nil {
+				return err
+			}
+			b.BARID = v
+		case ie.DownlinkDataNotificationDelay:
+			v, err := c.DownlinkDataNotificationDelay()
+			if err != nil {
+				return err
+			}
+			b.DownlinkDataNotificationDelay = &v
+		case ie.SuggestedBufferingPacketsCount:
+			v, err := c.SuggestedBufferingPacketsCount()
+			if err != nil {
+				return err
+			}
+			b.SuggestedBufferingPacketsCount = &v
+		default:
+			b.IEs = append(b.IEs, c)
+		}
+	}
+	return nil
+}
+
+// ToIE creates CreateBAR IE from b.
+func (b *BAR) ToIE() *ie.IE {
+	return ie.NewCreateBAR(b.childIEs()...)
+}
+
+func (b *BAR) childIEs() []*ie.IE {
+	ies := []*ie.IE{ie.NewBARID(b.BARID)}
+	if b.DownlinkDataNotificationDelay != nil {
+		ies = append(ies, ie.NewDownlinkDataNotificationDelay(*b.DownlinkDataNotificationDelay))
+	}
+	if b.SuggestedBufferingPacketsCount != nil {
+		ies = append(ies, ie.NewSuggestedBufferingPacketsCount(*b.SuggestedBufferingPacketsCount))
+	}
+	return append(ies, b.IEs...)
+}
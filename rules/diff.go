@@ -0,0 +1,433 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package rules
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// NewSessionModificationRequest creates a new SessionModificationRequest that
+// makes the rules in cur the ones in desired, with the additional ies.
+//
+// See Diff for how the rules are compared.
+func NewSessionModificationRequest(mp, fo uint8, seid uint64, seq uint32, pri uint8, cur, desired *Set, ies ...*ie.IE) *message.SessionModificationRequest {
+	return message.NewSessionModificationRequest(mp, fo, seid, seq, pri, append(Diff(cur, desired), ies...)...)
+}
+
+// Diff returns the RemoveXXX, CreateXXX and UpdateXXX IEs that make the rules
+// in cur the ones in desired.
+//
+// The rules are compared by their serialized child IEs. UpdateXXX IE contains
+// only the rule ID and the child IEs that have changed. As a child IE cannot
+// be removed with UpdateXXX IE, the rule is removed and created again if any
+// of the child IEs in cur is absent in desired, except for the predefined
+// rules that are deactivated with DeactivatePredefinedRules IE.
+//
+// UpdateDuplicatingParameters IEs are put for all the DuplicatingParameters
+// in order if any of them has changed, as they are identified by the order.
+// The rules are removed and created again if the number of the
+// DuplicatingParameters has changed.
+func Diff(cur, desired *Set) []*ie.IE {
+	var removes, creates, updates []*ie.IE
+	add := func(r, c, u []*ie.IE) {
+		removes = append(removes, r...)
+		creates = append(creates, c...)
+		updates = append(updates, u...)
+	}
+
+	add(diffRules(pdrs(cur.PDRs), pdrs(desired.PDRs)))
+	add(diffRules(fars(cur.FARs), fars(desired.FARs)))
+	add(diffRules(qers(cur.QERs), qers(desired.QERs)))
+	add(diffRules(urrs(cur.URRs), urrs(desired.URRs)))
+	add(diffRules(bars(cur.BAR), bars(desired.BAR)))
+	add(diffRules(mars(cur.MARs), mars(desired.MARs)))
+	add(diffRules(srrs(cur.SRRs), srrs(desired.SRRs)))
+	add(diffRules(trafficEndpoints(cur.TrafficEndpoints), trafficEndpoints(desired.TrafficEndpoints)))
+
+	return append(append(removes, creates...), updates...)
+}
+
+// rule is the common behavior of the rules used in Diff.
+type rule interface {
+	ToIE() *ie.IE
+	// removeIE returns RemoveXXX IE of the rule.
+	removeIE() *ie.IE
+	// updateIE returns UpdateXXX IE that makes the rule desired, or nil
+	// if nothing has changed. ok is false if the rule cannot be updated.
+	updateIE(desired rule) (upd *ie.IE, ok bool)
+}
+
+// diffRules returns the IEs that make the rules in cur the ones in desired.
+func diffRules(cur, desired map[uint32]rule) (removes, creates, updates []*ie.IE) {
+	for _, id := range sortedIDs(cur) {
+		if _, ok := desired[id]; !ok {
+			removes = append(removes, cur[id].removeIE())
+		}
+	}
+	for _, id := range sortedIDs(desired) {
+		d := desired[id]
+		c, ok := cur[id]
+		if !ok {
+			creates = append(creates, d.ToIE())
+			continue
+		}
+
+		upd, ok := c.updateIE(d)
+		if !ok {
+			removes = append(removes, c.removeIE())
+			creates = append(creates, d.ToIE())
+			continue
+		}
+		if upd != nil {
+			updates = append(updates, upd)
+		}
+	}
+	return
+}
+
+func sortedIDs(rs map[uint32]rule) []uint32 {
+	ids := make([]uint32, 0, len(rs))
+	for id := range rs {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func pdrs(m map[uint16]*PDR) map[uint32]rule {
+	rs := make(map[uint32]rule, len(m))
+	for id, r := range m {
+		rs[uint32(id)] = r
+	}
+	return rs
+}
+
+func fars(m map[uint32]*FAR) map[uint32]rule {
+	rs := make(map[uint32]rule, len(m))
+	for id, r := range m {
+		rs[id] = r
+	}
+	return rs
+}
+
+func qers(m map[uint32]*QER) map[uint32]rule {
+	rs := make(map[uint32]rule, len(m))
+	for id, r := range m {
+		rs[id] = r
+	}
+	return rs
+}
+
+func urrs(m map[uint32]*URR) map[uint32]rule {
+	rs := make(map[uint32]rule, len(m))
+	for id, r := range m {
+		rs[id] = r
+	}
+	return rs
+}
+
+func bars(b *BAR) map[uint32]rule {
+	if b == nil {
+		return nil
+	}
+	return map[uint32]rule{uint32(b.BARID): b}
+}
+
+func mars(m map[uint16]*MAR) map[uint32]rule {
+	rs := make(map[uint32]rule, len(m))
+	for id, r := range m {
+		rs[uint32(id)] = r
+	}
+	return rs
+}
+
+func srrs(m map[uint8]*SRR) map[uint32]rule {
+	rs := make(map[uint32]rule, len(m))
+	for id, r := range m {
+		rs[uint32(id)] = r
+	}
+	return rs
+}
+
+func trafficEndpoints(m map[uint8]*TrafficEndpoint) map[uint32]rule {
+	rs := make(map[uint32]rule, len(m))
+	for id, r := range m {
+		rs[uint32(id)] = r
+	}
+	return rs
+}
+
+// changedIEs returns the IEs in desired of the types whose IEs are different
+// from the ones in cur. ok is false if any type of IEs in cur is absent in
+// desired.
+func changedIEs(cur, desired []*ie.IE) (changed []*ie.IE, ok bool) {
+	c, d := serializeByType(cur), serializeByType(desired)
+	for t := range c {
+		if _, ok := d[t]; !ok {
+			return nil, false
+		}
+	}
+
+	for _, i := range desired {
+		if equalBytes(c[i.Type], d[i.Type]) {
+			continue
+		}
+		changed = append(changed, i)
+	}
+	return changed, true
+}
+
+// serializeByType returns the serialized IEs grouped by the type.
+// The IEs that cannot be serialized are treated as empty ones.
+func serializeByType(ies []*ie.IE) map[uint16][][]byte {
+	m := make(map[uint16][][]byte)
+	for _, i := range ies {
+		if i == nil {
+			continue
+		}
+		b, _ := i.Marshal()
+		m[i.Type] = append(m[i.Type], b)
+	}
+	return m
+}
+
+func equalBytes(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// newUpdateIE returns an update IE created by f with id and changed, or nil
+// if nothing has changed.
+func newUpdateIE(f func(ies ...*ie.IE) *ie.IE, id *ie.IE, changed []*ie.IE) *ie.IE {
+	if len(changed) == 0 {
+		return nil
+	}
+	return f(append([]*ie.IE{id}, changed...)...)
+}
+
+func (p *PDR) removeIE() *ie.IE {
+	return ie.NewRemovePDR(ie.NewPDRID(p.PDRID))
+}
+
+func (p *PDR) updateIE(desired rule) (*ie.IE, bool) {
+	d := desired.(*PDR)
+
+	// compare without the predefined rules, which are activated or
+	// deactivated separately.
+	c, n := *p, *d
+	c.ActivatePredefinedRules, n.ActivatePredefinedRules = nil, nil
+	changed, ok := changedIEs(c.childIEs(), n.childIEs())
+	if !ok {
+		return nil, false
+	}
+
+	active := make(map[string]bool)
+	for _, name := range p.ActivatePredefinedRules {
+		active[name] = true
+	}
+	for _, name := range d.ActivatePredefinedRules {
+		if !active[name] {
+			changed = append(changed, ie.NewActivatePredefinedRules(name))
+		}
+		delete(active, name)
+	}
+	for _, name := range p.ActivatePredefinedRules {
+		if active[name] {
+			changed = append(changed, ie.NewDeactivatePredefinedRules(name))
+		}
+	}
+
+	return newUpdateIE(ie.NewUpdatePDR, ie.NewPDRID(p.PDRID), changed), true
+}
+
+func (f *FAR) removeIE() *ie.IE {
+	return ie.NewRemoveFAR(ie.NewFARID(f.FARID))
+}
+
+func (f *FAR) updateIE(desired rule) (*ie.IE, bool) {
+	d := desired.(*FAR)
+
+	// compare without the parameters, which are updated with the dedicated
+	// IEs.
+	c, n := *f, *d
+	c.ForwardingParameters, n.ForwardingParameters = nil, nil
+	c.DuplicatingParameters, n.DuplicatingParameters = nil, nil
+	changed, ok := changedIEs(c.childIEs(), n.childIEs())
+	if !ok {
+		return nil, false
+	}
+
+	switch {
+	case f.ForwardingParameters == nil && d.ForwardingParameters != nil:
+		changed = append(changed, ie.NewUpdateForwardingParameters(d.ForwardingParameters.childIEs()...))
+	case f.ForwardingParameters != nil && d.ForwardingParameters == nil:
+		return nil, false
+	case f.ForwardingParameters != nil:
+		fp, ok := changedIEs(f.ForwardingParameters.childIEs(), d.ForwardingParameters.childIEs())
+		if !ok {
+			return nil, false
+		}
+		if len(fp) > 0 {
+			changed = append(changed, ie.NewUpdateForwardingParameters(fp...))
+		}
+	}
+
+	if len(f.DuplicatingParameters) != len(d.DuplicatingParameters) {
+		return nil, false
+	}
+	var dps []*ie.IE
+	dpChanged := false
+	for i, dp := range f.DuplicatingParameters {
+		ies, ok := changedIEs(dp.childIEs(), d.DuplicatingParameters[i].childIEs())
+		if !ok {
+			return nil, false
+		}
+		if len(ies) > 0 {
+			dpChanged = true
+		}
+		if di := d.DuplicatingParameters[i].DestinationInterface; di != nil && !containsIEType(ies, ie.DestinationInterface) {
+			ies = append([]*ie.IE{ie.NewDestinationInterface(*di)}, ies...)
+		}
+		dps = append(dps, ie.NewUpdateDuplicatingParameters(ies...))
+	}
+	if dpChanged {
+		changed = append(changed, dps...)
+	}
+
+	return newUpdateIE(ie.NewUpdateFAR, ie.NewFARID(f.FARID), changed), true
+}
+
+func containsIEType(ies []*ie.IE, t uint16) bool {
+	for _, i := range ies {
+		if i.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *QER) removeIE() *ie.IE {
+	return ie.NewRemoveQER(ie.NewQERID(q.QERID))
+}
+
+func (q *QER) updateIE(desired rule) (*ie.IE, bool) {
+	changed, ok := changedIEs(q.childIEs(), desired.(*QER).childIEs())
+	if !ok {
+		return nil, false
+	}
+	return newUpdateIE(ie.NewUpdateQER, ie.NewQERID(q.QERID), changed), true
+}
+
+func (u *URR) removeIE() *ie.IE {
+	return ie.NewRemoveURR(ie.NewURRID(u.URRID))
+}
+
+func (u *URR) updateIE(desired rule) (*ie.IE, bool) {
+	changed, ok := changedIEs(u.childIEs(), desired.(*URR).childIEs())
+	if !ok {
+		return nil, false
+	}
+	return newUpdateIE(ie.NewUpdateURR, ie.NewURRID(u.URRID), changed), true
+}
+
+func (b *BAR) removeIE() *ie.IE {
+	return ie.NewRemoveBAR(ie.NewBARID(b.BARID))
+}
+
+func (b *BAR) updateIE(desired rule) (*ie.IE, bool) {
+	changed, ok := changedIEs(b.childIEs(), desired.(*BAR).childIEs())
+	if !ok {
+		return nil, false
+	}
+	return newUpdateIE(ie.NewUpdateBARWithinSessionModificationRequest, ie.NewBARID(b.BARID), changed), true
+}
+
+func (m *MAR) removeIE() *ie.IE {
+	return ie.NewRemoveMAR(ie.NewMARID(m.MARID))
+}
+
+func (m *MAR) updateIE(desired rule) (*ie.IE, bool) {
+	d := desired.(*MAR)
+
+	c, n := *m, *d
+	c.TGPPAccessForwardingActionInformation, n.TGPPAccessForwardingActionInformation = nil, nil
+	c.NonTGPPAccessForwardingActionInformation, n.NonTGPPAccessForwardingActionInformation = nil, nil
+	changed, ok := changedIEs(c.childIEs(), n.childIEs())
+	if !ok {
+		return nil, false
+	}
+
+	for _, fai := range []struct {
+		cur, desired *ie.IE
+		update       func(ies ...*ie.IE) *ie.IE
+	}{
+		{m.TGPPAccessForwardingActionInformation, d.TGPPAccessForwardingActionInformation, ie.NewUpdateTGPPAccessForwardingActionInformation},
+		{m.NonTGPPAccessForwardingActionInformation, d.NonTGPPAccessForwardingActionInformation, ie.NewUpdateNonTGPPAccessForwardingActionInformation},
+	} {
+		if fai.desired == nil {
+			if fai.cur != nil {
+				return nil, false
+			}
+			continue
+		}
+
+		var cur []*ie.IE
+		if fai.cur != nil {
+			v, err := children(fai.cur)
+			if err != nil {
+				return nil, false
+			}
+			cur = v
+		}
+		des, err := children(fai.desired)
+		if err != nil {
+			return nil, false
+		}
+		ies, ok := changedIEs(cur, des)
+		if !ok {
+			return nil, false
+		}
+		if len(ies) > 0 {
+			changed = append(changed, fai.update(ies...))
+		}
+	}
+
+	return newUpdateIE(ie.NewUpdateMAR, ie.NewMARID(m.MARID), changed), true
+}
+
+func (s *SRR) removeIE() *ie.IE {
+	return ie.NewRemoveSRR(ie.NewSRRID(s.SRRID))
+}
+
+func (s *SRR) updateIE(desired rule) (*ie.IE, bool) {
+	changed, ok := changedIEs(s.childIEs(), desired.(*SRR).childIEs())
+	if !ok {
+		return nil, false
+	}
+	return newUpdateIE(ie.NewUpdateSRR, ie.NewSRRID(s.SRRID), changed), true
+}
+
+func (t *TrafficEndpoint) removeIE() *ie.IE {
+	return ie.NewRemoveTrafficEndpoint(ie.NewTrafficEndpointID(t.TrafficEndpointID))
+}
+
+func (t *TrafficEndpoint) updateIE(desired rule) (*ie.IE, bool) {
+	changed, ok := changedIEs(t.childIEs(), desired.(*TrafficEndpoint).childIEs())
+	if !ok {
+		return nil, false
+	}
+	return newUpdateIE(ie.NewUpdateTrafficEndpoint, ie.NewTrafficEndpointID(t.TrafficEndpointID), changed), true
+}
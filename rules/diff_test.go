@@ -0,0 +1,194 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package rules_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+	"github.com/wmnsk/go-pfcp/rules"
+)
+
+func mustParse(t *testing.T, i *ie.IE, r rule) rule {
+	t.Helper()
+
+	b, err := i.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ie.Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.FromIE(parsed); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// serializeSet returns the serialized rules in s keyed by the IE type and ID.
+func serializeSet(t *testing.T, s *rules.Set) map[string][]byte {
+	t.Helper()
+
+	m := make(map[string][]byte)
+	add := func(key string, r rule) {
+		b, err := r.ToIE().Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		m[key] = b
+	}
+	for id, r := range s.PDRs {
+		add("PDR"+fmt.Sprint(id), r)
+	}
+	for id, r := range s.FARs {
+		add("FAR"+fmt.Sprint(id), r)
+	}
+	for id, r := range s.QERs {
+		add("QER"+fmt.Sprint(id), r)
+	}
+	for id, r := range s.URRs {
+		add("URR"+fmt.Sprint(id), r)
+	}
+	if s.BAR != nil {
+		add("BAR", s.BAR)
+	}
+	for id, r := range s.MARs {
+		add("MAR"+fmt.Sprint(id), r)
+	}
+	return m
+}
+
+func TestDiff(t *testing.T) {
+	cur := rules.NewSet()
+	res := cur.ApplyEstablishment(message.NewSessionEstablishmentRequest(0, 0, 0, 1, 0,
+		ie.NewCreatePDR(
+			ie.NewPDRID(1),
+			ie.NewPrecedence(100),
+			ie.NewPDI(ie.NewSourceInterface(ie.SrcInterfaceAccess)),
+			ie.NewFARID(1),
+			ie.NewQERID(1),
+			ie.NewActivatePredefinedRules("rule-a"),
+		),
+		ie.NewCreatePDR(
+			ie.NewPDRID(2),
+			ie.NewPDI(ie.NewSourceInterface(ie.SrcInterfaceCore)),
+			ie.NewFARID(2),
+		),
+		ie.NewCreateFAR(
+			ie.NewFARID(1),
+			ie.NewApplyAction(0x02),
+			ie.NewForwardingParameters(
+				ie.NewDestinationInterface(ie.DstInterfaceCore),
+				ie.NewNetworkInstance("internet"),
+			),
+			ie.NewDuplicatingParameters(
+				ie.NewDestinationInterface(ie.DstInterfaceLIFunction),
+				ie.NewTransportLevelMarking(0x1111),
+			),
+		),
+		ie.NewCreateFAR(ie.NewFARID(2), ie.NewApplyAction(0x02)),
+		ie.NewCreateQER(ie.NewQERID(1), ie.NewGateStatus(ie.GateStatusOpen, ie.GateStatusOpen)),
+		ie.NewCreateURR(ie.NewURRID(1), ie.NewMeasurementMethod(0, 1, 0)),
+		ie.NewCreateBAR(ie.NewBARID(1), ie.NewSuggestedBufferingPacketsCount(10)),
+		ie.NewCreateMAR(
+			ie.NewMARID(1),
+			ie.NewSteeringFunctionality(0x01),
+			ie.NewTGPPAccessForwardingActionInformation(ie.NewFARID(1), ie.NewWeight(0x01)),
+		),
+	))
+	if !res.Accepted() {
+		t.Fatalf("got cause %d", res.Cause)
+	}
+
+	desired := cur.Clone()
+	// PDR 2 and its FAR are removed.
+	delete(desired.PDRs, 2)
+	delete(desired.FARs, 2)
+	// PDR 1 gets new precedence and predefined rule.
+	desired.PDRs[1] = mustParse(t, ie.NewCreatePDR(
+		ie.NewPDRID(1),
+		ie.NewPrecedence(200),
+		ie.NewPDI(ie.NewSourceInterface(ie.SrcInterfaceAccess)),
+		ie.NewFARID(1),
+		ie.NewQERID(1),
+		ie.NewActivatePredefinedRules("rule-b"),
+	), &rules.PDR{}).(*rules.PDR)
+	// FAR 1 forwards to another network instance.
+	desired.FARs[1] = mustParse(t, ie.NewCreateFAR(
+		ie.NewFARID(1),
+		ie.NewApplyAction(0x02),
+		ie.NewForwardingParameters(
+			ie.NewDestinationInterface(ie.DstInterfaceCore),
+			ie.NewNetworkInstance("ims"),
+		),
+		ie.NewDuplicatingParameters(
+			ie.NewDestinationInterface(ie.DstInterfaceLIFunction),
+			ie.NewTransportLevelMarking(0x2222),
+		),
+	), &rules.FAR{}).(*rules.FAR)
+	// URR 1 is replaced, as MeasurementMethod cannot be removed.
+	desired.URRs[1] = mustParse(t, ie.NewCreateURR(ie.NewURRID(1)), &rules.URR{}).(*rules.URR)
+	// new URR.
+	desired.URRs[2] = mustParse(t, ie.NewCreateURR(ie.NewURRID(2), ie.NewMeasurementMethod(1, 0, 0)), &rules.URR{}).(*rules.URR)
+	// MAR 1 gets new weight.
+	desired.MARs[1] = mustParse(t, ie.NewCreateMAR(
+		ie.NewMARID(1),
+		ie.NewSteeringFunctionality(0x01),
+		ie.NewTGPPAccessForwardingActionInformation(ie.NewFARID(1), ie.NewWeight(0x02)),
+	), &rules.MAR{}).(*rules.MAR)
+
+	req := rules.NewSessionModificationRequest(0, 0, 1, 2, 0, cur, desired)
+	if len(req.CreateQER) != 0 || len(req.UpdateQER) != 0 || req.UpdateBAR != nil || req.RemoveBAR != nil {
+		t.Errorf("got IEs for unchanged rules: %+v", req)
+	}
+	if len(req.RemovePDR) != 1 || len(req.RemoveFAR) != 1 || len(req.RemoveURR) != 1 || len(req.CreateURR) != 2 {
+		t.Errorf("got unexpected IEs: %+v", req)
+	}
+	if len(req.UpdateFAR) != 1 {
+		t.Fatalf("got unexpected UpdateFAR: %v", req.UpdateFAR)
+	}
+	want, err := ie.NewUpdateFAR(
+		ie.NewFARID(1),
+		ie.NewUpdateForwardingParameters(ie.NewNetworkInstance("ims")),
+		ie.NewUpdateDuplicatingParameters(
+			ie.NewDestinationInterface(ie.DstInterfaceLIFunction),
+			ie.NewTransportLevelMarking(0x2222),
+		),
+	).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := req.UpdateFAR[0].Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error(diff)
+	}
+
+	// the rules applied on UP side should be the desired ones.
+	b, err := req.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := message.ParseSessionModificationRequest(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := cur.ApplyModification(parsed); !res.Accepted() {
+		t.Fatalf("got cause %d, failed rule %v", res.Cause, res.FailedRuleID)
+	}
+	if diff := cmp.Diff(serializeSet(t, desired), serializeSet(t, cur)); diff != "" {
+		t.Error(diff)
+	}
+
+	if ies := rules.Diff(cur, desired); len(ies) != 0 {
+		t.Errorf("got IEs for the same sets: %v", ies)
+	}
+}
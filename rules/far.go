@@ -0,0 +1,328 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package rules
+
+import (
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+// FAR is a Forwarding Action Rule.
+type FAR struct {
+	FARID                 uint32
+	ApplyAction           *ie.IE
+	ForwardingParameters  *ForwardingParameters
+	DuplicatingParameters []*DuplicatingParameters
+	BARID                 *uint8
+	// IEs are the other child IEs, e.g., RedundantTransmissionParameters.
+	IEs []*ie.IE
+}
+
+// ParseFAR parses CreateFAR IE into FAR.
+func ParseFAR(i *ie.IE) (*FAR, error) {
+	f := &FAR{}
+	if err := f.FromIE(i); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// FromIE parses CreateFAR IE into f.
+func (f *FAR) FromIE(i *ie.IE) error {
+	return f.parse(i, ie.CreateFAR)
+}
+
+// parseUpdateFAR parses UpdateFAR IE into FAR, which has only the fields to be
+// changed. UpdateForwardingParameters and UpdateDuplicatingParameters in it are
+// parsed into ForwardingParameters and DuplicatingParameters.
+func parseUpdateFAR(i *ie.IE) (*FAR, error) {
+	f := &FAR{}
+	if err := f.parse(i, ie.UpdateFAR); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// parse parses the IE of type typ into f.
+func (f *FAR) parse(i *ie.IE, typ uint16) error {
+	if i.Type != typ {
+		return &ie.InvalidTypeError{Type: i.Type}
+	}
+
+	ies, err := children(i)
+	if err != nil {
+		return err
+	}
+	if !hasChild(ies, ie.FARID) {
+		return ie.ErrIENotFound
+	}
+
+	fp, dp := ie.ForwardingParameters, ie.DuplicatingParameters
+	if typ == ie.UpdateFAR {
+		fp, dp = ie.UpdateForwardingParameters, ie.UpdateDuplicatingParameters
+	}
+
+	*f = FAR{}
+	for _, c := range ies {
+		switch c.Type {
+		case ie.FARID:
+			v, err := c.FARID()
+			if err != nil {
+				return err
+			}
+			f.FARID = v
+		case ie.ApplyAction:
+			f.ApplyAction = c
+		case fp:
+			v := &ForwardingParameters{}
+			if err := v.parse(c, fp); err != nil {
+				return err
+			}
+			f.ForwardingParameters = v
+		case dp:
+			v := &DuplicatingParameters{}
+			if err := v.parse(c, dp); err != nil {
+				return err
+			}
+			f.DuplicatingParameters = append(f.DuplicatingParameters, v)
+		case ie.BARID:
+			v, err := c.BARID()
+			if err != nil {
+				return err
+			}
+			f.BARID = &v
+		default:
+			f.IEs = append(f.IEs, c)
+		}
+	}
+	return nil
+}
+
+// ToIE creates CreateFAR IE from f.
+func (f *FAR) ToIE() *ie.IE {
+	return ie.NewCreateFAR(f.childIEs()...)
+}
+
+func (f *FAR) childIEs() []*ie.IE {
+	ies := []*ie.IE{ie.NewFARID(f.FARID)}
+	if f.ApplyAction != nil {
+		ies = append(ies, f.ApplyAction)
+	}
+	if f.ForwardingParameters != nil {
+		ies = append(ies, f.ForwardingParameters.ToIE())
+	}
+	for _, d := range f.DuplicatingParameters {
+		ies = append(ies, d.ToIE())
+	}
+	if f.BARID != nil {
+		ies = append(ies, ie.NewBARID(*f.BARID))
+	}
+	return append(ies, f.IEs...)
+}
+
+// ForwardingParameters is a Forwarding Parameters in FAR.
+type ForwardingParameters struct {
+	DestinationInterface  *uint8
+	NetworkInstance       *string
+	RedirectInformation   *ie.IE
+	OuterHeaderCreation   *ie.OuterHeaderCreationFields
+	TransportLevelMarking *uint16
+	ForwardingPolicy      *string
+	HeaderEnrichment      *ie.IE
+	// IEs are the other child IEs, e.g., Proxying.
+	IEs []*ie.IE
+}
+
+// ParseForwardingParameters parses ForwardingParameters IE into
+// ForwardingParameters.
+func ParseForwardingParameters(i *ie.IE) (*ForwardingParameters, error) {
+	p := &ForwardingParameters{}
+	if err := p.FromIE(i); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// FromIE parses ForwardingParameters IE into p.
+func (p *ForwardingParameters) FromIE(i *ie.IE) error {
+	return p.parse(i, ie.ForwardingParameters)
+}
+
+// parse parses the IE of type typ into p.
+func (p *ForwardingParameters) parse(i *ie.IE, typ uint16) error {
+	if i.Type != typ {
+		return &ie.InvalidTypeError{Type: i.Type}
+	}
+
+	ies, err := children(i)
+	if err != nil {
+		return err
+	}
+
+	*p = ForwardingParameters{}
+	for _, c := range ies {
+		switch c.Type {
+		case ie.DestinationInterface:
+			v, err := c.DestinationInterface()
+			if err != nil {
+				return err
+			}
+			p.DestinationInterface = &v
+		case ie.NetworkInstance:
+			v, err := c.NetworkInstance()
+			if err != nil {
+				return err
+			}
+			p.NetworkInstance = &v
+		case ie.RedirectInformation:
+			p.RedirectInformation = c
+		case ie.OuterHeaderCreation:
+			v, err := c.OuterHeaderCreation()
+			if err != nil {
+				return err
+			}
+			p.OuterHeaderCreation = v
+		case ie.TransportLevelMarking:
+			v, err := c.TransportLevelMarking()
+			if err != nil {
+				return err
+			}
+			p.TransportLevelMarking = &v
+		case ie.ForwardingPolicy:
+			v, err := c.ForwardingPolicyIdentifier()
+			if err != nil {
+				return err
+			}
+			p.ForwardingPolicy = &v
+		case ie.HeaderEnrichment:
+			p.HeaderEnrichment = c
+		default:
+			p.IEs = append(p.IEs, c)
+		}
+	}
+	return nil
+}
+
+// ToIE creates ForwardingParameters IE from p.
+func (p *ForwardingParameters) ToIE() *ie.IE {
+	return ie.NewForwardingParameters(p.childIEs()...)
+}
+
+func (p *ForwardingParameters) childIEs() []*ie.IE {
+	var ies []*ie.IE
+	if p.DestinationInterface != nil {
+		ies = append(ies, ie.NewDestinationInterface(*p.DestinationInterface))
+	}
+	if p.NetworkInstance != nil {
+		ies = append(ies, ie.NewNetworkInstance(*p.NetworkInstance))
+	}
+	if p.RedirectInformation != nil {
+		ies = append(ies, p.RedirectInformation)
+	}
+	if p.OuterHeaderCreation != nil {
+		ies = append(ies, newFieldsIE(ie.OuterHeaderCreation, p.OuterHeaderCreation))
+	}
+	if p.TransportLevelMarking != nil {
+		ies = append(ies, ie.NewTransportLevelMarking(*p.TransportLevelMarking))
+	}
+	if p.ForwardingPolicy != nil {
+		ies = append(ies, ie.NewForwardingPolicy(*p.ForwardingPolicy))
+	}
+	if p.HeaderEnrichment != nil {
+		ies = append(ies, p.HeaderEnrichment)
+	}
+	return append(ies, p.IEs...)
+}
+
+// DuplicatingParameters is a Duplicating Parameters in FAR.
+type DuplicatingParameters struct {
+	DestinationInterface  *uint8
+	OuterHeaderCreation   *ie.OuterHeaderCreationFields
+	TransportLevelMarking *uint16
+	ForwardingPolicy      *string
+	// IEs are the other child IEs.
+	IEs []*ie.IE
+}
+
+// ParseDuplicatingParameters parses DuplicatingParameters IE into
+// DuplicatingParameters.
+func ParseDuplicatingParameters(i *ie.IE) (*DuplicatingParameters, error) {
+	p := &DuplicatingParameters{}
+	if err := p.FromIE(i); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// FromIE parses DuplicatingParameters IE into p.
+func (p *DuplicatingParameters) FromIE(i *ie.IE) error {
+	return p.parse(i, ie.DuplicatingParameters)
+}
+
+// parse parses the IE of type typ into p.
+func (p *DuplicatingParameters) parse(i *ie.IE, typ uint16) error {
+	if i.Type != typ {
+		return &ie.InvalidTypeError{Type: i.Type}
+	}
+
+	ies, err := children(i)
+	if err != nil {
+		return err
+	}
+
+	*p = DuplicatingParameters{}
+	for _, c := range ies {
+		switch c.Type {
+		case ie.DestinationInterface:
+			v, err := c.DestinationInterface()
+			if err != nil {
+				return err
+			}
+			p.DestinationInterface = &v
+		case ie.OuterHeaderCreation:
+			v, err := c.OuterHeaderCreation()
+			if err != nil {
+				return err
+			}
+			p.OuterHeaderCreation = v
+		case ie.TransportLevelMarking:
+			v, err := c.TransportLevelMarking()
+			if err != nil {
+				return err
+			}
+			p.TransportLevelMarking = &v
+		case ie.ForwardingPolicy:
+			v, err := c.ForwardingPolicyIdentifier()
+			if err != nil {
+				return err
+			}
+			p.ForwardingPolicy = &v
+		default:
+			p.IEs = append(p.IEs, c)
+		}
+	}
+	return nil
+}
+
+// ToIE creates DuplicatingParameters IE from p.
+func (p *DuplicatingParameters) ToIE() *ie.IE {
+	return ie.NewDuplicatingParameters(p.childIEs()...)
+}
+
+func (p *DuplicatingParameters) childIEs() []*ie.IE {
+	var ies []*ie.IE
+	if p.DestinationInterface != nil {
+		ies = append(ies, ie.NewDestinationInterface(*p.DestinationInterface))
+	}
+	if p.OuterHeaderCreation != nil {
+		ies = append(ies, newFieldsIE(ie.OuterHeaderCreation, p.OuterHeaderCreation))
+	}
+	if p.TransportLevelMarking != nil {
+		ies = append(ies, ie.NewTransportLevelMarking(*p.TransportLevelMarking))
+	}
+	if p.ForwardingPolicy != nil {
+		ies = append(ies, ie.NewForwardingPolicy(*p.ForwardingPolicy))
+	}
+	return append(ies, p.IEs...)
+}
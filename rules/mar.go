@@ -0,0 +1,108 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package rules
+
+import (
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+// MAR is a Multi-Access Rule.
+type MAR struct {
+	MARID                                    uint16
+	SteeringFunctionality                    *uint8
+	SteeringMode                             *uint8
+	TGPPAccessForwardingActionInformation    *ie.IE
+	NonTGPPAccessForwardingActionInformation *ie.IE
+	// IEs are the other child IEs, e.g., UpdateTGPPAccessForwardingActionInformation
+	// in UpdateMAR.
+	IEs []*ie.IE
+}
+
+// ParseMAR parses CreateMAR IE into MAR.
+func ParseMAR(i *ie.IE) (*MAR, error) {
+	m := &MAR{}
+	if err := m.FromIE(i); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// FromIE parses CreateMAR IE into m.
+func (m *MAR) FromIE(i *ie.IE) error {
+	return m.parse(i, ie.CreateMAR)
+}
+
+// parseUpdateMAR parses UpdateMAR IE into MAR, which has only the fields to be
+// changed.
+func parseUpdateMAR(i *ie.IE) (*MAR, error) {
+	m := &MAR{}
+	if err := m.parse(i, ie.UpdateMAR); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// parse parses the IE of type typ into m.
+func (m *MAR) parse(i *ie.IE, typ uint16) error {
+	if i.Type != typ {
+		return &ie.InvalidTypeError{Type: i.Type}
+	}
+
+	ies, err := children(i)
+	if err != nil {
+		return err
+	}
+	if !hasChild(ies, ie.MARID) {
+		return ie.ErrIENotFound
+	}
+
+	*m = MAR{}
+	for _, c := range ies {
+		switch c.Type {
+		case ie.MARID:
+			v, err := c.MARID()
+			if err != nil {
+				return err
+			}
+			m.MARID = v
+		case ie.SteeringFunctionality:
+			v, err := c.SteeringFunctionality()
+			if err != nil {
+				return err
+			}
+			m.SteeringFunctionality = &v
+		case ie.SteeringMode:
+			v, err := c.SteeringMode()
+			if err != nil {
+				return err
+			}
+			m.SteeringMode = &v
+		case ie.TGPPAccessForwardingActionInformation:
+			m.TGPPAccessForwardingActionInformation = c
+		case ie.NonTGPPAccessForwardingActionInformation:
+			m.NonTGPPAccessForwardingActionInformation = c
+		default:
+			m.IEs = append(m.IEs, c)
+		}
+	}
+	return nil
+}
+
+// ToIE creates CreateMAR IE from m.
+func (m *MAR) ToIE() *ie.IE {
+	return ie.NewCreateMAR(m.childIEs()...)
+}
+
+func (m *MAR) childIEs() []*ie.IE {
+	ies := []*ie.IE{ie.NewMARID(m.MARID)}
+	if m.SteeringFunctionality != nil {
+		ies = append(ies, ie.NewSteeringFunctionality(*m.SteeringFunctionality))
+	}
+	if m.SteeringMode != nil {
+		ies = append(ies, ie.NewSteeringMode(*m.SteeringMode))
+	}
+	ies = appendIfNotNil(ies, m.TGPPAccessForwardingActionInformation, m.NonTGPPAccessForwardingActionInformation)
+	return append(ies, m.IEs...)
+}
@@ -0,0 +1,288 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package rules
+
+import (
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+// PDR is a Packet Detection Rule.
+type PDR struct {
+	PDRID                   uint16
+	Precedence              *uint32
+	PDI                     *PDI
+	OuterHeaderRemoval      *ie.IE
+	FARID                   *uint32
+	URRIDs                  []uint32
+	QERIDs                  []uint32
+	ActivatePredefinedRules []string
+	MARID                   *uint16
+	// IEs are the other child IEs, e.g., ActivationTime.
+	IEs []*ie.IE
+}
+
+// ParsePDR parses CreatePDR IE into PDR.
+func ParsePDR(i *ie.IE) (*PDR, error) {
+	p := &PDR{}
+	if err := p.FromIE(i); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// FromIE parses CreatePDR IE into p.
+func (p *PDR) FromIE(i *ie.IE) error {
+	return p.parse(i, ie.CreatePDR)
+}
+
+// parseUpdatePDR parses UpdatePDR IE into PDR, which has only the fields to be
+// changed.
+func parseUpdatePDR(i *ie.IE) (*PDR, error) {
+	p := &PDR{}
+	if err := p.parse(i, ie.UpdatePDR); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// parse parses the IE of type typ into p.
+func (p *PDR) parse(i *ie.IE, typ uint16) error {
+	if i.Type != typ {
+		return &ie.InvalidTypeError{Type: i.Type}
+	}
+
+	ies, err := children(i)
+	if err != nil {
+		return err
+	}
+	if !hasChild(ies, ie.PDRID) {
+		return ie.ErrIENotFound
+	}
+
+	*p = PDR{}
+	for _, c := range ies {
+		switch c.Type {
+		case ie.PDRID:
+			v, err := c.PDRID()
+			if err != nil {
+				return err
+			}
+			p.PDRID = v
+		case ie.Precedence:
+			v, err := c.Precedence()
+			if err != nil {
+				return err
+			}
+			p.Precedence = &v
+		case ie.PDI:
+			v, err := ParsePDI(c)
+			if err != nil {
+				return err
+			}
+			p.PDI = v
+		case ie.OuterHeaderRemoval:
+			p.OuterHeaderRemoval = c
+		case ie.FARID:
+			v, err := c.FARID()
+			if err != nil {
+				return err
+			}
+			p.FARID = &v
+		case ie.URRID:
+			v, err := c.URRID()
+			if err != nil {
+				return err
+			}
+			p.URRIDs = append(p.URRIDs, v)
+		case ie.QERID:
+			v, err := c.QERID()
+			if err != nil {
+				return err
+			}
+			p.QERIDs = append(p.QERIDs, v)
+		case ie.ActivatePredefinedRules:
+			v, err := c.ActivatePredefinedRules()
+			if err != nil {
+				return err
+			}
+			p.ActivatePredefinedRules = append(p.ActivatePredefinedRules, v)
+		case ie.MARID:
+			v, err := c.MARID()
+			if err != nil {
+				return err
+			}
+			p.MARID = &v
+		default:
+			p.IEs = append(p.IEs, c)
+		}
+	}
+	return nil
+}
+
+// ToIE creates CreatePDR IE from p.
+func (p *PDR) ToIE() *ie.IE {
+	return ie.NewCreatePDR(p.childIEs()...)
+}
+
+func (p *PDR) childIEs() []*ie.IE {
+	ies := []*ie.IE{ie.NewPDRID(p.PDRID)}
+	if p.Precedence != nil {
+		ies = append(ies, ie.NewPrecedence(*p.Precedence))
+	}
+	if p.PDI != nil {
+		ies = append(ies, p.PDI.ToIE())
+	}
+	if p.OuterHeaderRemoval != nil {
+		ies = append(ies, p.OuterHeaderRemoval)
+	}
+	if p.FARID != nil {
+		ies = append(ies, ie.NewFARID(*p.FARID))
+	}
+	for _, id := range p.URRIDs {
+		ies = append(ies, ie.NewURRID(id))
+	}
+	for _, id := range p.QERIDs {
+		ies = append(ies, ie.NewQERID(id))
+	}
+	for _, name := range p.ActivatePredefinedRules {
+		ies = append(ies, ie.NewActivatePredefinedRules(name))
+	}
+	if p.MARID != nil {
+		ies = append(ies, ie.NewMARID(*p.MARID))
+	}
+	return append(ies, p.IEs...)
+}
+
+// PDI is a Packet Detection Information in PDR.
+type PDI struct {
+	SourceInterface    *uint8
+	LocalFTEID         *ie.FTEIDFields
+	NetworkInstance    *string
+	UEIPAddresses      []*ie.UEIPAddressFields
+	TrafficEndpointIDs []uint8
+	SDFFilters         []*ie.SDFFilterFields
+	ApplicationID      *string
+	QFIs               []uint8
+	FramedRoutes       []string
+	// IEs are the other child IEs, e.g., EthernetPacketFilter.
+	IEs []*ie.IE
+}
+
+// ParsePDI parses PDI IE into PDI.
+func ParsePDI(i *ie.IE) (*PDI, error) {
+	p := &PDI{}
+	if err := p.FromIE(i); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// FromIE parses PDI IE into p.
+func (p *PDI) FromIE(i *ie.IE) error {
+	if i.Type != ie.PDI {
+		return &ie.InvalidTypeError{Type: i.Type}
+	}
+
+	ies, err := children(i)
+	if err != nil {
+		return err
+	}
+
+	*p = PDI{}
+	for _, c := range ies {
+		switch c.Type {
+		case ie.SourceInterface:
+			v, err := c.SourceInterface()
+			if err != nil {
+				return err
+			}
+			p.SourceInterface = &v
+		case ie.FTEID:
+			v, err := c.FTEID()
+			if err != nil {
+				return err
+			}
+			p.LocalFTEID = v
+		case ie.NetworkInstance:
+			v, err := c.NetworkInstance()
+			if err != nil {
+				return err
+			}
+			p.NetworkInstance = &v
+		case ie.UEIPAddress:
+			v, err := c.UEIPAddress()
+			if err != nil {
+				return err
+			}
+			p.UEIPAddresses = append(p.UEIPAddresses, v)
+		case ie.TrafficEndpointID:
+			v, err := c.TrafficEndpointID()
+			if err != nil {
+				return err
+			}
+			p.TrafficEndpointIDs = append(p.TrafficEndpointIDs, v)
+		case ie.SDFFilter:
+			v, err := c.SDFFilter()
+			if err != nil {
+				return err
+			}
+			p.SDFFilters = append(p.SDFFilters, v)
+		case ie.ApplicationID:
+			v, err := c.ApplicationID()
+			if err != nil {
+				return err
+			}
+			p.ApplicationID = &v
+		case ie.QFI:
+			v, err := c.QFI()
+			if err != nil {
+				return err
+			}
+			p.QFIs = append(p.QFIs, v)
+		case ie.FramedRoute:
+			v, err := c.FramedRoute()
+			if err != nil {
+				return err
+			}
+			p.FramedRoutes = append(p.FramedRoutes, v)
+		default:
+			p.IEs = append(p.IEs, c)
+		}
+	}
+	return nil
+}
+
+// ToIE creates PDI IE from p.
+func (p *PDI) ToIE() *ie.IE {
+	var ies []*ie.IE
+	if p.SourceInterface != nil {
+		ies = append(ies, ie.NewSourceInterface(*p.SourceInterface))
+	}
+	if p.LocalFTEID != nil {
+		ies = append(ies, newFieldsIE(ie.FTEID, p.LocalFTEID))
+	}
+	if p.NetworkInstance != nil {
+		ies = append(ies, ie.NewNetworkInstance(*p.NetworkInstance))
+	}
+	for _, f := range p.UEIPAddresses {
+		ies = append(ies, newFieldsIE(ie.UEIPAddress, f))
+	}
+	for _, id := range p.TrafficEndpointIDs {
+		ies = append(ies, ie.NewTrafficEndpointID(id))
+	}
+	for _, f := range p.SDFFilters {
+		ies = append(ies, newFieldsIE(ie.SDFFilter, f))
+	}
+	if p.ApplicationID != nil {
+		ies = append(ies, ie.NewApplicationID(*p.ApplicationID))
+	}
+	for _, qfi := range p.QFIs {
+		ies = append(ies, ie.NewQFI(qfi))
+	}
+	for _, r := range p.FramedRoutes {
+		ies = append(ies, ie.NewFramedRoute(r))
+	}
+	return ie.NewPDI(append(ies, p.IEs...)...)
+}
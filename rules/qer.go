@@ -0,0 +1,114 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package rules
+
+import (
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+// QER is a QoS Enforcement Rule.
+type QER struct {
+	QERID            uint32
+	QERCorrelationID *uint32
+	GateStatus       *ie.IE
+	MBR              *ie.IE
+	GBR              *ie.IE
+	QFI              *uint8
+	RQI              *ie.IE
+	// IEs are the other child IEs, e.g., PacketRate.
+	IEs []*ie.IE
+}
+
+// ParseQER parses CreateQER IE into QER.
+func ParseQER(i *ie.IE) (*QER, error) {
+	q := &QER{}
+	if err := q.FromIE(i); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// FromIE parses CreateQER IE into q.
+func (q *QER) FromIE(i *ie.IE) error {
+	return q.parse(i, ie.CreateQER)
+}
+
+// parseUpdateQER parses UpdateQER IE into QER, which has only the fields to be
+// changed.
+func parseUpdateQER(i *ie.IE) (*QER, error) {
+	q := &QER{}
+	if err := q.parse(i, ie.UpdateQER); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// parse parses the IE of type typ into q.
+func (q *QER) parse(i *ie.IE, typ uint16) error {
+	if i.Type != typ {
+		return &ie.InvalidTypeError{Type: i.Type}
+	}
+
+	ies, err := children(i)
+	if err != nil {
+		return err
+	}
+	if !hasChild(ies, ie.QERID) {
+		return ie.ErrIENotFound
+	}
+
+	*q = QER{}
+	for _, c := range ies {
+		switch c.Type {
+		case ie.QERID:
+			v, err := c.QERID()
+			if err != nil {
+				return err
+			}
+			q.QERID = v
+		case ie.QERCorrelationID:
+			v, err := c.QERCorrelationID()
+			if err != nil {
+				return err
+			}
+			q.QERCorrelationID = &v
+		case ie.GateStatus:
+			q.GateStatus = c
+		case ie.MBR:
+			q.MBR = c
+		case ie.GBR:
+			q.GBR = c
+		case ie.QFI:
+			v, err := c.QFI()
+			if err != nil {
+				return err
+			}
+			q.QFI = &v
+		case ie.RQI:
+			q.RQI = c
+		default:
+			q.IEs = append(q.IEs, c)
+		}
+	}
+	return nil
+}
+
+// ToIE creates CreateQER IE from q.
+func (q *QER) ToIE() *ie.IE {
+	return ie.NewCreateQER(q.childIEs()...)
+}
+
+func (q *QER) childIEs() []*ie.IE {
+	ies := []*ie.IE{ie.NewQERID(q.QERID)}
+	if q.QERCorrelationID != nil {
+		ies = append(ies, ie.NewQERCorrelationID(*q.QERCorrelationID))
+	}
+	ies = appendIfNotNil(ies, q.GateStatus, q.MBR, q.GBR)
+	if q.QFI != nil {
+		ies = append(ies, ie.NewQFI(*q.QFI))
+	}
+	ies = appendIfNotNil(ies, q.RQI)
+	return append(ies, q.IEs...)
+}
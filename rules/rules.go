@@ -0,0 +1,75 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+// Package rules provides the rules in PFCP sessions(PDR, FAR, QER, URR, BAR,
+// MAR, SRR and Traffic Endpoint) as plain Go structures, and the conversion
+// between them and the grouped IEs defined in package ie.
+//
+// FromIE parses the whole grouped IE at once, and ToIE creates the grouped
+// IE from the structure. The child IEs that have no dedicated field in the
+// structure are kept as they are in IEs, so that no information is lost in
+// the conversion. The order of the child IEs may be different from the
+// original one, as ToIE puts them in the order of the fields.
+//
+// FromIE accepts only the IEs that ToIE creates, e.g., CreatePDR, so that the
+// structure is converted back to the same IE. UpdateXXX IEs are applied to
+// the rules with Set.ApplyModification instead. The rule ID, e.g., PDR ID, is
+// mandatory, and FromIE returns ie.ErrIENotFound without it.
+//
+// The optional child IEs are represented as pointers or slices, and they are
+// nil if the IE is not present.
+//
+// The fields of type *ie.IE, e.g., FAR.ApplyAction, QER.MBR and the Forwarding
+// Action Information of MAR, are the IEs that this package does not look
+// into. They are passed through untouched, and an UpdateXXX IE replaces the
+// whole IE. Use the methods of ie.IE to get their values.
+package rules
+
+import (
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/internal/logger"
+)
+
+// children returns the child IEs of a grouped IE.
+func children(i *ie.IE) ([]*ie.IE, error) {
+	if len(i.ChildIEs) > 0 {
+		return i.ChildIEs, nil
+	}
+	return ie.ParseMultiIEs(i.Payload)
+}
+
+// hasChild reports whether ies has the IE of type t.
+func hasChild(ies []*ie.IE, t uint16) bool {
+	for _, i := range ies {
+		if i.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+// marshaler is the structured value of the IEs, e.g., *ie.FTEIDFields.
+type marshaler interface {
+	Marshal() ([]byte, error)
+}
+
+// newFieldsIE creates a new IE of type t with the serialized f as a payload.
+func newFieldsIE(t uint16, f marshaler) *ie.IE {
+	b, err := f.Marshal()
+	if err != nil {
+		logger.Log(logger.LevelWarn, "failed to marshal IE", "type", t, "error", err)
+		return nil
+	}
+	return ie.New(t, b)
+}
+
+// appendIfNotNil appends the IEs that are not nil to ies.
+func appendIfNotNil(ies []*ie.IE, is ...*ie.IE) []*ie.IE {
+	for _, i := range is {
+		if i != nil {
+			ies = append(ies, i)
+		}
+	}
+	return ies
+}
@@ -0,0 +1,265 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package rules_test
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/rules"
+)
+
+// rule is the common interface of the rules.
+type rule interface {
+	FromIE(i *ie.IE) error
+	ToIE() *ie.IE
+}
+
+func TestRules(t *testing.T) {
+	cases := []struct {
+		description string
+		rule        rule
+		structured  *ie.IE
+	}{
+		{
+			"PDR",
+			&rules.PDR{},
+			ie.NewCreatePDR(
+				ie.NewPDRID(0xffff),
+				ie.NewPrecedence(0x11111111),
+				ie.NewPDI(
+					ie.NewSourceInterface(ie.SrcInterfaceAccess),
+					ie.NewFTEID(0x11111111, net.ParseIP("127.0.0.1"), nil, nil),
+					ie.NewNetworkInstance("some.instance.example"),
+					ie.NewUEIPAddress(0x02, "127.0.0.1", "", 0),
+					ie.NewTrafficEndpointID(0x01),
+					ie.NewSDFFilter("aaaaaaaa", "bb", "cccc", "ddd", 0xffffffff),
+					ie.NewApplicationID("https://github.com/wmnsk/go-pfcp/"),
+					ie.NewQFI(0x01),
+					ie.NewFramedRoute("go-pfcp"),
+					ie.NewEthernetPDUSessionInformation(0x01),
+				),
+				ie.NewOuterHeaderRemoval(0x01, 0x02),
+				ie.NewFARID(0xffffffff),
+				ie.NewURRID(0xffffffff),
+				ie.NewURRID(0xeeeeeeee),
+				ie.NewQERID(0xffffffff),
+				ie.NewActivatePredefinedRules("go-pfcp"),
+				ie.NewMARID(0x1111),
+				ie.NewActivationTime(time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)),
+			),
+		}, {
+			"FAR",
+			&rules.FAR{},
+			ie.NewCreateFAR(
+				ie.NewFARID(0xffffffff),
+				ie.NewApplyAction(0x02),
+				ie.NewForwardingParameters(
+					ie.NewDestinationInterface(ie.DstInterfaceAccess),
+					ie.NewNetworkInstance("some.instance.example"),
+					ie.NewRedirectInformation(ie.RedirectAddrIPv4AndIPv6, "127.0.0.1", "2001::1"),
+					ie.NewOuterHeaderCreation(0x0100, 0x11111111, "127.0.0.1", "", 0, 0, 0),
+					ie.NewTransportLevelMarking(0x1111),
+					ie.NewForwardingPolicy("go-pfcp"),
+					ie.NewHeaderEnrichment(ie.HeaderTypeHTTP, "name", "value"),
+					ie.NewProxying(1, 1),
+				),
+				ie.NewDuplicatingParameters(
+					ie.NewDestinationInterface(ie.DstInterfaceAccess),
+					ie.NewOuterHeaderCreation(0x0100, 0x11111111, "127.0.0.1", "", 0, 0, 0),
+					ie.NewTransportLevelMarking(0x1111),
+					ie.NewForwardingPolicy("go-pfcp"),
+				),
+				ie.NewBARID(0xff),
+			),
+		}, {
+			"QER",
+			&rules.QER{},
+			ie.NewCreateQER(
+				ie.NewQERID(0xffffffff),
+				ie.NewQERCorrelationID(0x11111111),
+				ie.NewGateStatus(ie.GateStatusOpen, ie.GateStatusOpen),
+				ie.NewMBR(0x11111111, 0x22222222),
+				ie.NewGBR(0x11111111, 0x22222222),
+				ie.NewQFI(0x01),
+				ie.NewRQI(0x01),
+				ie.NewPacketRate(0x03, ie.TimeUnitMinute, 0x1111, ie.TimeUnitMinute, 0x2222),
+			),
+		}, {
+			"URR",
+			&rules.URR{},
+			ie.NewCreateURR(
+				ie.NewURRID(0xffffffff),
+				ie.NewMeasurementMethod(1, 1, 1),
+				ie.NewReportingTriggers(0xffff),
+				ie.NewMeasurementPeriod(10*time.Second),
+				ie.NewVolumeThreshold(0x07, 0x3333333333333333, 0x1111111111111111, 0x2222222222222222),
+				ie.NewVolumeQuota(0x07, 0x3333333333333333, 0x1111111111111111, 0x2222222222222222),
+				ie.NewTimeThreshold(0x11111111),
+				ie.NewTimeQuota(10*time.Second),
+				ie.NewLinkedURRID(0xffffffff),
+				ie.NewQuotaHoldingTime(10*time.Second),
+			),
+		}, {
+			"BAR",
+			&rules.BAR{},
+			ie.NewCreateBAR(
+				ie.NewBARID(0xff),
+				ie.NewDownlinkDataNotificationDelay(100*time.Millisecond),
+				ie.NewSuggestedBufferingPacketsCount(0x01),
+				ie.NewMTEDTControlInformation(1),
+			),
+		}, {
+			"MAR",
+			&rules.MAR{},
+			ie.NewCreateMAR(
+				ie.NewMARID(0x1111),
+				ie.NewSteeringFunctionality(0x01),
+				ie.NewSteeringMode(0x01),
+				ie.NewTGPPAccessForwardingActionInformation(
+					ie.NewFARID(0xffffffff),
+					ie.NewWeight(0x01),
+				),
+				ie.NewNonTGPPAccessForwardingActionInformation(
+					ie.NewFARID(0xffffffff),
+					ie.NewWeight(0x01),
+				),
+			),
+		}, {
+			"SRR",
+			&rules.SRR{},
+			ie.NewCreateSRR(
+				ie.NewSRRID(0xff),
+				ie.NewAccessAvailabilityControlInformation(
+					ie.NewRequestedAccessAvailabilityInformation(1),
+				),
+			),
+		}, {
+			"TrafficEndpoint",
+			&rules.TrafficEndpoint{},
+			ie.NewCreateTrafficEndpoint(
+				ie.NewTrafficEndpointID(0x01),
+				ie.NewFTEID(0x11111111, net.ParseIP("127.0.0.1"), nil, nil),
+				ie.NewNetworkInstance("some.instance.example"),
+				ie.NewUEIPAddress(0x02, "127.0.0.1", "", 0),
+				ie.NewFramedRoute("go-pfcp"),
+				ie.NewQFI(0x01),
+				ie.NewEthernetPDUSessionInformation(0x01),
+			),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			want, err := c.structured.Marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// parse the serialized one, which has no ChildIEs set.
+			parsed, err := ie.Parse(want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.rule.FromIE(parsed); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := c.rule.ToIE().Marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestParsePDR(t *testing.T) {
+	p, err := rules.ParsePDR(ie.NewCreatePDR(
+		ie.NewPDRID(1),
+		ie.NewPrecedence(100),
+		ie.NewPDI(
+			ie.NewSourceInterface(ie.SrcInterfaceCore),
+			ie.NewSDFFilter("permit out ip from any to assigned", "", "", "", 0),
+		),
+		ie.NewFARID(2),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.PDRID != 1 || p.Precedence == nil || *p.Precedence != 100 || p.FARID == nil || *p.FARID != 2 {
+		t.Errorf("got unexpected PDR: %+v", p)
+	}
+	if p.PDI == nil || p.PDI.SourceInterface == nil || *p.PDI.SourceInterface != ie.SrcInterfaceCore {
+		t.Fatalf("got unexpected PDI: %+v", p.PDI)
+	}
+	if len(p.PDI.SDFFilters) != 1 || p.PDI.SDFFilters[0].FlowDescription != "permit out ip from any to assigned" {
+		t.Errorf("got unexpected SDFFilters: %+v", p.PDI.SDFFilters)
+	}
+	if p.QERIDs != nil || p.MARID != nil || p.IEs != nil {
+		t.Errorf("got absent IEs: %+v", p)
+	}
+
+	if _, err := rules.ParsePDR(ie.NewCreateFAR(ie.NewFARID(1))); err == nil {
+		t.Error("parsed CreateFAR as PDR")
+	}
+}
+
+func TestFromIERejectsUpdate(t *testing.T) {
+	for _, c := range []struct {
+		description string
+		rule        rule
+		update      *ie.IE
+	}{
+		{"PDR", &rules.PDR{}, ie.NewUpdatePDR(ie.NewPDRID(1))},
+		{"FAR", &rules.FAR{}, ie.NewUpdateFAR(ie.NewFARID(1))},
+		{"QER", &rules.QER{}, ie.NewUpdateQER(ie.NewQERID(1))},
+		{"URR", &rules.URR{}, ie.NewUpdateURR(ie.NewURRID(1))},
+		{"BAR", &rules.BAR{}, ie.NewUpdateBARWithinSessionModificationRequest(ie.NewBARID(1))},
+		{"MAR", &rules.MAR{}, ie.NewUpdateMAR(ie.NewMARID(1))},
+		{"SRR", &rules.SRR{}, ie.NewUpdateSRR(ie.NewSRRID(1))},
+		{"TrafficEndpoint", &rules.TrafficEndpoint{}, ie.NewUpdateTrafficEndpoint(ie.NewTrafficEndpointID(1))},
+		{"ForwardingParameters", &rules.ForwardingParameters{}, ie.NewUpdateForwardingParameters()},
+		{"DuplicatingParameters", &rules.DuplicatingParameters{}, ie.NewUpdateDuplicatingParameters()},
+	} {
+		t.Run(c.description, func(t *testing.T) {
+			// ToIE cannot create the update IE again.
+			var ierr *ie.InvalidTypeError
+			if err := c.rule.FromIE(c.update); !errors.As(err, &ierr) {
+				t.Errorf("got %v, want InvalidTypeError", err)
+			}
+		})
+	}
+}
+
+func TestFromIENoRuleID(t *testing.T) {
+	for _, c := range []struct {
+		description string
+		rule        rule
+		create      *ie.IE
+	}{
+		{"PDR", &rules.PDR{}, ie.NewCreatePDR(ie.NewPrecedence(100), ie.NewFARID(1))},
+		{"FAR", &rules.FAR{}, ie.NewCreateFAR(ie.NewApplyAction(0x02))},
+		{"QER", &rules.QER{}, ie.NewCreateQER(ie.NewGateStatus(ie.GateStatusOpen, ie.GateStatusOpen))},
+		{"URR", &rules.URR{}, ie.NewCreateURR(ie.NewMeasurementMethod(0, 1, 0))},
+		{"BAR", &rules.BAR{}, ie.NewCreateBAR(ie.NewSuggestedBufferingPacketsCount(10))},
+		{"MAR", &rules.MAR{}, ie.NewCreateMAR(ie.NewSteeringFunctionality(0x01))},
+		{"SRR", &rules.SRR{}, ie.NewCreateSRR()},
+		{"TrafficEndpoint", &rules.TrafficEndpoint{}, ie.NewCreateTrafficEndpoint(ie.NewNetworkInstance("some.instance.example"))},
+	} {
+		t.Run(c.description, func(t *testing.T) {
+			if err := c.rule.FromIE(c.create); !errors.Is(err, ie.ErrIENotFound) {
+				t.Errorf("got %v, want %v", err, ie.ErrIENotFound)
+			}
+		})
+	}
+}
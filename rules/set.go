@@ -0,0 +1,409 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package rules
+
+import (
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+// Set is the set of rules in a PFCP session, keyed by the rule IDs.
+//
+// The rules in Set should not be modified in place, as the ones in the copy
+// made by Clone share them. Replace them with the new ones instead.
+type Set struct {
+	PDRs             map[uint16]*PDR
+	FARs             map[uint32]*FAR
+	QERs             map[uint32]*QER
+	URRs             map[uint32]*URR
+	BAR              *BAR
+	MARs             map[uint16]*MAR
+	SRRs             map[uint8]*SRR
+	TrafficEndpoints map[uint8]*TrafficEndpoint
+}
+
+// NewSet creates an empty Set.
+func NewSet() *Set {
+	return &Set{
+		PDRs:             make(map[uint16]*PDR),
+		FARs:             make(map[uint32]*FAR),
+		QERs:             make(map[uint32]*QER),
+		URRs:             make(map[uint32]*URR),
+		MARs:             make(map[uint16]*MAR),
+		SRRs:             make(map[uint8]*SRR),
+		TrafficEndpoints: make(map[uint8]*TrafficEndpoint),
+	}
+}
+
+// Clone returns a copy of s. The rules are shared between s and the copy.
+func (s *Set) Clone() *Set {
+	c := NewSet()
+	for k, v := range s.PDRs {
+		c.PDRs[k] = v
+	}
+	for k, v := range s.FARs {
+		c.FARs[k] = v
+	}
+	for k, v := range s.QERs {
+		c.QERs[k] = v
+	}
+	for k, v := range s.URRs {
+		c.URRs[k] = v
+	}
+	c.BAR = s.BAR
+	for k, v := range s.MARs {
+		c.MARs[k] = v
+	}
+	for k, v := range s.SRRs {
+		c.SRRs[k] = v
+	}
+	for k, v := range s.TrafficEndpoints {
+		c.TrafficEndpoints[k] = v
+	}
+	return c
+}
+
+// mergeIEs returns old with the IEs replaced by the ones of the same type in
+// upd, keeping the order in old. The IEs of the types in ignore are not taken
+// from upd.
+func mergeIEs(old, upd []*ie.IE, ignore ...uint16) []*ie.IE {
+	if len(upd) == 0 {
+		return old
+	}
+
+	replaced := make(map[uint16][]*ie.IE)
+	for _, i := range upd {
+		if containsType(ignore, i.Type) {
+			continue
+		}
+		replaced[i.Type] = append(replaced[i.Type], i)
+	}
+
+	var ies []*ie.IE
+	done := make(map[uint16]bool)
+	for _, i := range old {
+		v, ok := replaced[i.Type]
+		if !ok {
+			ies = append(ies, i)
+			continue
+		}
+		if !done[i.Type] {
+			ies = append(ies, v...)
+			done[i.Type] = true
+		}
+	}
+	for _, i := range upd {
+		if _, ok := replaced[i.Type]; ok && !done[i.Type] {
+			ies = append(ies, i)
+		}
+	}
+	return ies
+}
+
+func containsType(types []uint16, t uint16) bool {
+	for _, typ := range types {
+		if typ == t {
+			return true
+		}
+	}
+	return false
+}
+
+// merge returns a new PDR updated with the fields present in upd.
+func (p *PDR) merge(upd *PDR) *PDR {
+	n := *p
+	if upd.Precedence != nil {
+		n.Precedence = upd.Precedence
+	}
+	if upd.PDI != nil {
+		n.PDI = upd.PDI
+	}
+	if upd.OuterHeaderRemoval != nil {
+		n.OuterHeaderRemoval = upd.OuterHeaderRemoval
+	}
+	if upd.FARID != nil {
+		n.FARID = upd.FARID
+	}
+	if upd.URRIDs != nil {
+		n.URRIDs = upd.URRIDs
+	}
+	if upd.QERIDs != nil {
+		n.QERIDs = upd.QERIDs
+	}
+	n.ActivatePredefinedRules = mergePredefinedRules(p.ActivatePredefinedRules, upd)
+	n.IEs = mergeIEs(p.IEs, upd.IEs, ie.DeactivatePredefinedRules)
+	return &n
+}
+
+// mergePredefinedRules returns active with the ones activated by upd added,
+// and the ones deactivated by DeactivatePredefinedRules in upd removed.
+func mergePredefinedRules(active []string, upd *PDR) []string {
+	deactivated := make(map[string]bool)
+	for _, i := range upd.IEs {
+		if i.Type != ie.DeactivatePredefinedRules {
+			continue
+		}
+		if v, err := i.DeactivatePredefinedRules(); err == nil {
+			deactivated[v] = true
+		}
+	}
+	if len(deactivated) == 0 && upd.ActivatePredefinedRules == nil {
+		return active
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range append(append([]string{}, active...), upd.ActivatePredefinedRules...) {
+		if deactivated[name] || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// merge returns a new FAR updated with the fields present in upd.
+func (f *FAR) merge(upd *FAR) *FAR {
+	n := *f
+	if upd.ApplyAction != nil {
+		n.ApplyAction = upd.ApplyAction
+	}
+	if upd.ForwardingParameters != nil {
+		if n.ForwardingParameters == nil {
+			n.ForwardingParameters = &ForwardingParameters{}
+		}
+		n.ForwardingParameters = n.ForwardingParameters.merge(upd.ForwardingParameters)
+	}
+	if upd.DuplicatingParameters != nil {
+		n.DuplicatingParameters = mergeDuplicatingParameters(f.DuplicatingParameters, upd.DuplicatingParameters)
+	}
+	if upd.BARID != nil {
+		n.BARID = upd.BARID
+	}
+	n.IEs = mergeIEs(f.IEs, upd.IEs)
+	return &n
+}
+
+// mergeDuplicatingParameters returns the DuplicatingParameters updated with
+// the ones in upd, where the n-th one in upd updates the n-th one in old.
+func mergeDuplicatingParameters(old, upd []*DuplicatingParameters) []*DuplicatingParameters {
+	ps := make([]*DuplicatingParameters, 0, len(old))
+	for i, p := range old {
+		if i < len(upd) {
+			p = p.merge(upd[i])
+		}
+		ps = append(ps, p)
+	}
+	if len(upd) > len(old) {
+		ps = append(ps, upd[len(old):]...)
+	}
+	return ps
+}
+
+// merge returns a new DuplicatingParameters updated with the fields present in upd.
+func (p *DuplicatingParameters) merge(upd *DuplicatingParameters) *DuplicatingParameters {
+	n := *p
+	if upd.DestinationInterface != nil {
+		n.DestinationInterface = upd.DestinationInterface
+	}
+	if upd.OuterHeaderCreation != nil {
+		n.OuterHeaderCreation = upd.OuterHeaderCreation
+	}
+	if upd.TransportLevelMarking != nil {
+		n.TransportLevelMarking = upd.TransportLevelMarking
+	}
+	if upd.ForwardingPolicy != nil {
+		n.ForwardingPolicy = upd.ForwardingPolicy
+	}
+	n.IEs = mergeIEs(p.IEs, upd.IEs)
+	return &n
+}
+
+// merge returns a new ForwardingParameters updated with the fields present in upd.
+//
+// PFCPSMReqFlags in UpdateForwardingParameters is not kept, as it is not a
+// part of the rule.
+func (p *ForwardingParameters) merge(upd *ForwardingParameters) *ForwardingParameters {
+	n := *p
+	if upd.DestinationInterface != nil {
+		n.DestinationInterface = upd.DestinationInterface
+	}
+	if upd.NetworkInstance != nil {
+		n.NetworkInstance = upd.NetworkInstance
+	}
+	if upd.RedirectInformation != nil {
+		n.RedirectInformation = upd.RedirectInformation
+	}
+	if upd.OuterHeaderCreation != nil {
+		n.OuterHeaderCreation = upd.OuterHeaderCreation
+	}
+	if upd.TransportLevelMarking != nil {
+		n.TransportLevelMarking = upd.TransportLevelMarking
+	}
+	if upd.ForwardingPolicy != nil {
+		n.ForwardingPolicy = upd.ForwardingPolicy
+	}
+	if upd.HeaderEnrichment != nil {
+		n.HeaderEnrichment = upd.HeaderEnrichment
+	}
+	n.IEs = mergeIEs(p.IEs, upd.IEs, ie.PFCPSMReqFlags)
+	return &n
+}
+
+// merge returns a new QER updated with the fields present in upd.
+func (q *QER) merge(upd *QER) *QER {
+	n := *q
+	if upd.QERCorrelationID != nil {
+		n.QERCorrelationID = upd.QERCorrelationID
+	}
+	if upd.GateStatus != nil {
+		n.GateStatus = upd.GateStatus
+	}
+	if upd.MBR != nil {
+		n.MBR = upd.MBR
+	}
+	if upd.GBR != nil {
+		n.GBR = upd.GBR
+	}
+	if upd.QFI != nil {
+		n.QFI = upd.QFI
+	}
+	if upd.RQI != nil {
+		n.RQI = upd.RQI
+	}
+	n.IEs = mergeIEs(q.IEs, upd.IEs)
+	return &n
+}
+
+// merge returns a new URR updated with the fields present in upd.
+func (u *URR) merge(upd *URR) *URR {
+	n := *u
+	if upd.MeasurementMethod != nil {
+		n.MeasurementMethod = upd.MeasurementMethod
+	}
+	if upd.ReportingTriggers != nil {
+		n.ReportingTriggers = upd.ReportingTriggers
+	}
+	if upd.MeasurementPeriod != nil {
+		n.MeasurementPeriod = upd.MeasurementPeriod
+	}
+	if upd.VolumeThreshold != nil {
+		n.VolumeThreshold = upd.VolumeThreshold
+	}
+	if upd.VolumeQuota != nil {
+		n.VolumeQuota = upd.VolumeQuota
+	}
+	if upd.TimeThreshold != nil {
+		n.TimeThreshold = upd.TimeThreshold
+	}
+	if upd.TimeQuota != nil {
+		n.TimeQuota = upd.TimeQuota
+	}
+	if upd.LinkedURRIDs != nil {
+		n.LinkedURRIDs = upd.LinkedURRIDs
+	}
+	n.IEs = mergeIEs(u.IEs, upd.IEs)
+	return &n
+}
+
+// merge returns a new BAR updated with the fields present in upd.
+func (b *BAR) merge(upd *BAR) *BAR {
+	n := *b
+	if upd.DownlinkDataNotificationDelay != nil {
+		n.DownlinkDataNotificationDelay = upd.DownlinkDataNotificationDelay
+	}
+	if upd.SuggestedBufferingPacketsCount != nil {
+		n.SuggestedBufferingPacketsCount = upd.SuggestedBufferingPacketsCount
+	}
+	n.IEs = mergeIEs(b.IEs, upd.IEs)
+	return &n
+}
+
+// merge returns a new MAR updated with the fields present in upd.
+func (m *MAR) merge(upd *MAR) *MAR {
+	n := *m
+	if upd.SteeringFunctionality != nil {
+		n.SteeringFunctionality = upd.SteeringFunctionality
+	}
+	if upd.SteeringMode != nil {
+		n.SteeringMode = upd.SteeringMode
+	}
+	if upd.TGPPAccessForwardingActionInformation != nil {
+		n.TGPPAccessForwardingActionInformation = upd.TGPPAccessForwardingActionInformation
+	}
+	if upd.NonTGPPAccessForwardingActionInformation != nil {
+		n.NonTGPPAccessForwardingActionInformation = upd.NonTGPPAccessForwardingActionInformation
+	}
+	for _, i := range upd.IEs {
+		switch i.Type {
+		case ie.UpdateTGPPAccessForwardingActionInformation:
+			n.TGPPAccessForwardingActionInformation = mergeGroupedIE(
+				ie.TGPPAccessForwardingActionInformation, n.TGPPAccessForwardingActionInformation, i,
+			)
+		case ie.UpdateNonTGPPAccessForwardingActionInformation:
+			n.NonTGPPAccessForwardingActionInformation = mergeGroupedIE(
+				ie.NonTGPPAccessForwardingActionInformation, n.NonTGPPAccessForwardingActionInformation, i,
+			)
+		}
+	}
+	n.IEs = mergeIEs(
+		m.IEs, upd.IEs,
+		ie.UpdateTGPPAccessForwardingActionInformation, ie.UpdateNonTGPPAccessForwardingActionInformation,
+	)
+	return &n
+}
+
+// mergeGroupedIE returns a new grouped IE of type t which has the child IEs
+// of old replaced by the ones of the same type in upd. old can be nil.
+func mergeGroupedIE(t uint16, old, upd *ie.IE) *ie.IE {
+	var olds []*ie.IE
+	if old != nil {
+		v, err := children(old)
+		if err != nil {
+			return old
+		}
+		olds = v
+	}
+	upds, err := children(upd)
+	if err != nil {
+		return old
+	}
+	return ie.NewGroupedIE(t, mergeIEs(olds, upds)...)
+}
+
+// merge returns a new SRR updated with the fields present in upd.
+func (s *SRR) merge(upd *SRR) *SRR {
+	n := *s
+	if upd.AccessAvailabilityControlInformation != nil {
+		n.AccessAvailabilityControlInformation = upd.AccessAvailabilityControlInformation
+	}
+	if upd.QoSMonitoringPerQoSFlowControlInformation != nil {
+		n.QoSMonitoringPerQoSFlowControlInformation = upd.QoSMonitoringPerQoSFlowControlInformation
+	}
+	n.IEs = mergeIEs(s.IEs, upd.IEs)
+	return &n
+}
+
+// merge returns a new TrafficEndpoint updated with the fields present in upd.
+func (t *TrafficEndpoint) merge(upd *TrafficEndpoint) *TrafficEndpoint {
+	n := *t
+	if upd.LocalFTEID != nil {
+		n.LocalFTEID = upd.LocalFTEID
+	}
+	if upd.NetworkInstance != nil {
+		n.NetworkInstance = upd.NetworkInstance
+	}
+	if upd.UEIPAddresses != nil {
+		n.UEIPAddresses = upd.UEIPAddresses
+	}
+	if upd.FramedRoutes != nil {
+		n.FramedRoutes = upd.FramedRoutes
+	}
+	if upd.QFIs != nil {
+		n.QFIs = upd.QFIs
+	}
+	n.IEs = mergeIEs(t.IEs, upd.IEs)
+	return &n
+}
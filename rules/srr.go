@@ -0,0 +1,88 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package rules
+
+import (
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+// SRR is a Session Reporting Rule.
+type SRR struct {
+	SRRID                                     uint8
+	AccessAvailabilityControlInformation      *ie.IE
+	QoSMonitoringPerQoSFlowControlInformation []*ie.IE
+	// IEs are the other child IEs.
+	IEs []*ie.IE
+}
+
+// ParseSRR parses CreateSRR IE into SRR.
+func ParseSRR(i *ie.IE) (*SRR, error) {
+	s := &SRR{}
+	if err := s.FromIE(i); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// FromIE parses CreateSRR IE into s.
+func (s *SRR) FromIE(i *ie.IE) error {
+	return s.parse(i, ie.CreateSRR)
+}
+
+// parseUpdateSRR parses UpdateSRR IE into SRR, which has only the fields to be
+// changed.
+func parseUpdateSRR(i *ie.IE) (*SRR, error) {
+	s := &SRR{}
+	if err := s.parse(i, ie.UpdateSRR); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// parse parses the IE of type typ into s.
+func (s *SRR) parse(i *ie.IE, typ uint16) error {
+	if i.Type != typ {
+		return &ie.InvalidTypeError{Type: i.Type}
+	}
+
+	ies, err := children(i)
+	if err != nil {
+		return err
+	}
+	if !hasChild(ies, ie.SRRID) {
+		return ie.ErrIENotFound
+	}
+
+	*s = SRR{}
+	for _, c := range ies {
+		switch c.Type {
+		case ie.SRRID:
+			v, err := c.SRRID()
+			if err != nil {
+				return err
+			}
+			s.SRRID = v
+		case ie.AccessAvailabilityControlInformation:
+			s.AccessAvailabilityControlInformation = c
+		case ie.QoSMonitoringPerQoSFlowControlInformation:
+			s.QoSMonitoringPerQoSFlowControlInformation = append(s.QoSMonitoringPerQoSFlowControlInformation, c)
+		default:
+			s.IEs = append(s.IEs, c)
+		}
+	}
+	return nil
+}
+
+// ToIE creates CreateSRR IE from s.
+func (s *SRR) ToIE() *ie.IE {
+	return ie.NewCreateSRR(s.childIEs()...)
+}
+
+func (s *SRR) childIEs() []*ie.IE {
+	ies := []*ie.IE{ie.NewSRRID(s.SRRID)}
+	ies = appendIfNotNil(ies, s.AccessAvailabilityControlInformation)
+	ies = append(ies, s.QoSMonitoringPerQoSFlowControlInformation...)
+	return append(ies, s.IEs...)
+}
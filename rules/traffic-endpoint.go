@@ -0,0 +1,130 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package rules
+
+import (
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+// TrafficEndpoint is a Traffic Endpoint referred by the PDIs.
+type TrafficEndpoint struct {
+	TrafficEndpointID uint8
+	LocalFTEID        *ie.FTEIDFields
+	NetworkInstance   *string
+	UEIPAddresses     []*ie.UEIPAddressFields
+	FramedRoutes      []string
+	QFIs              []uint8
+	// IEs are the other child IEs, e.g., EthernetPDUSessionInformation.
+	IEs []*ie.IE
+}
+
+// ParseTrafficEndpoint parses CreateTrafficEndpoint IE into TrafficEndpoint.
+func ParseTrafficEndpoint(i *ie.IE) (*TrafficEndpoint, error) {
+	t := &TrafficEndpoint{}
+	if err := t.FromIE(i); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// FromIE parses CreateTrafficEndpoint IE into t.
+func (t *TrafficEndpoint) FromIE(i *ie.IE) error {
+	return t.parse(i, ie.CreateTrafficEndpoint)
+}
+
+// parseUpdateTrafficEndpoint parses UpdateTrafficEndpoint IE into
+// TrafficEndpoint, which has only the fields to be changed.
+func parseUpdateTrafficEndpoint(i *ie.IE) (*TrafficEndpoint, error) {
+	t := &TrafficEndpoint{}
+	if err := t.parse(i, ie.UpdateTrafficEndpoint); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// parse parses the IE of type typ into t.
+func (t *TrafficEndpoint) parse(i *ie.IE, typ uint16) error {
+	if i.Type != typ {
+		return &ie.InvalidTypeError{Type: i.Type}
+	}
+
+	ies, err := children(i)
+	if err != nil {
+		return err
+	}
+	if !hasChild(ies, ie.TrafficEndpointID) {
+		return ie.ErrIENotFound
+	}
+
+	*t = TrafficEndpoint{}
+	for _, c := range ies {
+		switch c.Type {
+		case ie.TrafficEndpointID:
+			v, err := c.TrafficEndpointID()
+			if err != nil {
+				return err
+			}
+			t.TrafficEndpointID = v
+		case ie.FTEID:
+			v, err := c.FTEID()
+			if err != nil {
+				return err
+			}
+			t.LocalFTEID = v
+		case ie.NetworkInstance:
+			v, err := c.NetworkInstance()
+			if err != nil {
+				return err
+			}
+			t.NetworkInstance = &v
+		case ie.UEIPAddress:
+			v, err := c.UEIPAddress()
+			if err != nil {
+				return err
+			}
+			t.UEIPAddresses = append(t.UEIPAddresses, v)
+		case ie.FramedRoute:
+			v, err := c.FramedRoute()
+			if err != nil {
+				return err
+			}
+			t.FramedRoutes = append(t.FramedRoutes, v)
+		case ie.QFI:
+			v, err := c.QFI()
+			if err != nil {
+				return err
+			}
+			t.QFIs = append(t.QFIs, v)
+		default:
+			t.IEs = append(t.IEs, c)
+		}
+	}
+	return nil
+}
+
+// ToIE creates CreateTrafficEndpoint IE from t.
+func (t *TrafficEndpoint) ToIE() *ie.IE {
+	return ie.NewCreateTrafficEndpoint(t.childIEs()...)
+}
+
+func (t *TrafficEndpoint) childIEs() []*ie.IE {
+	ies := []*ie.IE{ie.NewTrafficEndpointID(t.TrafficEndpointID)}
+	if t.LocalFTEID != nil {
+		ies = append(ies, newFieldsIE(ie.FTEID, t.LocalFTEID))
+	}
+	if t.NetworkInstance != nil {
+		ies = append(ies, ie.NewNetworkInstance(*t.NetworkInstance))
+	}
+	for _, f := range t.UEIPAddresses {
+		ies = append(ies, newFieldsIE(ie.UEIPAddress, f))
+	}
+	for _, r := range t.FramedRoutes {
+		ies = append(ies, ie.NewFramedRoute(r))
+	}
+	for _, qfi := range t.QFIs {
+		ies = append(ies, ie.NewQFI(qfi))
+	}
+	return append(ies, t.IEs...)
+}
@@ -0,0 +1,149 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package rules
+
+import (
+	"time"
+
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+// URR is a Usage Reporting Rule.
+type URR struct {
+	URRID             uint32
+	MeasurementMethod *ie.IE
+	ReportingTriggers *ie.IE
+	MeasurementPeriod *time.Duration
+	VolumeThreshold   *ie.VolumeThresholdFields
+	VolumeQuota       *ie.VolumeQuotaFields
+	TimeThreshold     *uint32
+	TimeQuota         *time.Duration
+	LinkedURRIDs      []uint32
+	// IEs are the other child IEs, e.g., QuotaHoldingTime.
+	IEs []*ie.IE
+}
+
+// ParseURR parses CreateURR IE into URR.
+func ParseURR(i *ie.IE) (*URR, error) {
+	u := &URR{}
+	if err := u.FromIE(i); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// FromIE parses CreateURR IE into u.
+func (u *URR) FromIE(i *ie.IE) error {
+	return u.parse(i, ie.CreateURR)
+}
+
+// parseUpdateURR parses UpdateURR IE into URR, which has only the fields to be
+// changed.
+func parseUpdateURR(i *ie.IE) (*URR, error) {
+	u := &URR{}
+	if err := u.parse(i, ie.UpdateURR); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// parse parses the IE of type typ into u.
+func (u *URR) parse(i *ie.IE, typ uint16) error {
+	if i.Type != typ {
+		return &ie.InvalidTypeError{Type: i.Type}
+	}
+
+	ies, err := children(i)
+	if err != nil {
+		return err
+	}
+	if !hasChild(ies, ie.URRID) {
+		return ie.ErrIENotFound
+	}
+
+	*u = URR{}
+	for _, c := range ies {
+		switch c.Type {
+		case ie.URRID:
+			v, err := c.URRID()
+			if err != nil {
+				return err
+			}
+			u.URRID = v
+		case ie.MeasurementMethod:
+			u.MeasurementMethod = c
+		case ie.ReportingTriggers:
+			u.ReportingTriggers = c
+		case ie.MeasurementPeriod:
+			v, err := c.MeasurementPeriod()
+			if err != nil {
+				return err
+			}
+			u.MeasurementPeriod = &v
+		case ie.VolumeThreshold:
+			v, err := c.VolumeThreshold()
+			if err != nil {
+				return err
+			}
+			u.VolumeThreshold = v
+		case ie.VolumeQuota:
+			v, err := c.VolumeQuota()
+			if err != nil {
+				return err
+			}
+			u.VolumeQuota = v
+		case ie.TimeThreshold:
+			v, err := c.TimeThreshold()
+			if err != nil {
+				return err
+			}
+			u.TimeThreshold = &v
+		case ie.TimeQuota:
+			v, err := c.TimeQuota()
+			if err != nil {
+				return err
+			}
+			u.TimeQuota = &v
+		case ie.LinkedURRID:
+			v, err := c.LinkedURRID()
+			if err != nil {
+				return err
+			}
+			u.LinkedURRIDs = append(u.LinkedURRIDs, v)
+		default:
+			u.IEs = append(u.IEs, c)
+		}
+	}
+	return nil
+}
+
+// ToIE creates CreateURR IE from u.
+func (u *URR) ToIE() *ie.IE {
+	return ie.NewCreateURR(u.childIEs()...)
+}
+
+func (u *URR) childIEs() []*ie.IE {
+	ies := []*ie.IE{ie.NewURRID(u.URRID)}
+	ies = appendIfNotNil(ies, u.MeasurementMethod, u.ReportingTriggers)
+	if u.MeasurementPeriod != nil {
+		ies = append(ies, ie.NewMeasurementPeriod(*u.MeasurementPeriod))
+	}
+	if u.VolumeThreshold != nil {
+		ies = append(ies, newFieldsIE(ie.VolumeThreshold, u.VolumeThreshold))
+	}
+	if u.VolumeQuota != nil {
+		ies = append(ies, newFieldsIE(ie.VolumeQuota, u.VolumeQuota))
+	}
+	if u.TimeThreshold != nil {
+		ies = append(ies, ie.NewTimeThreshold(*u.TimeThreshold))
+	}
+	if u.TimeQuota != nil {
+		ies = append(ies, ie.NewTimeQuota(*u.TimeQuota))
+	}
+	for _, id := range u.LinkedURRIDs {
+		ies = append(ies, ie.NewLinkedURRID(id))
+	}
+	return append(ies, u.IEs...)
+}
@@ -0,0 +1,495 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package pfcp
+
+import (
+	"encoding/hex"
+	"net"
+	"sync"
+
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// Session is a PFCP session registered in SessionRegistry.
+type Session struct {
+	// LocalSEID is the SEID allocated by this node.
+	LocalSEID uint64
+	// Handler handles the session related requests for the session routed
+	// by SessionRegistry.
+	Handler Handler
+
+	mu     sync.Mutex
+	peer   net.Addr
+	remote *ie.FSEIDFields
+	csids  []CSID
+}
+
+// Peer returns the address of the peer of the session.
+func (s *Session) Peer() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.peer
+}
+
+// SetPeer sets the address of the peer of the session, e.g., when another
+// SMF in the same SMF set takes over the session.
+func (s *Session) SetPeer(peer net.Addr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.peer = peer
+}
+
+// RemoteFSEID returns the F-SEID allocated by the peer, or nil if not known.
+func (s *Session) RemoteFSEID() *ie.FSEIDFields {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.remote
+}
+
+// RemoteSEID returns the SEID allocated by the peer, or 0 if not known.
+func (s *Session) RemoteSEID() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.remote == nil {
+		return 0
+	}
+	return s.remote.SEID
+}
+
+// SetRemoteFSEID sets the F-SEID allocated by the peer, e.g., the UP F-SEID in
+// SessionEstablishmentResponse on the CP function.
+func (s *Session) SetRemoteFSEID(fseid *ie.IE) error {
+	f, err := fseid.FSEID()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.remote = f
+	return nil
+}
+
+// CSIDs returns the connection sets the session belongs to, recorded by
+// SessionRegistry.
+func (s *Session) CSIDs() []CSID {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]CSID(nil), s.csids...)
+}
+
+// CSID identifies a PDN connection set by the address of the node that
+// allocated it and the Connection Set Identifier, as defined in TS 23.007.
+type CSID struct {
+	// Node is the IP address of the node, or the hex string of the other
+	// type of Node-ID as given to ie.NewFQCSID.
+	Node string
+	// ID is the Connection Set Identifier.
+	ID uint16
+}
+
+// ParseFQCSID returns the CSIDs in the FQCSID IE.
+func ParseFQCSID(fqcsid *ie.IE) ([]CSID, error) {
+	typ, err := fqcsid.NodeIDType()
+	if err != nil {
+		return nil, err
+	}
+	addr, err := fqcsid.NodeAddress()
+	if err != nil {
+		return nil, err
+	}
+	ids, err := fqcsid.CSIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	// the Node-ID Type in FQ-CSID is 0 for IPv4, 1 for IPv6 and 2 for the
+	// others as in NodeID.
+	node := hex.EncodeToString(addr)
+	if typ == ie.NodeIDIPv4Address || typ == ie.NodeIDIPv6Address {
+		node = net.IP(addr).String()
+	}
+
+	csids := make([]CSID, len(ids))
+	for n, id := range ids {
+		csids[n] = CSID{Node: node, ID: id}
+	}
+	return csids, nil
+}
+
+// FQCSIDs returns all the FQCSID IEs in msg, which are the FQ-CSIDs of
+// SGW-C, PGW-C/SMF, MME, ePDG, TWAN or UP function depending on the type.
+func FQCSIDs(msg message.Message) []*ie.IE {
+	var (
+		first *ie.IE
+		ies   []*ie.IE
+	)
+	switch m := msg.(type) {
+	case *message.SessionEstablishmentRequest:
+		first, ies = m.FQCSID, m.IEs
+	case *message.SessionEstablishmentResponse:
+		first, ies = m.FQCSID, m.IEs
+	case *message.SessionModificationRequest:
+		first, ies = m.FQCSID, m.IEs
+	case *message.SessionSetDeletionRequest:
+		first, ies = m.FQCSID, m.IEs
+	default:
+		return nil
+	}
+
+	var fqcsids []*ie.IE
+	if first != nil {
+		fqcsids = append(fqcsids, first)
+	}
+	for _, i := range ies {
+		if i != nil && i.Type == ie.FQCSID {
+			fqcsids = append(fqcsids, i)
+		}
+	}
+	return fqcsids
+}
+
+// SessionRegistry keeps the PFCP sessions of a node, keyed by the local SEID.
+//
+// SessionRegistry allocates the unique local SEIDs to the sessions, and routes
+// the session related requests to the Handler of the session identified by
+// the SEID in the header when it is registered as a Handler, e.g., with
+// ServeMux.Handle(message.MsgTypeSessionModificationRequest, registry).
+// The requests with unknown SEID are answered with the Cause "Session context
+// not found" and SEID 0 in the header, as required in TS 29.244 §7.2.2.4.2.
+//
+// SessionRegistry also keeps the connection sets the sessions belong to, which
+// are recorded from the FQ-CSIDs in the requests routed to the sessions, or
+// with RecordFQCSIDs. When SessionSetDeletionRequest is routed to it, all the
+// sessions in the connection sets in it are deleted, as defined in TS 29.244
+// §6.2.7.
+type SessionRegistry struct {
+	// NodeID is put in SessionSetDeletionResponse.
+	NodeID *ie.IE
+	// OnSetDeletion is called for each session deleted by DeleteSet, if not
+	// nil.
+	OnSetDeletion func(s *Session)
+	// TakeOver is called for SessionReportRequest with OldCPFSEID, which a UP
+	// function sends to this node when another SMF in the same SMF set fails,
+	// as defined in TS 29.244 §5.22.3. It is called instead of looking up the
+	// SEID in the request, which is allocated by the failed SMF and may be
+	// the same as the one of another session registered.
+	// It returns the session taking over the one of old, e.g., created with
+	// New with the UP F-SEID stored for it, or false to reject the request.
+	// The Handler of the session should put CPFSEID in the response to make
+	// the UP function send the subsequent messages to the new one.
+	TakeOver func(peer net.Addr, old *ie.FSEIDFields) (*Session, bool)
+	// Metrics receives the number of the sessions registered each time it
+	// changes, if not nil.
+	Metrics Metrics
+
+	mu       sync.Mutex
+	sessions map[uint64]*Session
+	sets     map[CSID]map[uint64]*Session
+	lastSEID uint64
+}
+
+// NewSessionRegistry creates a new SessionRegistry.
+func NewSessionRegistry() *SessionRegistry {
+	return &SessionRegistry{
+		sessions: make(map[uint64]*Session),
+		sets:     make(map[CSID]map[uint64]*Session),
+	}
+}
+
+// New allocates a new local SEID and registers a new Session with it.
+//
+// remote is the F-SEID allocated by the peer(e.g., CP F-SEID in
+// SessionEstablishmentRequest on the UP function), which can be nil if it is
+// not known yet. h handles the requests for the session.
+func (r *SessionRegistry) New(peer net.Addr, remote *ie.IE, h Handler) (*Session, error) {
+	s := &Session{Handler: h, peer: peer}
+	if remote != nil {
+		if err := s.SetRemoteFSEID(remote); err != nil {
+			return nil, err
+		}
+	}
+
+	r.mu.Lock()
+
+	// SEID 0 is reserved for the messages with no session context.
+	for {
+		r.lastSEID++
+		if r.lastSEID == 0 {
+			continue
+		}
+		if _, ok := r.sessions[r.lastSEID]; !ok {
+			break
+		}
+	}
+
+	s.LocalSEID = r.lastSEID
+	r.sessions[s.LocalSEID] = s
+	n := len(r.sessions)
+	r.mu.Unlock()
+
+	r.reportSessions(n)
+	return s, nil
+}
+
+// Session returns the session with the local SEID given.
+func (r *SessionRegistry) Session(seid uint64) (*Session, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.sessions[seid]
+	return s, ok
+}
+
+// Delete removes the session with the local SEID given.
+//
+// It should be called by the Handler of the session after accepting the
+// SessionDeletionRequest, or on the CP function after receiving the response
+// to it.
+func (r *SessionRegistry) Delete(seid uint64) {
+	r.mu.Lock()
+	r.delete(seid)
+	n := len(r.sessions)
+	r.mu.Unlock()
+
+	r.reportSessions(n)
+}
+
+func (r *SessionRegistry) delete(seid uint64) *Session {
+	s, ok := r.sessions[seid]
+	if !ok {
+		return nil
+	}
+	delete(r.sessions, seid)
+
+	for _, c := range s.CSIDs() {
+		delete(r.sets[c], seid)
+		if len(r.sets[c]) == 0 {
+			delete(r.sets, c)
+		}
+	}
+	return s
+}
+
+// RecordFQCSIDs adds the connection sets in the FQ-CSIDs in msg to the session
+// with the local SEID given, e.g., the ones in SessionEstablishmentRequest
+// on the UP function, or in SessionEstablishmentResponse on the CP function.
+func (r *SessionRegistry) RecordFQCSIDs(seid uint64, msg message.Message) error {
+	var csids []CSID
+	for _, i := range FQCSIDs(msg) {
+		c, err := ParseFQCSID(i)
+		if err != nil {
+			return err
+		}
+		csids = append(csids, c...)
+	}
+	if len(csids) == 0 {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.sessions[seid]
+	if !ok {
+		return ErrSessionNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range csids {
+		if _, ok := r.sets[c][seid]; ok {
+			continue
+		}
+		if r.sets[c] == nil {
+			r.sets[c] = make(map[uint64]*Session)
+		}
+		r.sets[c][seid] = s
+		s.csids = append(s.csids, c)
+	}
+	return nil
+}
+
+// SessionsInSet returns the sessions that belong to any of the connection
+// sets given.
+func (r *SessionRegistry) SessionsInSet(csids ...CSID) []*Session {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.inSet(csids)
+}
+
+func (r *SessionRegistry) inSet(csids []CSID) []*Session {
+	var (
+		sessions []*Session
+		found    = make(map[uint64]bool)
+	)
+	for _, c := range csids {
+		for seid, s := range r.sets[c] {
+			if !found[seid] {
+				found[seid] = true
+				sessions = append(sessions, s)
+			}
+		}
+	}
+	return sessions
+}
+
+// DeleteSet removes the sessions that belong to any of the connection sets
+// given, and returns them. OnSetDeletion is called for each of them.
+func (r *SessionRegistry) DeleteSet(csids ...CSID) []*Session {
+	r.mu.Lock()
+	sessions := r.inSet(csids)
+	for _, s := range sessions {
+		r.delete(s.LocalSEID)
+	}
+	n := len(r.sessions)
+	r.mu.Unlock()
+
+	r.reportSessions(n)
+
+	if r.OnSetDeletion != nil {
+		for _, s := range sessions {
+			r.OnSetDeletion(s)
+		}
+	}
+	return sessions
+}
+
+// reportSessions passes n, the number of the sessions, to Metrics.
+func (r *SessionRegistry) reportSessions(n int) {
+	if r.Metrics != nil {
+		r.Metrics.ActiveSessions(n)
+	}
+}
+
+// Len returns the number of sessions registered.
+func (r *SessionRegistry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.sessions)
+}
+
+// ServePFCP passes the session related request to the Handler of the session
+// identified by the SEID in the header.
+//
+// The SEID in the response written by the Handler is set to the remote SEID
+// of the session. SessionSetDeletionRequest is handled by the registry itself.
+func (r *SessionRegistry) ServePFCP(w ResponseWriter, peer net.Addr, msg message.Message) {
+	l := loggerOf(w)
+	if req, ok := msg.(*message.SessionSetDeletionRequest); ok {
+		if err := w.WriteMessage(r.handleSetDeletion(req)); err != nil {
+			l.Log(LogLevelWarn, "failed to respond to request", "peer", peer, "type", msg.MessageTypeName(), "error", err)
+		}
+		return
+	}
+
+	var s *Session
+	var ok bool
+	if old, takeOver := r.oldCPFSEID(l, peer, msg); takeOver {
+		// the SEID in the request is allocated by the failed SMF.
+		s, ok = r.TakeOver(peer, old)
+	} else {
+		s, ok = r.Session(msg.SEID())
+	}
+	if !ok || s.Handler == nil {
+		if ok {
+			l.Log(LogLevelDebug, "no handler for session", "peer", peer, "type", msg.MessageTypeName(), "seid", msg.SEID())
+		}
+
+		if h, ok := msg.(interface{ HasSEID() bool }); !ok || !h.HasSEID() {
+			l.Log(LogLevelDebug, "ignored message: not a session related request", "peer", peer, "type", msg.MessageTypeName())
+			return
+		}
+
+		rsp := newCauseResponse(w, msg, ie.CauseSessionContextNotFound, nil)
+		if rsp == nil {
+			l.Log(LogLevelDebug, "ignored message: no response with Cause", "peer", peer, "type", msg.MessageTypeName())
+			return
+		}
+		if err := w.WriteMessage(rsp); err != nil {
+			l.Log(LogLevelWarn, "failed to respond to request", "peer", peer, "type", msg.MessageTypeName(), "error", err)
+		}
+		return
+	}
+
+	if err := r.RecordFQCSIDs(s.LocalSEID, msg); err != nil {
+		l.Log(LogLevelWarn, "failed to record FQ-CSIDs", "peer", peer, "type", msg.MessageTypeName(), "seid", s.LocalSEID, "error", err)
+	}
+	if req, ok := msg.(*message.SessionModificationRequest); ok {
+		takeOver(l, s, peer, req)
+	}
+	s.Handler.ServePFCP(&sessionResponse{ResponseWriter: w, session: s}, peer, msg)
+}
+
+// oldCPFSEID returns OldCPFSEID in msg, and reports whether msg is to be
+// passed to TakeOver, i.e., it is SessionReportRequest with the valid one
+// and TakeOver is set.
+func (r *SessionRegistry) oldCPFSEID(l Logger, peer net.Addr, msg message.Message) (*ie.FSEIDFields, bool) {
+	req, ok := msg.(*message.SessionReportRequest)
+	if !ok || req.OldCPFSEID == nil || r.TakeOver == nil {
+		return nil, false
+	}
+
+	old, err := req.OldCPFSEID.FSEID()
+	if err != nil {
+		l.Log(LogLevelWarn, "got invalid OldCPFSEID", "peer", peer, "type", msg.MessageTypeName(), "error", err)
+		return nil, false
+	}
+	return old, true
+}
+
+// handleSetDeletion deletes the sessions in the connection sets in req, and
+// returns the response to it.
+func (r *SessionRegistry) handleSetDeletion(req *message.SessionSetDeletionRequest) message.Message {
+	fqcsids := FQCSIDs(req)
+	if len(fqcsids) == 0 {
+		return message.NewSessionSetDeletionResponse(0, r.NodeID, ie.NewCause(ie.CauseMandatoryIEMissing), ie.NewOffendingIE(ie.FQCSID))
+	}
+
+	var csids []CSID
+	for _, i := range fqcsids {
+		c, err := ParseFQCSID(i)
+		if err != nil {
+			return message.NewSessionSetDeletionResponse(0, r.NodeID, ie.NewCause(ie.CauseMandatoryIEIncorrect), ie.NewOffendingIE(ie.FQCSID))
+		}
+		csids = append(csids, c...)
+	}
+
+	r.DeleteSet(csids...)
+	return message.NewSessionSetDeletionResponse(0, r.NodeID, ie.NewCause(ie.CauseRequestAccepted), nil)
+}
+
+// sessionResponse is the ResponseWriter given to the Handler of a session.
+type sessionResponse struct {
+	ResponseWriter
+	session *Session
+}
+
+// WriteMessage sends msg with the remote SEID of the session, if known.
+func (r *sessionResponse) WriteMessage(msg message.Message) error {
+	if h, ok := msg.(interface {
+		HasSEID() bool
+		SetSEID(uint64)
+	}); ok && h.HasSEID() {
+		if f := r.session.RemoteFSEID(); f != nil {
+			h.SetSEID(f.SEID)
+		}
+	}
+	return r.ResponseWriter.WriteMessage(msg)
+}
+
+func (r *sessionResponse) log() Logger {
+	return loggerOf(r.ResponseWriter)
+}
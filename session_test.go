@@ -0,0 +1,231 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package pfcp_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/wmnsk/go-pfcp"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+func TestSessionRegistry(t *testing.T) {
+	reg := pfcp.NewSessionRegistry()
+	srv := listen(t, func(c *pfcp.Conn) {
+		c.Handler = reg
+	})
+	cli := listen(t, nil)
+
+	accept := pfcp.HandlerFunc(func(w pfcp.ResponseWriter, peer net.Addr, msg message.Message) {
+		_ = w.WriteMessage(message.NewSessionModificationResponse(0, 0, 0, 0, 0, ie.NewCause(ie.CauseRequestAccepted)))
+	})
+
+	s1, err := reg.New(cli.LocalAddr(), ie.NewFSEID(0x1111, net.ParseIP("127.0.0.1"), nil, nil), accept)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2, err := reg.New(cli.LocalAddr(), ie.NewFSEID(0x2222, net.ParseIP("127.0.0.1"), nil, nil), accept)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s1.LocalSEID == 0 || s1.LocalSEID == s2.LocalSEID {
+		t.Fatalf("got invalid SEIDs: %#x, %#x", s1.LocalSEID, s2.LocalSEID)
+	}
+
+	cases := []struct {
+		description string
+		seid        uint64
+		cause       uint8
+		rspSEID     uint64
+	}{
+		{"known", s2.LocalSEID, ie.CauseRequestAccepted, 0x2222},
+		{"unknown", 0xdead, ie.CauseSessionContextNotFound, 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			rsp, err := cli.Request(context.Background(), message.NewSessionModificationRequest(0, 0, c.seid, 0, 0), srv.LocalAddr())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := rsp.SEID(); got != c.rspSEID {
+				t.Errorf("got SEID %#x want %#x", got, c.rspSEID)
+			}
+			got, err := rsp.(*message.SessionModificationResponse).Cause.Cause()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != c.cause {
+				t.Errorf("got Cause %d want %d", got, c.cause)
+			}
+		})
+	}
+
+	reg.Delete(s1.LocalSEID)
+	if _, ok := reg.Session(s1.LocalSEID); ok {
+		t.Error("session remains after Delete")
+	}
+	if got := reg.Len(); got != 1 {
+		t.Errorf("got %d sessions want 1", got)
+	}
+}
+
+func TestResponseSEID(t *testing.T) {
+	localhost := net.ParseIP("127.0.0.1")
+	reg := pfcp.NewSessionRegistry()
+	mux := pfcp.NewServeMux()
+	mux.HandleFunc(message.MsgTypeSessionEstablishmentRequest, func(w pfcp.ResponseWriter, peer net.Addr, msg message.Message) {
+		_ = w.WriteMessage(message.NewSessionEstablishmentResponse(0, 0, 0, 0, 0,
+			ie.NewNodeID("127.0.0.1", "", ""), ie.NewCause(ie.CauseRequestAccepted),
+		))
+	})
+	for _, typ := range []uint8{
+		message.MsgTypeSessionModificationRequest,
+		message.MsgTypeSessionDeletionRequest,
+		message.MsgTypeSessionReportRequest,
+	} {
+		mux.Handle(typ, reg)
+	}
+	srv := listen(t, func(c *pfcp.Conn) {
+		c.Handler = mux
+	})
+	cli := listen(t, nil)
+
+	// the Handler of the sessions sets no SEID, except for the one with the
+	// remote F-SEID unknown.
+	accept := pfcp.HandlerFunc(func(w pfcp.ResponseWriter, peer net.Addr, msg message.Message) {
+		cause := ie.NewCause(ie.CauseRequestAccepted)
+		switch msg.(type) {
+		case *message.SessionModificationRequest:
+			_ = w.WriteMessage(message.NewSessionModificationResponse(0, 0, 0, 0, 0, cause))
+		case *message.SessionDeletionRequest:
+			_ = w.WriteMessage(message.NewSessionDeletionResponse(0, 0, 0, 0, 0, cause))
+		case *message.SessionReportRequest:
+			_ = w.WriteMessage(message.NewSessionReportResponse(0, 0, 0, 0, 0, cause))
+		}
+	})
+	s, err := reg.New(cli.LocalAddr(), ie.NewFSEID(0x1111, localhost, nil, nil), accept)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknown, err := reg.New(cli.LocalAddr(), nil, pfcp.HandlerFunc(func(w pfcp.ResponseWriter, peer net.Addr, msg message.Message) {
+		_ = w.WriteMessage(message.NewSessionModificationResponse(0, 0, 0x3333, 0, 0, ie.NewCause(ie.CauseRequestAccepted)))
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		description string
+		req         message.Message
+		seid        uint64
+	}{
+		{
+			"SessionEstablishment",
+			message.NewSessionEstablishmentRequest(0, 0, 0, 0, 0,
+				ie.NewNodeID("127.0.0.2", "", ""), ie.NewFSEID(0x2222, localhost, nil, nil),
+			),
+			0x2222,
+		},
+		{"SessionModification", message.NewSessionModificationRequest(0, 0, s.LocalSEID, 0, 0), 0x1111},
+		{"SessionDeletion", message.NewSessionDeletionRequest(0, 0, s.LocalSEID, 0, 0), 0x1111},
+		{"SessionReport", message.NewSessionReportRequest(0, 0, s.LocalSEID, 0, 0), 0x1111},
+		{"RemoteFSEIDUnknown", message.NewSessionModificationRequest(0, 0, unknown.LocalSEID, 0, 0), 0x3333},
+	} {
+		t.Run(c.description, func(t *testing.T) {
+			rsp, err := cli.Request(context.Background(), c.req, srv.LocalAddr())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := pfcp.ResponseError(rsp); err != nil {
+				t.Fatal(err)
+			}
+			if got := rsp.SEID(); got != c.seid {
+				t.Errorf("got SEID %#x want %#x", got, c.seid)
+			}
+		})
+	}
+}
+
+func TestSessionRegistrySetDeletion(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		deleted []uint64
+	)
+	reg := pfcp.NewSessionRegistry()
+	reg.NodeID = ie.NewNodeID("127.0.0.2", "", "")
+	reg.OnSetDeletion = func(s *pfcp.Session) {
+		mu.Lock()
+		defer mu.Unlock()
+		deleted = append(deleted, s.LocalSEID)
+	}
+
+	up := listen(t, func(c *pfcp.Conn) {
+		mux := pfcp.NewServeMux()
+		mux.HandleFunc(message.MsgTypeAssociationSetupRequest, func(w pfcp.ResponseWriter, peer net.Addr, msg message.Message) {
+			_ = w.WriteMessage(message.NewAssociationSetupResponse(0,
+				reg.NodeID, ie.NewCause(ie.CauseRequestAccepted), ie.NewRecoveryTimeStamp(ts),
+			))
+		})
+		mux.Handle(message.MsgTypeSessionSetDeletionRequest, reg)
+		c.Handler = mux
+	})
+
+	var m *pfcp.CPAssociationManager
+	listen(t, func(c *pfcp.Conn) {
+		m = pfcp.NewCPAssociationManager(c, ie.NewNodeID("127.0.0.1", "", ""))
+	})
+
+	sgwc := ie.NewFQCSID("127.0.0.10", 1)
+	pgwc := ie.NewFQCSID("127.0.0.20", 7)
+	sessions := make([]*pfcp.Session, 3)
+	for n, fqcsids := range [][]*ie.IE{{sgwc}, {sgwc, pgwc}, {ie.NewFQCSID("127.0.0.10", 2)}} {
+		s, err := reg.New(nil, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ser := message.NewSessionEstablishmentRequest(0, 0, 0, 1, 0, fqcsids...)
+		if err := reg.RecordFQCSIDs(s.LocalSEID, ser); err != nil {
+			t.Fatal(err)
+		}
+		sessions[n] = s
+	}
+	if got := len(reg.SessionsInSet(pfcp.CSID{Node: "127.0.0.20", ID: 7})); got != 1 {
+		t.Errorf("got %d sessions in PGW-C set want 1", got)
+	}
+
+	ctx := context.Background()
+	if err := m.DeleteSessionSet(ctx, "127.0.0.2", sgwc); !errors.Is(err, pfcp.ErrNoAssociation) {
+		t.Fatalf("got %v want %v", err, pfcp.ErrNoAssociation)
+	}
+	if _, err := m.Setup(ctx, "127.0.0.2", up.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.DeleteSessionSet(ctx, "127.0.0.2", sgwc); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	n := len(deleted)
+	mu.Unlock()
+	if n != 2 {
+		t.Fatalf("got %d sessions deleted want 2", n)
+	}
+	if got := reg.Len(); got != 1 {
+		t.Errorf("got %d sessions want 1", got)
+	}
+	if _, ok := reg.Session(sessions[2].LocalSEID); !ok {
+		t.Error("session in the other set deleted")
+	}
+	if got := len(reg.SessionsInSet(pfcp.CSID{Node: "127.0.0.20", ID: 7})); got != 0 {
+		t.Errorf("got %d sessions in PGW-C set after deletion", got)
+	}
+}
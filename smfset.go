@@ -0,0 +1,230 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package pfcp
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// Alternatives returns the addresses of the SMFs that can take over the
+// sessions with the CP function at peer when it fails, as defined in TS
+// 29.244 §5.22.
+//
+// They are the AlternativeSMFIPAddresses advertised by the peer, followed by
+// the other peers associated in the same SMF set. The port of peer is used
+// for the AlternativeSMFIPAddresses.
+func (u *UPAssociationAcceptor) Alternatives(peer net.Addr) []net.Addr {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var cur *Association
+	for _, a := range u.assocs {
+		if a.Peer.String() == peer.String() {
+			cur = a
+			break
+		}
+	}
+	if cur == nil {
+		return nil
+	}
+
+	port := 0
+	if a, ok := peer.(*net.UDPAddr); ok {
+		port = a.Port
+	}
+
+	var addrs []net.Addr
+	seen := map[string]bool{peer.String(): true}
+	add := func(a net.Addr) {
+		if !seen[a.String()] {
+			seen[a.String()] = true
+			addrs = append(addrs, a)
+		}
+	}
+	for _, ip := range cur.AlternativeSMFIPAddresses {
+		add(&net.UDPAddr{IP: ip, Port: port})
+	}
+	if cur.SMFSetID != "" {
+		for _, a := range u.assocs {
+			if a.SMFSetID == cur.SMFSetID {
+				add(a.Peer)
+			}
+		}
+	}
+	return addrs
+}
+
+// Report sends req to the CP function of the session s, and waits for the
+// response to it.
+//
+// If the CP function does not answer, req is sent to the alternative SMFs
+// given by Alternatives in turn, with OldCPFSEID set to the F-SEID of the
+// failed one. When the CP function answers with the AlternativeSMFIPAddress
+// and rejects req, req is sent to the one in it instead. The peer of s is
+// updated to the one that accepts req, and the remote F-SEID is updated
+// with CPFSEID in the response if any.
+//
+// It returns ErrTimeout if none of them answer, and CauseError with the
+// response if the request is rejected.
+func (u *UPAssociationAcceptor) Report(ctx context.Context, s *Session, req *message.SessionReportRequest) (*message.SessionReportResponse, error) {
+	req.SetSEID(s.RemoteSEID())
+
+	peer := s.Peer()
+	rsp, err := u.report(ctx, req, peer)
+	if errors.Is(err, ErrTimeout) {
+		setOldCPFSEID(s, req)
+		for _, alt := range u.Alternatives(peer) {
+			u.conn.log().Log(LogLevelWarn, "request unanswered, trying alternative SMF", "peer", peer, "type", req.MessageTypeName(), "seid", s.LocalSEID, "alternative", alt)
+			rsp, err = u.report(ctx, req, alt)
+			if !errors.Is(err, ErrTimeout) {
+				peer = alt
+				break
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the CP function may redirect the request to another one.
+	if rsp.AlternativeSMFIPAddress != nil && ResponseError(rsp) != nil {
+		if alt := redirectAddr(peer, rsp.AlternativeSMFIPAddress); alt != nil {
+			setOldCPFSEID(s, req)
+			if rsp, err = u.report(ctx, req, alt); err != nil {
+				return nil, err
+			}
+			peer = alt
+		}
+	}
+
+	if err := ResponseError(rsp); err != nil {
+		return rsp, err
+	}
+	if peer.String() != s.Peer().String() {
+		s.SetPeer(peer)
+	}
+	if rsp.CPFSEID != nil {
+		if err := s.SetRemoteFSEID(rsp.CPFSEID); err != nil {
+			u.conn.log().Log(LogLevelWarn, "got invalid CPFSEID", "peer", peer, "type", rsp.MessageTypeName(), "seid", s.LocalSEID, "error", err)
+		}
+	}
+	return rsp, nil
+}
+
+func (u *UPAssociationAcceptor) report(ctx context.Context, req *message.SessionReportRequest, peer net.Addr) (*message.SessionReportResponse, error) {
+	rsp, err := u.conn.Request(ctx, req, peer)
+	if err != nil {
+		return nil, err
+	}
+
+	res, ok := rsp.(*message.SessionReportResponse)
+	if !ok {
+		return nil, &InvalidMessageError{Type: rsp.MessageType()}
+	}
+	return res, nil
+}
+
+// setOldCPFSEID puts the remote F-SEID of s in req as OldCPFSEID, to be sent
+// to an SMF other than the one that has s.
+func setOldCPFSEID(s *Session, req *message.SessionReportRequest) {
+	if req.OldCPFSEID != nil {
+		return
+	}
+	if f := s.RemoteFSEID(); f != nil {
+		req.OldCPFSEID = ie.NewFSEID(f.SEID, f.IPv4Address, f.IPv6Address, nil)
+	}
+}
+
+// TakeOverSession makes the UP function of nodeID send the messages of the
+// session s to this node, by sending SessionModificationRequest with cpFSEID,
+// the F-SEID allocated by this node to s, as defined in TS 29.244 §5.22.2.
+//
+// It is used when this node takes over s from another SMF in the same SMF
+// set. s is typically created with SessionRegistry.New with the UP F-SEID of
+// the session taken from the failed one, and its peer is updated to the UP
+// function after the request is accepted.
+//
+// The additional ies given are put in the request. It returns
+// ErrNoAssociation without sending the request if the association with the
+// peer is not established, and CauseError if the peer rejects it.
+func (m *CPAssociationManager) TakeOverSession(ctx context.Context, nodeID string, s *Session, cpFSEID *ie.IE, ies ...*ie.IE) error {
+	a, err := m.associated(nodeID)
+	if err != nil {
+		return err
+	}
+
+	req := message.NewSessionModificationRequest(0, 0, s.RemoteSEID(), 0, 0, append([]*ie.IE{cpFSEID}, ies...)...)
+	rsp, err := m.conn.Request(ctx, req, a.Peer)
+	if err != nil {
+		return err
+	}
+
+	res, ok := rsp.(*message.SessionModificationResponse)
+	if !ok {
+		return &InvalidMessageError{Type: rsp.MessageType()}
+	}
+	if err := ResponseError(res); err != nil {
+		return err
+	}
+
+	s.SetPeer(a.Peer)
+	return nil
+}
+
+// takeOver updates the peer and the remote F-SEID of s with CPFSEID in the
+// SessionModificationRequest from the CP function, which is sent when
+// another SMF in the same SMF set takes over s.
+func takeOver(l Logger, s *Session, peer net.Addr, req *message.SessionModificationRequest) {
+	if req.CPFSEID == nil {
+		return
+	}
+	if err := s.SetRemoteFSEID(req.CPFSEID); err != nil {
+		l.Log(LogLevelWarn, "got invalid CPFSEID", "peer", peer, "type", req.MessageTypeName(), "seid", s.LocalSEID, "error", err)
+		return
+	}
+	if peer.String() != s.Peer().String() {
+		l.Log(LogLevelInfo, "session taken over", "peer", peer, "seid", s.LocalSEID)
+		s.SetPeer(peer)
+	}
+}
+
+// alternativeSMFIPAddresses returns the addresses in AlternativeSMFIPAddress
+// IEs, preferring IPv4 ones.
+func alternativeSMFIPAddresses(ies []*ie.IE) []net.IP {
+	var ips []net.IP
+	for _, i := range ies {
+		f, err := i.AlternativeSMFIPAddress()
+		if err != nil {
+			continue
+		}
+		switch {
+		case f.HasIPv4():
+			ips = append(ips, f.IPv4Address)
+		case f.HasIPv6():
+			ips = append(ips, f.IPv6Address)
+		}
+	}
+	return ips
+}
+
+// redirectAddr returns the address in AlternativeSMFIPAddress with the port
+// of peer, or nil if it is invalid.
+func redirectAddr(peer net.Addr, i *ie.IE) net.Addr {
+	ips := alternativeSMFIPAddresses([]*ie.IE{i})
+	if len(ips) == 0 {
+		return nil
+	}
+
+	port := 0
+	if a, ok := peer.(*net.UDPAddr); ok {
+		port = a.Port
+	}
+	return &net.UDPAddr{IP: ips[0], Port: port}
+}
@@ -0,0 +1,141 @@
+// Copyright 2019-2020 go-pfcp authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package pfcp_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wmnsk/go-pfcp"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+func TestSMFSetFailover(t *testing.T) {
+	ctx := context.Background()
+	localhost := net.ParseIP("127.0.0.1")
+
+	upReg := pfcp.NewSessionRegistry()
+	var acceptor *pfcp.UPAssociationAcceptor
+	up := listen(t, func(c *pfcp.Conn) {
+		c.T1 = 50 * time.Millisecond
+		c.N1 = 1
+		acceptor = pfcp.NewUPAssociationAcceptor(c, ie.NewNodeID("127.0.0.2", "", ""))
+		mux := pfcp.NewServeMux()
+		mux.Handle(message.MsgTypeAssociationSetupRequest, acceptor)
+		mux.Handle(message.MsgTypeSessionModificationRequest, upReg)
+		c.Handler = mux
+	})
+
+	// smf returns the CPAssociationManager of an SMF in the set.
+	smf := func(nodeID string, reg *pfcp.SessionRegistry) (*pfcp.Conn, *pfcp.CPAssociationManager) {
+		t.Helper()
+		var m *pfcp.CPAssociationManager
+		c := listen(t, func(c *pfcp.Conn) {
+			m = pfcp.NewCPAssociationManager(c, ie.NewNodeID(nodeID, "", ""))
+			if reg != nil {
+				c.Handler = reg
+			}
+		})
+		if _, err := m.Setup(ctx, "127.0.0.2", up.LocalAddr(), ie.NewSMFSetID("smfset.example")); err != nil {
+			t.Fatal(err)
+		}
+		return c, m
+	}
+
+	smf1, _ := smf("127.0.0.11", nil)
+	s, err := upReg.New(smf1.LocalAddr(), ie.NewFSEID(0x1111, localhost, nil, nil), pfcp.HandlerFunc(func(w pfcp.ResponseWriter, peer net.Addr, msg message.Message) {
+		_ = w.WriteMessage(message.NewSessionModificationResponse(0, 0, 0, 0, 0, ie.NewCause(ie.CauseRequestAccepted)))
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the SMF that takes over the session reported.
+	reg2 := pfcp.NewSessionRegistry()
+	var old uint64
+	reg2.TakeOver = func(peer net.Addr, f *ie.FSEIDFields) (*pfcp.Session, bool) {
+		atomic.StoreUint64(&old, f.SEID)
+		s2, err := reg2.New(peer, ie.NewFSEID(s.LocalSEID, localhost, nil, nil), pfcp.HandlerFunc(func(w pfcp.ResponseWriter, peer net.Addr, msg message.Message) {
+			_ = w.WriteMessage(message.NewSessionReportResponse(0, 0, 0, 0, 0,
+				ie.NewCause(ie.CauseRequestAccepted),
+				ie.NewFSEID(0x2222, localhost, nil, nil),
+			))
+		}))
+		return s2, err == nil
+	}
+	smf2, _ := smf("127.0.0.12", reg2)
+
+	_ = smf1.Close()
+	if _, err := acceptor.Report(ctx, s, message.NewSessionReportRequest(0, 0, 0, 0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadUint64(&old); got != 0x1111 {
+		t.Errorf("got OldCPFSEID %#x want %#x", got, 0x1111)
+	}
+	if got := s.Peer().String(); got != smf2.LocalAddr().String() {
+		t.Errorf("got peer %s want %s", got, smf2.LocalAddr())
+	}
+	if got := s.RemoteSEID(); got != 0x2222 {
+		t.Errorf("got remote SEID %#x want %#x", got, 0x2222)
+	}
+
+	// another SMF takes over the session with a new CP F-SEID.
+	reg3 := pfcp.NewSessionRegistry()
+	smf3, m3 := smf("127.0.0.13", reg3)
+	s3, err := reg3.New(nil, ie.NewFSEID(s.LocalSEID, localhost, nil, nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m3.TakeOverSession(ctx, "127.0.0.2", s3, ie.NewFSEID(s3.LocalSEID+0x3000, localhost, nil, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Peer().String(); got != smf3.LocalAddr().String() {
+		t.Errorf("got peer %s want %s", got, smf3.LocalAddr())
+	}
+	if got, want := s.RemoteSEID(), s3.LocalSEID+0x3000; got != want {
+		t.Errorf("got remote SEID %#x want %#x", got, want)
+	}
+	if got := s3.Peer().String(); got != up.LocalAddr().String() {
+		t.Errorf("got peer of taken over session %s want %s", got, up.LocalAddr())
+	}
+}
+
+func TestSessionRegistryTakeOverRejected(t *testing.T) {
+	reg := pfcp.NewSessionRegistry()
+	reg.TakeOver = func(peer net.Addr, f *ie.FSEIDFields) (*pfcp.Session, bool) {
+		return nil, false
+	}
+	handled := make(chan struct{}, 1)
+	s, err := reg.New(nil, nil, pfcp.HandlerFunc(func(w pfcp.ResponseWriter, peer net.Addr, msg message.Message) {
+		handled <- struct{}{}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	smf := listen(t, func(c *pfcp.Conn) {
+		c.Handler = reg
+	})
+	up := listen(t, nil)
+
+	// the SEID of the failed SMF is the same as the one of s by chance.
+	req := message.NewSessionReportRequest(0, 0, s.LocalSEID, 0, 0, ie.NewFSEID(s.LocalSEID, net.ParseIP("127.0.0.11"), nil, nil))
+	rsp, err := up.Request(context.Background(), req, smf.LocalAddr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pfcp.ResponseError(rsp); !errors.Is(err, pfcp.ErrSessionContextNotFound) {
+		t.Errorf("got %v want %v", err, pfcp.ErrSessionContextNotFound)
+	}
+	select {
+	case <-handled:
+		t.Error("request passed to the session with the same SEID")
+	default:
+	}
+}